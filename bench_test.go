@@ -0,0 +1,269 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// bulkItems returns n items of mixed kinds, like a Conf merged from many
+// sources
+func bulkItems(n int) []resolvconf.ConfItem {
+	items := make([]resolvconf.ConfItem, 0, n)
+	for i := 0; len(items) < n; i++ {
+		ip := net.IPv4(10, byte(i>>8), byte(i), 1)
+		items = append(items, resolvconf.NewNameserver(ip))
+		items = append(items, resolvconf.NewSearchDomain("d"+strconv.Itoa(i)+".example"))
+		items = append(items, resolvconf.NewSortItem(ip).SetNetmask(net.IPv4(255, 255, 255, 0)))
+	}
+	return items[:n]
+}
+
+func BenchmarkBulkAdd(b *testing.B) {
+	for _, n := range []int{50, 500} {
+		items := bulkItems(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+				conf.Add(items...)
+			}
+		})
+	}
+}
+
+// BenchmarkBulkAddLogged is BenchmarkBulkAdd with logging enabled, the
+// difference is the cost of building the log messages
+func BenchmarkBulkAddLogged(b *testing.B) {
+	items := bulkItems(50)
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+		conf.EnableLogging(&buf)
+		conf.Add(items...)
+	}
+}
+
+func BenchmarkAddOptions(b *testing.B) {
+	var opts []resolvconf.ConfItem
+	for _, t := range []string{"debug", "rotate", "edns0", "inet6", "use-vc", "single-request", "no-tld-query"} {
+		opts = append(opts, resolvconf.NewOption(t))
+	}
+	// Value options are updated when added again
+	for i := 0; i < 10; i++ {
+		opts = append(opts, resolvconf.NewOption("ndots").Set(i), resolvconf.NewOption("timeout").Set(i), resolvconf.NewOption("attempts").Set(i))
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+		conf.Add(opts...)
+	}
+}
+
+func accessorConf() *resolvconf.Conf {
+	conf := resolvconf.New()
+	conf.Add(bulkItems(9)...)
+	conf.Add(resolvconf.NewOption("rotate"), resolvconf.NewOption("ndots").Set(2))
+	return conf
+}
+
+func BenchmarkGetNameservers(b *testing.B) {
+	conf := accessorConf()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		conf.GetNameservers()
+	}
+}
+
+func BenchmarkGetNameserversInto(b *testing.B) {
+	conf := accessorConf()
+	var dst []resolvconf.Nameserver
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = conf.GetNameserversInto(dst[:0])
+	}
+}
+
+// indexSizes are the item counts of the Add, Find and Remove benchmarks
+var indexSizes = []int{10, 100, 1000}
+
+// BenchmarkAddFind adds each item after finding the previous one, finding
+// an item must not make the next Add walk all items
+func BenchmarkAddFind(b *testing.B) {
+	for _, n := range indexSizes {
+		items := bulkItems(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+				for j, item := range items {
+					conf.Add(item)
+					if j > 0 {
+						conf.Find(items[j-1])
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	for _, n := range indexSizes {
+		items := bulkItems(n)
+		conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+		conf.Add(items...)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				conf.Find(items[i%n])
+			}
+		})
+	}
+}
+
+// BenchmarkRemove removes all items one by one, last first
+func BenchmarkRemove(b *testing.B) {
+	for _, n := range indexSizes {
+		items := bulkItems(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+				conf.Add(items...)
+				b.StartTimer()
+				for j := n - 1; j >= 0; j-- {
+					conf.Remove(items[j])
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSnapshot takes a Snapshot per read like a resolver would, it
+// is cached while the Conf doesn't change
+func BenchmarkSnapshot(b *testing.B) {
+	conf := accessorConf()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		conf.Snapshot().Nameservers()
+	}
+}
+
+func BenchmarkSnapshotParallel(b *testing.B) {
+	conf := accessorConf()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conf.Snapshot().Nameservers()
+		}
+	})
+}
+
+func BenchmarkGetNameserversParallel(b *testing.B) {
+	conf := accessorConf()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conf.GetNameservers()
+		}
+	})
+}
+
+func BenchmarkGetOptionsInto(b *testing.B) {
+	conf := accessorConf()
+	var dst []resolvconf.Option
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = conf.GetOptionsInto(dst[:0])
+	}
+}
+
+func TestAccessorsIntoAllocateAtMostOnce(t *testing.T) {
+	conf := accessorConf()
+	var nss []resolvconf.Nameserver
+	var sis []resolvconf.SortItem
+	var sds []resolvconf.SearchDomain
+	var opts []resolvconf.Option
+	allocs := testing.AllocsPerRun(100, func() {
+		nss = conf.GetNameserversInto(nss[:0])
+		sis = conf.GetSortItemsInto(sis[:0])
+		sds = conf.GetSearchDomainsInto(sds[:0])
+		opts = conf.GetOptionsInto(opts[:0])
+	})
+	assert.Equal(t, 0.0, allocs)
+	allocs = testing.AllocsPerRun(100, func() {
+		conf.GetNameserversInto(make([]resolvconf.Nameserver, 0, 8))
+	})
+	assert.True(t, allocs <= 1)
+	assert.Equal(t, []int{3, 3, 3, 2}, []int{len(nss), len(sis), len(sds), len(opts)})
+}
+
+// benchCorpus is a typical resolv.conf
+const benchCorpus = `# Generated by NetworkManager
+domain corp.example
+search corp.example lab.corp.example example.com
+nameserver 10.0.0.1
+nameserver 10.0.0.2
+nameserver 2001:db8::1
+sortlist 130.155.160.0/255.255.240.0 130.155.0.0
+options ndots:2 timeout:3 attempts:2 rotate edns0
+`
+
+func BenchmarkReadConf(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchCorpus)))
+	for i := 0; i < b.N; i++ {
+		if _, err := resolvconf.ReadConf(strings.NewReader(benchCorpus)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRetainedConf reports the heap kept alive by a parsed Conf, as
+// when many are held in memory at once
+func BenchmarkRetainedConf(b *testing.B) {
+	confs := make([]*resolvconf.Conf, b.N)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := range confs {
+		confs[i], _ = resolvconf.ReadConf(strings.NewReader(benchCorpus))
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N), "retained-B/conf")
+	runtime.KeepAlive(confs)
+}
+
+func BenchmarkString(b *testing.B) {
+	conf, err := resolvconf.ReadConf(strings.NewReader(benchCorpus))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = conf.String()
+	}
+}
+
+func BenchmarkWriteFiltered(b *testing.B) {
+	conf, err := resolvconf.ReadConf(strings.NewReader(benchCorpus))
+	if err != nil {
+		b.Fatal(err)
+	}
+	keep := resolvconf.FilterNameservers(func(resolvconf.Nameserver) bool { return true })
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		conf.Write(&buf, keep)
+	}
+}
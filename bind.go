@@ -0,0 +1,56 @@
+package resolvconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BindOption customizes ToBindForwarders
+type BindOption func(o *bindOptions)
+
+type bindOptions struct {
+	bare bool
+}
+
+// BindBareBlock renders only the forwarders block, without the options
+// wrapper, so it can be spliced into an existing options clause
+func BindBareBlock() BindOption {
+	return func(o *bindOptions) {
+		o.bare = true
+	}
+}
+
+// ToBindForwarders renders a BIND options clause forwarding to the
+// nameservers of the configuration. Ports other than 53 are rendered as
+// "addr port N". An error is returned if there are no nameservers
+func (conf *Conf) ToBindForwarders(opts ...BindOption) (string, error) {
+	o := &bindOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	nss := conf.GetNameservers()
+	if len(nss) == 0 {
+		return "", fmt.Errorf("No nameservers to forward to")
+	}
+
+	indent := "\t"
+	var b strings.Builder
+	if !o.bare {
+		b.WriteString("options {\n")
+		b.WriteString(indent)
+		indent += "\t"
+	}
+	b.WriteString("forwarders {\n")
+	for _, ns := range nss {
+		b.WriteString(indent + ns.IP.String())
+		if ns.Port != 0 && ns.Port != DefaultPort {
+			fmt.Fprintf(&b, " port %d", ns.Port)
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString(indent[1:] + "};\n")
+	if !o.bare {
+		b.WriteString("};\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,22 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestToBindForwarders(t *testing.T) {
+	out, err := exportConf(t).ToBindForwarders()
+	assert.Nil(t, err)
+	assert.Equal(t, "options {\n\tforwarders {\n\t\t10.0.0.1;\n\t\t2001:db8::1 port 5353;\n\t};\n};\n", out)
+
+	out, err = exportConf(t).ToBindForwarders(resolvconf.BindBareBlock())
+	assert.Nil(t, err)
+	assert.Equal(t, "forwarders {\n\t10.0.0.1;\n\t2001:db8::1 port 5353;\n};\n", out)
+}
+
+func TestToBindForwardersNoNameservers(t *testing.T) {
+	_, err := resolvconf.New().ToBindForwarders()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,124 @@
+package resolvconf
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Op is the kind of change reported to the callbacks of OnChange
+type Op int
+
+// Ops
+const (
+	OpAdd    Op = iota // The item was added
+	OpRemove           // The item was removed
+	OpUpdate           // The item replaced one of its kind, got a new value or moved
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpAdd:
+		return "add"
+	case OpRemove:
+		return "remove"
+	case OpUpdate:
+		return "update"
+	}
+	return "unknown"
+}
+
+type change struct {
+	op   Op
+	item ConfItem
+}
+
+type changeHook struct {
+	fn        func(Op, ConfItem)
+	cancelled bool
+}
+
+// changeHooks holds the callbacks of OnChange and the changes made while
+// the Conf is locked, they are reported once it is unlocked. It is shared
+// by copies of the Conf made by value receivers
+type changeHooks struct {
+	mu      sync.Mutex
+	n       int32 // Number of hooks, read without the lock
+	hooks   []*changeHook
+	pending []change
+}
+
+// OnChange registers fn to be called after every change to the items, one
+// call per item added, removed or updated, e.g. a domain replacing another
+// or an option getting a new value. Rejected items are not reported. fn is
+// called synchronously once the Conf is unlocked, it sees the Conf in its
+// new state and may call its methods. Changes made to items returned by
+// Find are not seen, neither are those of clones.
+//
+// The returned func removes fn again, it may be called from fn
+func (conf *Conf) OnChange(fn func(op Op, item ConfItem)) (cancel func()) {
+	if conf.hooks == nil {
+		// Zero Conf
+		conf.hooks = new(changeHooks)
+	}
+	h := conf.hooks
+	hook := &changeHook{fn: fn}
+	h.mu.Lock()
+	h.hooks = append(h.hooks, hook)
+	atomic.StoreInt32(&h.n, int32(len(h.hooks)))
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, o := range h.hooks {
+			if o == hook {
+				hook.cancelled = true
+				h.hooks = append(h.hooks[:i:i], h.hooks[i+1:]...)
+				atomic.StoreInt32(&h.n, int32(len(h.hooks)))
+				return
+			}
+		}
+	}
+}
+
+// changed records a change of the items, the Conf must be locked
+func (conf *Conf) changed(op Op, item ConfItem) {
+	h := conf.hooks
+	if h == nil || atomic.LoadInt32(&h.n) == 0 {
+		return
+	}
+	h.mu.Lock()
+	h.pending = append(h.pending, change{op, item})
+	h.mu.Unlock()
+}
+
+// take returns the recorded changes and forgets them
+func (h *changeHooks) take() []change {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	changes := h.pending
+	h.pending = nil
+	return changes
+}
+
+// notify calls the hooks for changes, hooks cancelled meanwhile are skipped
+func (h *changeHooks) notify(changes []change) {
+	if len(changes) == 0 {
+		return
+	}
+	h.mu.Lock()
+	hooks := append([]*changeHook(nil), h.hooks...)
+	h.mu.Unlock()
+	for _, c := range changes {
+		for _, hook := range hooks {
+			h.mu.Lock()
+			cancelled := hook.cancelled
+			h.mu.Unlock()
+			if !cancelled {
+				hook.fn(c.op, c.item)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+type changeEntry struct {
+	op   resolvconf.Op
+	item string
+}
+
+func recordChanges(conf *resolvconf.Conf) (*[]changeEntry, func()) {
+	var changes []changeEntry
+	cancel := conf.OnChange(func(op resolvconf.Op, item resolvconf.ConfItem) {
+		changes = append(changes, changeEntry{op, item.String()})
+	})
+	return &changes, cancel
+}
+
+func TestOnChange(t *testing.T) {
+	conf := resolvconf.New()
+	changes, cancel := recordChanges(conf)
+	ns := resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))
+	conf.Add(ns, resolvconf.NewDomain("a.com"), resolvconf.NewOption("ndots").Set(2))
+	conf.Add(resolvconf.NewDomain("b.com"), resolvconf.NewOption("ndots").Set(3), resolvconf.NewOption("ndots").Set(3))
+	conf.Remove(ns)
+	assert.Equal(t, []changeEntry{
+		{resolvconf.OpAdd, "10.0.0.1"},
+		{resolvconf.OpAdd, "a.com"},
+		{resolvconf.OpAdd, "ndots:2"},
+		{resolvconf.OpUpdate, "b.com"},
+		{resolvconf.OpUpdate, "ndots:3"},
+		{resolvconf.OpRemove, "10.0.0.1"},
+	}, *changes)
+	assert.Equal(t, "update", resolvconf.OpUpdate.String())
+
+	// Rejected items and failed strict adds are not reported
+	*changes = nil
+	conf.Add(ns, ns, resolvconf.NewNameserver(nil), resolvconf.NewOption("ndots").Set(-5))
+	conf.Remove(resolvconf.NewSearchDomain("c.com"))
+	conf.AddStrict(resolvconf.NewSearchDomain("c.com"), resolvconf.NewNameserver(nil))
+	assert.Equal(t, []changeEntry{{resolvconf.OpAdd, "10.0.0.1"}}, *changes)
+
+	*changes = nil
+	cancel()
+	conf.Add(resolvconf.NewSearchDomain("c.com"))
+	assert.Nil(t, *changes)
+}
+
+func TestOnChangeSeesNewState(t *testing.T) {
+	conf := resolvconf.New()
+	var seen [][]resolvconf.Nameserver
+	conf.OnChange(func(op resolvconf.Op, item resolvconf.ConfItem) {
+		seen = append(seen, conf.GetNameservers())
+	})
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	assert.Equal(t, 2, len(seen))
+	for _, nss := range seen {
+		assert.Equal(t, 2, len(nss))
+	}
+}
+
+func TestOnChangeCancelFromCallback(t *testing.T) {
+	conf := resolvconf.New()
+	n := 0
+	var cancel func()
+	cancel = conf.OnChange(func(op resolvconf.Op, item resolvconf.ConfItem) {
+		n++
+		cancel()
+	})
+	changes, _ := recordChanges(conf)
+	conf.Add(resolvconf.NewSearchDomain("a.com"), resolvconf.NewSearchDomain("b.com"))
+	conf.Add(resolvconf.NewSearchDomain("c.com"))
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 3, len(*changes))
+}
+
+func TestOnChangeBulkChanges(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	changes, _ := recordChanges(conf)
+	assert.Nil(t, conf.PromoteNameserver(net.ParseIP("10.0.0.2")))
+	assert.Equal(t, []changeEntry{{resolvconf.OpUpdate, "10.0.0.2"}, {resolvconf.OpUpdate, "10.0.0.1"}}, *changes)
+
+	*changes = nil
+	conf.Clear()
+	assert.Equal(t, []changeEntry{{resolvconf.OpRemove, "10.0.0.2"}, {resolvconf.OpRemove, "10.0.0.1"}}, *changes)
+
+	// Clones don't share the callbacks
+	*changes = nil
+	conf.Clone().Add(resolvconf.NewDomain("a.com"))
+	assert.Nil(t, *changes)
+}
@@ -0,0 +1,156 @@
+package resolvconf
+
+import (
+	"strings"
+)
+
+// Comment is a comment line, or the comment at the end of the line of the
+// item before it if Trailing is set. Text starts with the # or ; marker
+type Comment struct {
+	Text     string
+	Trailing bool
+}
+
+// NewComment creates a comment line, text is prefixed with "# " unless it
+// starts with a comment marker
+func NewComment(text string) *Comment {
+	if !strings.HasPrefix(text, "#") && !strings.HasPrefix(text, ";") {
+		text = "# " + text
+	}
+	return &Comment{Text: text}
+}
+
+func (c Comment) applyLimits(conf *Conf) (bool, error) {
+	// Comments may repeat
+	return true, nil
+}
+
+func (c Comment) String() string {
+	return c.Text
+}
+
+// Equal compares two comments with each other, returns true if equal
+func (c Comment) Equal(b ConfItem) bool {
+	if item, ok := b.(*Comment); ok {
+		return c == *item
+	}
+	return false
+}
+
+// RawLine is a line ReadConf didn't recognize, e.g. a keyword of another
+// resolver like lookup. It is written back as is
+type RawLine struct {
+	Text string
+}
+
+// NewRawLine creates a line that is written as is
+func NewRawLine(text string) *RawLine {
+	return &RawLine{text}
+}
+
+func (rl RawLine) applyLimits(conf *Conf) (bool, error) {
+	return true, nil
+}
+
+func (rl RawLine) String() string {
+	return rl.Text
+}
+
+// Equal compares two raw lines with each other, returns true if equal
+func (rl RawLine) Equal(b ConfItem) bool {
+	if item, ok := b.(*RawLine); ok {
+		return rl.Text == item.Text
+	}
+	return false
+}
+
+// GetComments returns a list of all comments
+func (conf *Conf) GetComments() []Comment {
+	conf.rlock()
+	defer conf.runlock()
+	var res []Comment
+	for _, item := range conf.items {
+		if c, ok := item.(*Comment); ok {
+			res = append(res, *c)
+		}
+	}
+	return res
+}
+
+// GetRawLines returns a list of all raw lines
+func (conf *Conf) GetRawLines() []RawLine {
+	conf.rlock()
+	defer conf.runlock()
+	var res []RawLine
+	for _, item := range conf.items {
+		if rl, ok := item.(*RawLine); ok {
+			res = append(res, *rl)
+		}
+	}
+	return res
+}
+
+// RemoveComments removes all comments, also those at the end of lines, and
+// returns how many there were
+func (conf *Conf) RemoveComments() int {
+	return conf.removeIf(func(item ConfItem) bool {
+		_, ok := item.(*Comment)
+		return ok
+	})
+}
+
+// RemoveRawLines removes all lines ReadConf didn't recognize and returns
+// how many there were
+func (conf *Conf) RemoveRawLines() int {
+	return conf.removeIf(func(item ConfItem) bool {
+		_, ok := item.(*RawLine)
+		return ok
+	})
+}
+
+// removeIf removes the items drop returns true for and returns how many,
+// the comment at the end of the line of a removed item goes with it
+func (conf *Conf) removeIf(drop func(ConfItem) bool) int {
+	conf.lock()
+	defer conf.unlock()
+	return conf.dropIf(drop)
+}
+
+// dropIf is removeIf without locking
+func (conf *Conf) dropIf(drop func(ConfItem) bool) int {
+	n := 0
+	dropped := false
+	kept := conf.items[:0]
+	for _, item := range conf.items {
+		if c, ok := item.(*Comment); ok && c.Trailing && dropped {
+			conf.changed(OpRemove, item)
+			continue
+		}
+		if dropped = drop(item); dropped {
+			n++
+			conf.changed(OpRemove, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == len(conf.items) {
+		return 0
+	}
+	for i := len(kept); i < len(conf.items); i++ {
+		conf.items[i] = nil
+	}
+	conf.setItems(kept)
+	return n
+}
+
+// hasLines returns true if items has comments or raw lines, a Conf is
+// then written in item order so these stay in place
+func hasLines(items []ConfItem) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case *Comment, *RawLine:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+const commentedConf = `# Generated by NetworkManager
+search example.com # office
+nameserver 10.0.0.1
+; fallback
+nameserver 10.0.0.2   # backup
+lookup file bind
+options ndots:2
+`
+
+func TestCommentsSurviveRoundTrip(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader(commentedConf))
+	assert.NotNil(t, err) // lookup is not a known keyword
+	assert.Equal(t, "# Generated by NetworkManager\n"+
+		"search example.com # office\n"+
+		"nameserver 10.0.0.1\n"+
+		"; fallback\n"+
+		"nameserver 10.0.0.2 # backup\n"+
+		"lookup file bind\n"+
+		"options ndots:2\n", conf.String())
+	assert.Equal(t, []resolvconf.Comment{{"# Generated by NetworkManager", false}, {"# office", true},
+		{"; fallback", false}, {"# backup", true}}, conf.GetComments())
+	assert.Equal(t, []resolvconf.RawLine{{"lookup file bind"}}, conf.GetRawLines())
+
+	// Items of merged lines join the line, others are written last
+	conf.Add(resolvconf.NewSearchDomain("lab.example"), resolvconf.NewOption("rotate"), resolvconf.NewDomain("example.com"))
+	assert.Nil(t, conf.Remove(resolvconf.NewNameserver(net.ParseIP("10.0.0.2"))))
+	assert.Equal(t, "# Generated by NetworkManager\n"+
+		"search example.com lab.example # office\n"+
+		"nameserver 10.0.0.1\n"+
+		"; fallback\n"+
+		"lookup file bind\n"+
+		"options ndots:2 rotate\n"+
+		"domain example.com\n", conf.String())
+}
+
+func TestTrailingCommentOfFilteredNameserver(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 127.0.0.53 # stub\nnameserver 10.0.0.1 ; upstream\n"))
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, conf.Write(&buf, resolvconf.FilterNameservers(func(ns resolvconf.Nameserver) bool {
+		return !ns.IP.IsLoopback()
+	})))
+	assert.Equal(t, "nameserver 10.0.0.1 ; upstream\n", buf.String())
+}
+
+func TestOnlyComments(t *testing.T) {
+	in := "# nothing here\n\n;  yet\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Nil(t, err)
+	assert.Equal(t, "# nothing here\n;  yet\n", conf.String())
+	assert.Equal(t, 0, len(conf.GetNameservers()))
+}
+
+func TestRemoveComments(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader(commentedConf))
+	assert.Equal(t, 4, conf.RemoveComments())
+	assert.Equal(t, 1, conf.RemoveRawLines())
+	assert.Nil(t, conf.GetComments())
+	assert.Equal(t, 0, conf.RemoveComments())
+	assert.Equal(t, 0, conf.RemoveRawLines())
+	assert.Equal(t, "nameserver 10.0.0.1\nnameserver 10.0.0.2\n\nsearch example.com\n\noptions ndots:2\n\n", conf.String())
+
+	// Single comments are removed like any other item
+	conf.Add(resolvconf.NewComment("managed by hand"), resolvconf.NewComment("; twice"), resolvconf.NewComment("; twice"))
+	assert.Equal(t, "# managed by hand", conf.GetComments()[0].Text)
+	assert.Nil(t, conf.Remove(resolvconf.NewComment("; twice")))
+	assert.Equal(t, 2, len(conf.GetComments()))
+}
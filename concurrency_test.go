@@ -0,0 +1,111 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Run with -race
+func TestConcurrentAddGetWrite(t *testing.T) {
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	var wg sync.WaitGroup
+	const n = 200
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			ip := net.IPv4(10, 0, byte(i/250), byte(i%250+1))
+			conf.Add(resolvconf.NewNameserver(ip), resolvconf.NewOption("ndots").Set(i%15))
+			if i%3 == 0 {
+				conf.Remove(resolvconf.NewNameserver(ip))
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			for _, ns := range conf.GetNameservers() {
+				assert.NotNil(t, ns.IP)
+			}
+			conf.GetOptions()
+			conf.Find(resolvconf.NewOption("ndots"))
+			conf.Validate()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			var buf bytes.Buffer
+			assert.Nil(t, conf.Write(&buf))
+			// Every line is whole, Add and Write don't interleave
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				assert.True(t, line == "" || strings.HasPrefix(line, "nameserver 10.0.") || strings.HasPrefix(line, "options ndots:"), line)
+			}
+			_ = conf.String()
+			conf.Clone()
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, n-n/3-1, len(conf.GetNameservers()))
+}
+
+func TestConcurrentFind(t *testing.T) {
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	var wg sync.WaitGroup
+	const n = 200
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			ip := net.IPv4(10, 0, byte(i/250), byte(i%250+1))
+			conf.Add(resolvconf.NewNameserver(ip), resolvconf.NewSearchDomain("a.com"))
+			if i%2 == 0 {
+				conf.Remove(resolvconf.NewNameserver(ip), resolvconf.NewSearchDomain("a.com"))
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			ip := net.IPv4(10, 0, byte(i/250), byte(i%250+1))
+			if ns, ok := conf.Find(resolvconf.NewNameserver(ip)).(*resolvconf.Nameserver); ok {
+				assert.NotNil(t, ns)
+			}
+			conf.Find(resolvconf.NewSearchDomain("a.com"))
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, n/2, len(conf.GetNameservers()))
+}
+
+func TestConcurrentAllowUnknownOptions(t *testing.T) {
+	conf := resolvconf.New()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			conf.SetAllowUnknownOptions(i%2 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			conf.Add(&resolvconf.Option{Type: "x-private", Value: -1})
+			_ = conf.String()
+		}
+	}()
+	wg.Wait()
+
+	// The cached output follows the setting
+	conf.SetAllowUnknownOptions(true)
+	conf.Add(&resolvconf.Option{Type: "x-private", Value: -1})
+	assert.Contains(t, conf.String(), "x-private")
+	conf.SetAllowUnknownOptions(false)
+	assert.NotContains(t, conf.String(), "options x-private")
+}
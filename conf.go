@@ -1,14 +1,14 @@
 package resolvconf
 
 import (
-	"io/ioutil"
-	"log"
+	"net"
+	"sync"
 )
 
 // Limits
 const (
-	searchDomainMaxCount     = 6   // Maximum count of search domains
-	searchDomainMaxCharCount = 256 // Maximum total number of chars in search domains
+	searchDomainMaxCount     = 6   // Maximum count of search domains with SearchLegacy
+	searchDomainMaxCharCount = 256 // Maximum length of the search list with SearchLegacy
 	nameserversMaxCount      = 3   // Maximum number of nameservers
 	sortListMaxCount         = 10  // Maximum number of items in sortlist
 	optionNdotsMax           = 15  // Maximum ndots value, silently capped
@@ -16,43 +16,275 @@ const (
 	optionAttemptsMax        = 5   // Maximum attempts value, silently capped
 )
 
-// Conf represents a configuration object
+// Resolver defaults used when an option is not set, see resolv.conf(5)
+const (
+	defaultNdots    = 1
+	defaultTimeout  = 5
+	defaultAttempts = 2
+)
+
+// Profile selects the set of limits enforced when items are added
+type Profile int
+
+// Profiles
+const (
+	// ProfileGlibc enforces the limits documented in resolv.conf(5), this
+	// is the default
+	ProfileGlibc Profile = iota
+	// ProfileNone enforces no count limits, useful when building a Conf
+	// from a source that isn't read by libc, e.g. systemd-resolved
+	ProfileNone
+)
+
+// Conf represents a configuration object. A Conf made by New or read by
+// ReadConf is safe for concurrent use, e.g. Add in one goroutine and Write
+// in another, Write always sees the items of a whole Add or Remove call.
+// Items returned by Find must not be modified while the Conf is in use by
+// other goroutines, settings like SetPolicy should be made before sharing
+// it
 type Conf struct {
-	items  []ConfItem
-	logger *log.Logger
+	mu       *sync.RWMutex // nil for a zero Conf, which isn't safe for concurrent use
+	items    []ConfItem
+	idx      *confIndex
+	cache    *renderCache
+	warnings *warningList
+	logger   *confLogger
+	hooks    *changeHooks
+	pos      map[ConfItem]itemPos // Of parsed items
+	reading  itemPos              // Line being parsed
+	source   *confSource          // Set by ReadConfFile
+	parsed   []ParseWarning
+	policy   Policy
+	profile  Profile
+	mode     LimitMode
+	search   SearchPolicy
+	dups     DuplicatePolicy
+	ranges   RangePolicy
+	order    WriteOrder
+	unknown  bool     // Options of unknown type are kept
+	header   []string // Comment lines written first
+	nsLimit  int      // 0 if unlimited
+}
+
+// ConfOption configures a Conf when it is created, by New or by ReadConf
+// and the functions reading files, e.g. WithProfile(ProfileNone) to read a
+// file for OpenBSD
+type ConfOption func(conf *Conf)
+
+func (o ConfOption) applyRead(ro *readOptions) {
+	ro.conf = append(ro.conf, o)
+}
+
+func (o ConfOption) applyFile(fo *fileOptions) {
+	fo.read.conf = append(fo.read.conf, o)
+}
+
+// WithProfile sets the limit profile for the new Conf
+func WithProfile(p Profile) ConfOption {
+	return func(conf *Conf) {
+		conf.profile = p
+	}
 }
 
 // New creates a new configuration
-func New() *Conf {
-	c := new(Conf)
-	c.logger = log.New(ioutil.Discard, "[resolvconf] ", 0)
+func New(opts ...ConfOption) *Conf {
+	c := &Conf{mu: new(sync.RWMutex), idx: &confIndex{dirty: true}, cache: new(renderCache), warnings: new(warningList),
+		hooks: new(changeHooks), nsLimit: nameserversMaxCount}
+	c.logger = newConfLogger()
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
+// lock locks the Conf for changing the items. Methods holding the lock
+// must not call methods taking it, they use the unexported variants
+func (conf *Conf) lock() {
+	if conf.mu != nil {
+		conf.mu.Lock()
+	}
+}
+
+// unlock unlocks the Conf and then reports the changes made to the
+// callbacks of OnChange
+func (conf *Conf) unlock() {
+	changes := conf.hooks.take()
+	if conf.mu != nil {
+		conf.mu.Unlock()
+	}
+	conf.hooks.notify(changes)
+}
+
+// rlock locks the Conf for reading the items
+func (conf *Conf) rlock() {
+	if conf.mu != nil {
+		conf.mu.RLock()
+	}
+}
+
+func (conf *Conf) runlock() {
+	if conf.mu != nil {
+		conf.mu.RUnlock()
+	}
+}
+
+// GetProfile returns the limit profile of the Conf
+func (conf *Conf) GetProfile() Profile {
+	return conf.profile
+}
+
+// limited returns true if count limits should be enforced
+func (conf *Conf) limited() bool {
+	return conf.profile != ProfileNone
+}
+
+// LimitMode tells what Add does with items beyond a count limit
+type LimitMode int
+
+// Limit modes
+const (
+	// LimitStrict rejects items beyond a limit, this is the default
+	LimitStrict LimitMode = iota
+	// LimitPermissive accepts items beyond the nameserver limit and IPv6
+	// sortlist pairs with a warning, e.g. for files read by resolvers
+	// other than glibc which ignores them
+	LimitPermissive
+)
+
+// SetLimitMode sets what Add does with nameservers beyond the limit
+func (conf *Conf) SetLimitMode(m LimitMode) {
+	conf.mode = m
+}
+
+// GetLimitMode returns the current limit mode
+func (conf *Conf) GetLimitMode() LimitMode {
+	return conf.mode
+}
+
+// DuplicatePolicy tells what Add does with a nameserver or search domain
+// that is already present
+type DuplicatePolicy int
+
+// Duplicate policies
+const (
+	// DuplicateError rejects the item with ErrDuplicateItem, this is the
+	// default
+	DuplicateError DuplicatePolicy = iota
+	// DuplicateIgnore skips the item without an error
+	DuplicateIgnore
+	// DuplicateAllow adds the item again, like glibc does with a file
+	// listing it twice. Remove removes one of them at a time
+	DuplicateAllow
+)
+
+// SetDuplicatePolicy sets what Add does with nameservers and search
+// domains already present, use WithDuplicates for the parser
+func (conf *Conf) SetDuplicatePolicy(p DuplicatePolicy) {
+	conf.dups = p
+}
+
+// GetDuplicatePolicy returns the current duplicate policy
+func (conf *Conf) GetDuplicatePolicy() DuplicatePolicy {
+	return conf.dups
+}
+
+// duplicate tells if item is a duplicate to be rejected or skipped
+func (conf *Conf) duplicate(item ConfItem) bool {
+	return conf.dups != DuplicateAllow && conf.lookup(item) != nil
+}
+
+// RangePolicy tells what Add does with an option value beyond the maximum
+// documented in resolv.conf(5), ndots 15, timeout 30 and attempts 5
+type RangePolicy int
+
+// Range policies
+const (
+	// RangeClamp caps the value at the maximum with a WarnValueCapped
+	// warning, like glibc silently does. This is the default
+	RangeClamp RangePolicy = iota
+	// RangeReject rejects the option with ErrInvalidValue
+	RangeReject
+)
+
+// SetRangePolicy sets what Add does with option values beyond their
+// maximum, use WithRangePolicy for the parser
+func (conf *Conf) SetRangePolicy(p RangePolicy) {
+	conf.ranges = p
+}
+
+// GetRangePolicy returns the current range policy
+func (conf *Conf) GetRangePolicy() RangePolicy {
+	return conf.ranges
+}
+
+// SetNameserverLimit sets the maximum number of nameservers, 0 for no
+// limit. The default is 3, like MAXNS of glibc. Nameservers already added
+// are kept
+func (conf *Conf) SetNameserverLimit(n int) {
+	if n < 0 {
+		n = 0
+	}
+	conf.nsLimit = n
+}
+
+// GetNameserverLimit returns the maximum number of nameservers, 0 if
+// there is no limit
+func (conf *Conf) GetNameserverLimit() int {
+	return conf.nsLimit
+}
+
 // GetNameservers returns a list of all added nameservers
 func (conf *Conf) GetNameservers() []Nameserver {
-	var ret []Nameserver
+	return conf.GetNameserversInto(nil)
+}
+
+// GetNameserversInto appends all added nameservers to dst and returns the
+// extended slice, reusing dst avoids allocating
+func (conf *Conf) GetNameserversInto(dst []Nameserver) []Nameserver {
+	conf.rlock()
+	defer conf.runlock()
+	return conf.nameserversInto(dst)
+}
+
+func (conf *Conf) nameserversInto(dst []Nameserver) []Nameserver {
 	for _, item := range conf.items {
-		if _, ok := item.(*Nameserver); ok {
-			ret = append(ret, *item.(*Nameserver))
+		if ns, ok := item.(*Nameserver); ok {
+			dst = append(dst, *ns)
 		}
 	}
-	return ret
+	return dst
 }
 
 // GetSortItems returns list of all added sortitems
 func (conf *Conf) GetSortItems() []SortItem {
-	var ret []SortItem
+	return conf.GetSortItemsInto(nil)
+}
+
+// GetSortItemsInto appends all added sortitems to dst and returns the
+// extended slice, reusing dst avoids allocating
+func (conf *Conf) GetSortItemsInto(dst []SortItem) []SortItem {
+	conf.rlock()
+	defer conf.runlock()
+	return conf.sortItemsInto(dst)
+}
+
+func (conf *Conf) sortItemsInto(dst []SortItem) []SortItem {
 	for _, item := range conf.items {
-		if _, ok := item.(*SortItem); ok {
-			ret = append(ret, *item.(*SortItem))
+		if si, ok := item.(*SortItem); ok {
+			dst = append(dst, *si)
 		}
 	}
-	return ret
+	return dst
 }
 
 // GetDomain returns current domain
 func (conf *Conf) GetDomain() Domain {
+	conf.rlock()
+	defer conf.runlock()
+	return conf.domain()
+}
+
+func (conf *Conf) domain() Domain {
 	for _, item := range conf.items {
 		if d, ok := item.(*Domain); ok {
 			return *d
@@ -63,22 +295,143 @@ func (conf *Conf) GetDomain() Domain {
 
 // GetSearchDomains returns a list of all added SearchDomains
 func (conf *Conf) GetSearchDomains() []SearchDomain {
-	var ret []SearchDomain
+	return conf.GetSearchDomainsInto(nil)
+}
+
+// GetSearchDomainsInto appends all added search domains to dst and returns
+// the extended slice, reusing dst avoids allocating
+func (conf *Conf) GetSearchDomainsInto(dst []SearchDomain) []SearchDomain {
+	conf.rlock()
+	defer conf.runlock()
+	return conf.searchDomainsInto(dst)
+}
+
+func (conf *Conf) searchDomainsInto(dst []SearchDomain) []SearchDomain {
 	for _, item := range conf.items {
-		if _, ok := item.(*SearchDomain); ok {
-			ret = append(ret, *item.(*SearchDomain))
+		if sd, ok := item.(*SearchDomain); ok {
+			dst = append(dst, *sd)
 		}
 	}
-	return ret
+	return dst
 }
 
 // GetOptions returns a list of all added options
 func (conf *Conf) GetOptions() []Option {
-	var ret []Option
+	return conf.GetOptionsInto(nil)
+}
+
+// GetOptionsInto appends all added options to dst and returns the extended
+// slice, reusing dst avoids allocating
+func (conf *Conf) GetOptionsInto(dst []Option) []Option {
+	conf.rlock()
+	defer conf.runlock()
+	return conf.optionsInto(dst)
+}
+
+func (conf *Conf) optionsInto(dst []Option) []Option {
 	for _, item := range conf.items {
-		if _, ok := item.(*Option); ok {
-			ret = append(ret, *item.(*Option))
+		if opt, ok := item.(*Option); ok {
+			dst = append(dst, *opt)
 		}
 	}
-	return ret
+	return dst
+}
+
+// Items returns copies of all items in the order they were read or added,
+// comments and raw lines included. Write keeps this order with
+// OrderInsertion, and with the default OrderAuto if there are comments or
+// raw lines, otherwise it writes the items grouped by kind, each kind in
+// this order, see WriteOrder. Changing the items returned doesn't change
+// the configuration, use Find for that
+func (conf *Conf) Items() []ConfItem {
+	return conf.ItemsInto(nil)
+}
+
+// ItemsInto appends copies of all items to dst like Items and returns the
+// extended slice, reusing dst avoids allocating it
+func (conf *Conf) ItemsInto(dst []ConfItem) []ConfItem {
+	conf.rlock()
+	defer conf.runlock()
+	for _, item := range conf.items {
+		dst = append(dst, cloneItem(item))
+	}
+	return dst
+}
+
+// Clone returns a deep copy of the configuration, changing the items of
+// one doesn't change the other. The copy logs to the same logger but has
+// its own log level and no warnings
+func (conf *Conf) Clone() *Conf {
+	conf.rlock()
+	defer conf.runlock()
+	return conf.clone()
+}
+
+// clone is Clone without locking
+func (conf *Conf) clone() *Conf {
+	c := *conf
+	c.mu = new(sync.RWMutex)
+	c.idx = &confIndex{dirty: true}
+	c.cache = new(renderCache)
+	c.warnings = new(warningList)
+	c.hooks = new(changeHooks)
+	if conf.logger != nil {
+		l := *conf.logger
+		c.logger = &l
+	}
+	c.items = make([]ConfItem, len(conf.items))
+	c.pos = nil
+	for i, item := range conf.items {
+		c.items[i] = cloneItem(item)
+		if !isPointer(item) {
+			continue
+		}
+		if p, ok := conf.pos[item]; ok {
+			if c.pos == nil {
+				c.pos = make(map[ConfItem]itemPos, len(conf.pos))
+			}
+			c.pos[c.items[i]] = p
+		}
+	}
+	return &c
+}
+
+func cloneIP(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	return append(net.IP(nil), ip...)
+}
+
+func cloneItem(item ConfItem) ConfItem {
+	switch it := item.(type) {
+	case *Nameserver:
+		ns := *it
+		ns.IP = cloneIP(it.IP)
+		return &ns
+	case *Domain:
+		dom := *it
+		return &dom
+	case *SearchDomain:
+		sd := *it
+		return &sd
+	case *SortItem:
+		si := *it
+		si.Address, si.Netmask = cloneIP(it.Address), cloneIP(it.Netmask)
+		return &si
+	case *Option:
+		opt := *it
+		return &opt
+	case *Lookup:
+		return NewLookup(append([]string(nil), it.Sources...)...)
+	case *Family:
+		return NewFamily(append([]string(nil), it.Families...)...)
+	case *Comment:
+		c := *it
+		return &c
+	case *RawLine:
+		rl := *it
+		return &rl
+	}
+	return item
 }
@@ -11,3 +11,25 @@ type ConfItem interface {
 	applyLimits(conf *Conf) (bool, error)
 	Equal(b ConfItem) bool
 }
+
+// itemLine renders an item the way it would look on its own line in
+// a resolv.conf file
+func itemLine(item ConfItem) string {
+	switch item.(type) {
+	case *Nameserver, Nameserver:
+		return "nameserver " + item.String()
+	case *Domain, Domain:
+		return "domain " + item.String()
+	case *SearchDomain, SearchDomain:
+		return "search " + item.String()
+	case *SortItem, SortItem:
+		return "sortlist " + item.String()
+	case *Option:
+		return "options " + item.String()
+	case *Lookup, Lookup:
+		return "lookup " + item.String()
+	case *Family, Family:
+		return "family " + item.String()
+	}
+	return item.String()
+}
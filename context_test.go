@@ -0,0 +1,72 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Every function taking a context must give up at once if it is already
+// done and report it
+
+func cancelledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestCancelledCheckNameservers(t *testing.T) {
+	silent, stop := fakeDNS(t, -1)
+	defer stop()
+	conf := resolvconf.New()
+	conf.Add(silent)
+	start := time.Now()
+	_, err := conf.CheckNameservers(cancelledContext(), "")
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestCancelledFilterByConnectivity(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	_, err := conf.FilterByConnectivity(cancelledContext())
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestCancelledResolverDial(t *testing.T) {
+	silent, stop := fakeA(t, nil)
+	defer stop()
+	conf := resolvconf.New()
+	conf.Add(silent)
+	_, err := conf.Resolver().Dial(cancelledContext(), "udp", "")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestCancelledRunCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	err := resolvconf.RunCommand("/bin/sh", "-c", "exit 0")(cancelledContext(), "")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestCancelledWriteFileContextSkipsHooks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	called := false
+	conf := resolvconf.New()
+	err := conf.WriteFileContext(cancelledContext(), filepath.Join(dir, "resolv.conf"), 0644,
+		resolvconf.AfterWrite(func(ctx context.Context, path string) error {
+			called = true
+			return nil
+		}))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, called)
+}
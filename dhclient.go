@@ -0,0 +1,271 @@
+package resolvconf
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dhcpLease is the DNS related subset of a dhclient lease
+type dhcpLease struct {
+	servers    []string
+	domainName string
+	search     []string
+	expire     time.Time // Zero if never expires
+}
+
+// FromDHClientLeases builds a Conf from the DNS options of the most recent
+// lease in a dhclient lease file, e.g. /var/lib/dhcp/dhclient.eth0.leases.
+// Expired leases are skipped, if all are expired the newest one is used.
+//
+// domain-name-servers become nameservers, domain-search becomes the search
+// list and domain-name the domain. Like ReadConf the Conf is returned along
+// with the error if some of the items could not be added
+func FromDHClientLeases(r io.Reader) (*Conf, error) {
+	return FromDHClientLeasesAt(r, time.Now())
+}
+
+// FromDHClientLeasesAt is FromDHClientLeases using now to decide which
+// leases are expired
+func FromDHClientLeasesAt(r io.Reader, now time.Time) (*Conf, error) {
+	leases, err := parseLeases(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(leases) == 0 {
+		return nil, fmt.Errorf("No lease found")
+	}
+
+	// Leases are appended to the file, last valid one is the most recent
+	var lease *dhcpLease
+	for i := len(leases) - 1; i >= 0; i-- {
+		if leases[i].expire.IsZero() || leases[i].expire.After(now) {
+			lease = &leases[i]
+			break
+		}
+	}
+	if lease == nil {
+		lease = &leases[len(leases)-1]
+		for i := range leases {
+			if !leases[i].expire.Before(lease.expire) {
+				lease = &leases[i]
+			}
+		}
+	}
+
+	var res *multierror.Error
+	conf := New()
+	for _, s := range lease.servers {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			res = multierror.Append(res, fmt.Errorf("%w: malformed IP address: %s", ErrInvalidValue, s))
+			continue
+		}
+		if err := conf.Add(NewNameserver(ip)); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	search := lease.search
+	doms := strings.Fields(lease.domainName)
+	if len(doms) > 0 {
+		conf.Add(NewDomain(strings.TrimSuffix(doms[0], ".")))
+		// Some servers send the search list as domain-name
+		if len(search) == 0 && len(doms) > 1 {
+			search = doms
+		}
+	}
+	for _, s := range search {
+		if err := conf.Add(NewSearchDomain(strings.TrimSuffix(s, "."))); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	return conf, res.ErrorOrNil()
+}
+
+func parseLeases(r io.Reader) ([]dhcpLease, error) {
+	toks, err := tokenizeLeases(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var leases []dhcpLease
+	var cur *dhcpLease
+	depth := 0
+	var stmt []string
+	for _, tok := range toks {
+		if !tok.punct {
+			stmt = append(stmt, tok.text)
+			continue
+		}
+		switch tok.text {
+		case "{":
+			if depth == 0 && len(stmt) == 1 && stmt[0] == "lease" {
+				leases = append(leases, dhcpLease{})
+				cur = &leases[len(leases)-1]
+			}
+			depth++
+			stmt = nil
+		case "}":
+			if depth == 0 {
+				return nil, fmt.Errorf("Unbalanced } in lease file")
+			}
+			depth--
+			if depth == 0 {
+				cur = nil
+			}
+			stmt = nil
+		case ";":
+			if cur != nil && depth == 1 {
+				if err := cur.apply(stmt); err != nil {
+					return nil, err
+				}
+			}
+			stmt = nil
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("Unterminated lease block")
+	}
+	return leases, nil
+}
+
+func (l *dhcpLease) apply(stmt []string) error {
+	if len(stmt) == 0 {
+		return nil
+	}
+	switch stmt[0] {
+	case "option":
+		if len(stmt) < 3 {
+			return nil
+		}
+		vals := stmt[2:]
+		switch stmt[1] {
+		case "domain-name-servers":
+			l.servers = vals
+		case "domain-name":
+			l.domainName = strings.Join(vals, " ")
+		case "domain-search":
+			l.search = vals
+		}
+	case "expire":
+		t, err := parseLeaseTime(stmt[1:])
+		if err != nil {
+			return err
+		}
+		l.expire = t
+	}
+	return nil
+}
+
+// parseLeaseTime parses the time formats used by dhclient, either
+// "W YYYY/MM/DD HH:MM:SS" in UTC, "epoch N" or "never"
+func parseLeaseTime(toks []string) (time.Time, error) {
+	switch {
+	case len(toks) == 1 && toks[0] == "never":
+		return time.Time{}, nil
+	case len(toks) == 2 && toks[0] == "epoch":
+		secs, err := strconv.ParseInt(toks[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Malformed lease time epoch %s", toks[1])
+		}
+		return time.Unix(secs, 0).UTC(), nil
+	case len(toks) == 3:
+		t, err := time.Parse("2006/01/02 15:04:05", toks[1]+" "+toks[2])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Malformed lease time %s", strings.Join(toks, " "))
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Malformed lease time %s", strings.Join(toks, " "))
+}
+
+type leaseToken struct {
+	text  string
+	punct bool // One of { } ; ,
+}
+
+// tokenizeLeases splits a lease file into words, strings (unquoted and
+// unescaped) and the punctuation { } ; ,
+func tokenizeLeases(r io.Reader) ([]leaseToken, error) {
+	var toks []leaseToken
+	br := bufio.NewReader(r)
+	var word []byte
+	flush := func() {
+		if len(word) > 0 {
+			toks = append(toks, leaseToken{string(word), false})
+			word = nil
+		}
+	}
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			flush()
+			return toks, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			flush()
+		case '{', '}', ';', ',':
+			flush()
+			toks = append(toks, leaseToken{string(c), true})
+		case '#':
+			flush()
+			if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+		case '"':
+			flush()
+			s, err := readLeaseString(br)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, leaseToken{s, false})
+		default:
+			word = append(word, c)
+		}
+	}
+}
+
+// readLeaseString reads a quoted string after the opening quote, handling
+// the \ddd octal and \c escapes written by dhclient
+func readLeaseString(br *bufio.Reader) (string, error) {
+	var s []byte
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("Unterminated string in lease file")
+		}
+		switch c {
+		case '"':
+			return string(s), nil
+		case '\\':
+			c, err = br.ReadByte()
+			if err != nil {
+				return "", fmt.Errorf("Unterminated string in lease file")
+			}
+			if c >= '0' && c <= '7' {
+				oct := []byte{c}
+				for len(oct) < 3 {
+					if n, err := br.Peek(1); err == nil && n[0] >= '0' && n[0] <= '7' {
+						br.ReadByte()
+						oct = append(oct, n[0])
+					} else {
+						break
+					}
+				}
+				v, _ := strconv.ParseUint(string(oct), 8, 8)
+				c = byte(v)
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readLeases(t *testing.T, now time.Time) *resolvconf.Conf {
+	f, err := os.Open("testdata/dhclient.eth0.leases")
+	assert.Nil(t, err)
+	defer f.Close()
+	conf, err := resolvconf.FromDHClientLeasesAt(f, now)
+	assert.Nil(t, err)
+	return conf
+}
+
+func TestDHClientLeaseMostRecent(t *testing.T) {
+	conf := readLeases(t, time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	str, _ := GetConf(conf)
+	assert.Equal(t, "domain corp.example\nnameserver 192.168.1.53\nnameserver 1.1.1.1\n\n"+
+		"search corp.example lab.corp.example\n\n", str)
+}
+
+func TestDHClientAllExpiredUsesNewest(t *testing.T) {
+	conf := readLeases(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "corp.example", conf.GetDomain().Name)
+}
+
+func TestDHClientSkipsExpired(t *testing.T) {
+	leases := `lease {
+  option domain-name-servers 10.0.0.1;
+  expire never;
+}
+lease {
+  option domain-name-servers 10.0.0.2;
+  expire 1 2020/01/01 00:00:00;
+}`
+	conf, err := resolvconf.FromDHClientLeasesAt(strings.NewReader(leases), time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", conf.GetNameservers()[0].String())
+}
+
+func TestDHClientDomainNameAsSearchList(t *testing.T) {
+	leases := `lease { option domain-name "a.example b.example"; }`
+	conf, err := resolvconf.FromDHClientLeases(strings.NewReader(leases))
+	assert.Nil(t, err)
+	assert.Equal(t, "a.example", conf.GetDomain().Name)
+	assert.Equal(t, 2, len(conf.GetSearchDomains()))
+}
+
+func TestDHClientMalformed(t *testing.T) {
+	_, err := resolvconf.FromDHClientLeases(strings.NewReader(`lease { option domain-name "foo`))
+	assert.NotNil(t, err)
+	_, err = resolvconf.FromDHClientLeases(strings.NewReader(`lease { expire 1 2020/13/01 00:00:00; }`))
+	assert.NotNil(t, err)
+	_, err = resolvconf.FromDHClientLeases(strings.NewReader(``))
+	assert.NotNil(t, err)
+	conf, err := resolvconf.FromDHClientLeases(strings.NewReader(`lease { option domain-name-servers 10.0.0; }`))
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(conf.GetNameservers()))
+}
@@ -0,0 +1,212 @@
+package resolvconf
+
+import (
+	"sort"
+	"strings"
+)
+
+// Change is an item that is in both configurations with another value,
+// e.g. an option or the domain
+type Change struct {
+	From ConfItem // Item of the Conf
+	To   ConfItem // Item of the other Conf
+}
+
+// Delta is the difference between two configurations as returned by Diff.
+// The items are copies, changing them doesn't change the configurations
+type Delta struct {
+	Added   []ConfItem // Items of the other Conf not in the Conf
+	Removed []ConfItem // Items of the Conf not in the other Conf
+	Changed []Change
+	Moved   []ConfItem // Items of the other Conf in another order, only with DiffOrder
+}
+
+// IsEmpty returns true if there is no difference
+func (d Delta) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Moved) == 0
+}
+
+// DiffOption customizes how Diff compares configurations
+type DiffOption func(o *diffOptions)
+
+type diffOptions struct {
+	order bool
+}
+
+// DiffOrder makes Diff report nameservers, search domains and sortlist
+// pairs that are in both configurations in another order as moved, the
+// order of these is the order the resolver tries them in
+func DiffOrder() DiffOption {
+	return func(o *diffOptions) {
+		o.order = true
+	}
+}
+
+// Diff compares the Conf with other and returns what changes it into
+// other. Items are matched like by Find, not by position, so by default
+// reordered items are no difference
+func (conf *Conf) Diff(other *Conf, opts ...DiffOption) Delta {
+	var o diffOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var d Delta
+	if other == nil {
+		other = New()
+	} else {
+		// A copy so other isn't locked along with the Conf
+		other = other.Clone()
+	}
+	conf.rlock()
+	defer conf.runlock()
+	byKey := make(map[itemKey][]int)
+	for j, item := range other.items {
+		k := keyOf(item)
+		byKey[k] = append(byKey[k], j)
+	}
+	matched := make([]bool, len(other.items))
+	common := make(map[itemKind][]int) // Matched items of other, in the order of the Conf
+	for _, item := range conf.items {
+		k := keyOf(item)
+		j := -1
+		for _, c := range byKey[k] {
+			// There is only one domain, lookup and family line, a
+			// different one is a change
+			if !matched[c] && (single(k.kind) || item.Equal(other.items[c])) {
+				j = c
+				break
+			}
+		}
+		if j < 0 {
+			d.Removed = append(d.Removed, cloneItem(item))
+			continue
+		}
+		matched[j] = true
+		if !sameValue(item, other.items[j]) {
+			d.Changed = append(d.Changed, Change{cloneItem(item), cloneItem(other.items[j])})
+			continue
+		}
+		common[k.kind] = append(common[k.kind], j)
+	}
+	for j, item := range other.items {
+		if !matched[j] {
+			d.Added = append(d.Added, cloneItem(item))
+		}
+	}
+	if o.order {
+		for _, kind := range []itemKind{kindNameserver, kindSearchDomain, kindSortItem} {
+			idx := common[kind]
+			sorted := append([]int(nil), idx...)
+			sort.Ints(sorted)
+			for p := range idx {
+				if idx[p] != sorted[p] {
+					d.Moved = append(d.Moved, cloneItem(other.items[idx[p]]))
+				}
+			}
+		}
+	}
+	return d
+}
+
+// Equal returns true if both configurations have the same items, compared
+// with the Equal methods of the items and in any order. Comments and raw
+// lines are not compared
+func (conf *Conf) Equal(other *Conf) bool {
+	return conf.equal(other)
+}
+
+// EqualStrict is Equal but also requires the nameservers, search domains
+// and sortlist pairs to be in the same order, as it is the order the
+// resolver tries them in. The order of options doesn't matter
+func (conf *Conf) EqualStrict(other *Conf) bool {
+	return conf.equal(other, DiffOrder())
+}
+
+func (conf *Conf) equal(other *Conf, opts ...DiffOption) bool {
+	d := conf.Diff(other, opts...)
+	if len(d.Changed) > 0 || len(d.Moved) > 0 {
+		return false
+	}
+	for _, item := range append(d.Added, d.Removed...) {
+		switch item.(type) {
+		case *Comment, *RawLine:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// sameValue returns true if the matching items a and b have the same value
+func sameValue(a, b ConfItem) bool {
+	switch it := a.(type) {
+	case *Option:
+		return it.ValueEqual(b)
+	case *Domain, *Lookup, *Family:
+		return it.Equal(b)
+	}
+	return true
+}
+
+// single returns true for the kinds a Conf has at most one item of
+func single(k itemKind) bool {
+	return k == kindDomain || k == kindLookup || k == kindFamily
+}
+
+// TextDiff returns the lines that change when the file written for a is
+// replaced by the one written for b, in unified diff style, e.g.
+//
+//	-nameserver 8.8.8.8
+//	+nameserver 1.1.1.1
+//
+// Lines are compared as Write formats them, comments included, blank lines
+// are left out. The result is empty if the files have the same lines
+func TextDiff(a, b *Conf) string {
+	x, y := textLines(a), textLines(b)
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i, j = i+1, j+1
+		case j == len(y) || i < len(x) && lcs[i+1][j] >= lcs[i][j+1]:
+			sb.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+// textLines returns the non blank lines Write writes for conf
+func textLines(conf *Conf) []string {
+	if conf == nil {
+		return nil
+	}
+	var res []string
+	for _, line := range strings.Split(conf.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			res = append(res, line)
+		}
+	}
+	return res
+}
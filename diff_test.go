@@ -0,0 +1,75 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func readConf(t *testing.T, s string) *resolvconf.Conf {
+	conf, err := resolvconf.ReadConf(strings.NewReader(s))
+	assert.Nil(t, err)
+	return conf
+}
+
+func TestDiff(t *testing.T) {
+	a := readConf(t, "domain example.com\nnameserver 8.8.8.8\nnameserver 10.0.0.1\nsearch example.com\noptions ndots:2 rotate\n")
+	b := readConf(t, "domain lab.example\nnameserver 10.0.0.1\nnameserver 1.1.1.1\nsearch example.com\noptions ndots:5 rotate edns0\n")
+
+	d := a.Diff(b)
+	assert.False(t, d.IsEmpty())
+	assert.Equal(t, []resolvconf.ConfItem{resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))}, d.Removed)
+	assert.Equal(t, []resolvconf.ConfItem{resolvconf.NewNameserver(net.ParseIP("1.1.1.1")), resolvconf.NewOption("edns0")}, d.Added)
+	assert.Equal(t, []resolvconf.Change{
+		{From: resolvconf.NewDomain("example.com"), To: resolvconf.NewDomain("lab.example")},
+		{From: resolvconf.NewOption("ndots").Set(2), To: resolvconf.NewOption("ndots").Set(5)},
+	}, d.Changed)
+	assert.Nil(t, d.Moved)
+
+	assert.True(t, a.Diff(a).IsEmpty())
+	assert.Equal(t, 6, len(a.Diff(resolvconf.New()).Removed))
+}
+
+func TestDiffOrder(t *testing.T) {
+	a := readConf(t, "nameserver 8.8.8.8\nnameserver 10.0.0.1\nnameserver 1.1.1.1\n")
+	b := readConf(t, "nameserver 10.0.0.1\nnameserver 8.8.8.8\nnameserver 1.1.1.1\n")
+	assert.True(t, a.Diff(b).IsEmpty())
+
+	d := a.Diff(b, resolvconf.DiffOrder())
+	assert.Equal(t, 2, len(d.Moved))
+	assert.Nil(t, d.Added)
+	assert.Nil(t, d.Removed)
+
+	// Adding and removing items moves nothing
+	c := readConf(t, "nameserver 9.9.9.9\nnameserver 8.8.8.8\nnameserver 1.1.1.1\n")
+	assert.Nil(t, a.Diff(c, resolvconf.DiffOrder()).Moved)
+}
+
+func TestTextDiff(t *testing.T) {
+	a := readConf(t, "# Static\nnameserver 8.8.8.8\nnameserver 10.0.0.1\noptions ndots:2\n")
+	b := readConf(t, "# Static\nnameserver 10.0.0.1\nnameserver 1.1.1.1\noptions ndots:2 rotate\n")
+	assert.Equal(t, "-nameserver 8.8.8.8\n-options ndots:2\n+nameserver 1.1.1.1\n+options ndots:2 rotate\n", resolvconf.TextDiff(a, b))
+	assert.Equal(t, "", resolvconf.TextDiff(a, a))
+	assert.Equal(t, "+search example.com\n", resolvconf.TextDiff(resolvconf.New(),
+		readConf(t, "search example.com\n")))
+}
+
+func TestConfEqual(t *testing.T) {
+	a := readConf(t, "nameserver 10.0.0.1\nnameserver 10.0.0.2\nsearch a.com b.com\noptions ndots:2 rotate\n")
+	b := readConf(t, "# Same servers\nsearch b.com a.com\nnameserver 10.0.0.2\nnameserver 10.0.0.1\noptions rotate ndots:2\n")
+	assert.True(t, a.Equal(b))
+	assert.False(t, a.EqualStrict(b))
+
+	c := readConf(t, "options rotate ndots:2\nsearch a.com b.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n")
+	assert.True(t, a.EqualStrict(c))
+	assert.False(t, a.Equal(readConf(t, "nameserver 10.0.0.1\nnameserver 10.0.0.2\nsearch a.com b.com\noptions ndots:3 rotate\n")))
+	assert.False(t, a.Equal(readConf(t, "nameserver 10.0.0.1\nsearch a.com b.com\noptions ndots:2 rotate\n")))
+
+	// Empty configurations are equal however they were made
+	var empty resolvconf.Conf
+	assert.True(t, resolvconf.New().EqualStrict(&empty))
+	assert.True(t, readConf(t, "# Nothing\n").Equal(resolvconf.New()))
+	assert.True(t, resolvconf.New().Equal(nil))
+}
@@ -0,0 +1,95 @@
+package resolvconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DnsmasqOption customizes ToDnsmasq
+type DnsmasqOption func(o *dnsmasqOptions)
+
+type dnsmasqOptions struct {
+	domainServers map[string][]Nameserver
+	noResolv      bool
+}
+
+// DnsmasqDomainServers sends queries for domain and its subdomains to
+// servers, rendered as server=/domain/addr lines
+func DnsmasqDomainServers(domain string, servers ...Nameserver) DnsmasqOption {
+	return func(o *dnsmasqOptions) {
+		o.domainServers[domain] = append(o.domainServers[domain], servers...)
+	}
+}
+
+// DnsmasqNoResolv adds no-resolv so dnsmasq only uses the rendered servers
+// and doesn't read /etc/resolv.conf
+func DnsmasqNoResolv() DnsmasqOption {
+	return func(o *dnsmasqOptions) {
+		o.noResolv = true
+	}
+}
+
+// dnsmasqAddr renders a server address, dnsmasq separates the port by #
+func dnsmasqAddr(ns Nameserver) string {
+	if ns.Port != 0 && ns.Port != DefaultPort {
+		return ns.IP.String() + "#" + strconv.Itoa(ns.Port)
+	}
+	return ns.IP.String()
+}
+
+// ToDnsmasq renders the configuration as dnsmasq configuration lines. The
+// domain becomes domain=, the search list is handed to DHCP clients using
+// the domain-search option and every nameserver becomes a server= line.
+// Items without a dnsmasq equivalent, the sortlist and the options, are
+// listed in comments at the end. The output is deterministic
+func (conf *Conf) ToDnsmasq(opts ...DnsmasqOption) (string, error) {
+	o := &dnsmasqOptions{domainServers: make(map[string][]Nameserver)}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	conf.rlock()
+	defer conf.runlock()
+	var b strings.Builder
+	if o.noResolv {
+		b.WriteString("no-resolv\n")
+	}
+	if dom := conf.domain(); dom.Name != "" {
+		fmt.Fprintf(&b, "domain=%s\n", dom.Name)
+	}
+	if doms := conf.searchDomainsInto(nil); len(doms) > 0 {
+		names := make([]string, len(doms))
+		for i, dom := range doms {
+			names[i] = dom.Name
+		}
+		fmt.Fprintf(&b, "dhcp-option=option:domain-search,%s\n", strings.Join(names, ","))
+	}
+	for _, ns := range conf.nameserversInto(nil) {
+		fmt.Fprintf(&b, "server=%s\n", dnsmasqAddr(ns))
+	}
+
+	domains := make([]string, 0, len(o.domainServers))
+	for dom := range o.domainServers {
+		domains = append(domains, dom)
+	}
+	sort.Strings(domains)
+	for _, dom := range domains {
+		name := strings.Trim(dom, ".")
+		if name == "" || strings.ContainsAny(name, "/# \t") {
+			return "", fmt.Errorf("Malformed domain %q", dom)
+		}
+		for _, ns := range o.domainServers[dom] {
+			fmt.Fprintf(&b, "server=/%s/%s\n", name, dnsmasqAddr(ns))
+		}
+	}
+
+	for _, item := range conf.items {
+		switch item.(type) {
+		case *SortItem, *Option:
+			fmt.Fprintf(&b, "# unsupported: %s\n", itemLine(item))
+		}
+	}
+	return b.String(), nil
+}
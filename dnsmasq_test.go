@@ -0,0 +1,36 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func exportConf(t *testing.T) *resolvconf.Conf {
+	conf, err := resolvconf.ReadConf(strings.NewReader("domain corp.example\n" +
+		"nameserver 10.0.0.1\nnameserver [2001:db8::1]:5353\n" +
+		"search corp.example lab.example\nsortlist 10.0.0.0/255.0.0.0\n" +
+		"options rotate ndots:2\n"))
+	assert.Nil(t, err)
+	return conf
+}
+
+func TestToDnsmasqGolden(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/dnsmasq.golden.conf")
+	assert.Nil(t, err)
+	internal := resolvconf.NewNameserver(net.ParseIP("10.0.0.5"))
+	out, err := exportConf(t).ToDnsmasq(resolvconf.DnsmasqNoResolv(),
+		resolvconf.DnsmasqDomainServers("vpn.example", *internal),
+		resolvconf.DnsmasqDomainServers("internal.example.", *internal))
+	assert.Nil(t, err)
+	assert.Equal(t, string(golden), out)
+}
+
+func TestToDnsmasqMalformedDomain(t *testing.T) {
+	internal := resolvconf.NewNameserver(net.ParseIP("10.0.0.5"))
+	_, err := exportConf(t).ToDnsmasq(resolvconf.DnsmasqDomainServers("a/b", *internal))
+	assert.NotNil(t, err)
+}
@@ -1,21 +1,80 @@
 package resolvconf
 
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	nameMaxLen  = 253 // Maximum length of a domain name, without trailing dot
+	labelMaxLen = 63  // Maximum length of a label
+)
+
 // Domain is the single domain in a resolv.conf file
 type Domain struct {
 	Name string
 }
 
 // NewDomain creates a new domain that will be used
-// as value for the 'domain' option in the generated file
+// as value for the 'domain' option in the generated file. The name is
+// checked when added, NewDomainStrict checks it right away
 func NewDomain(dom string) *Domain {
 	return &Domain{dom}
 }
 
+// NewDomainStrict is NewDomain returning an error wrapping ErrInvalidValue
+// if dom isn't a valid domain name
+func NewDomainStrict(dom string) (*Domain, error) {
+	if err := checkName(dom); err != nil {
+		return nil, fmt.Errorf("%w: domain %s", err, dom)
+	}
+	return NewDomain(dom), nil
+}
+
+// checkName checks a domain name against the label rules of RFC 1035,
+// underscores and a trailing dot are allowed since they occur in the wild.
+// Internationalized names must be in punycode form
+func checkName(name string) error {
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("%w, not valid UTF-8", ErrInvalidValue)
+	}
+	for _, r := range name {
+		if r >= utf8.RuneSelf {
+			return fmt.Errorf("%w, non ASCII character %q, internationalized names must be in punycode (xn--) form", ErrInvalidValue, r)
+		}
+	}
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > nameMaxLen {
+		return fmt.Errorf("%w, longer than %d characters", ErrInvalidValue, nameMaxLen)
+	}
+	for _, label := range strings.Split(name, ".") {
+		switch {
+		case label == "":
+			return fmt.Errorf("%w, empty label", ErrInvalidValue)
+		case len(label) > labelMaxLen:
+			return fmt.Errorf("%w, label longer than %d characters", ErrInvalidValue, labelMaxLen)
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return fmt.Errorf("%w, label %s starts or ends with a dash", ErrInvalidValue, label)
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("%w, character %q not allowed", ErrInvalidValue, c)
+			}
+		}
+	}
+	return nil
+}
+
 func (dom Domain) applyLimits(conf *Conf) (bool, error) {
-	i := conf.indexOf(conf.GetDomain())
+	if err := checkName(dom.Name); err != nil {
+		return false, fmt.Errorf("%w: domain %s", err, dom.Name)
+	}
+	i := conf.indexOf(conf.domain())
 	if i != -1 {
 		// Found it, update and return not ok to add
-		conf.items[i] = &Domain{dom.Name}
+		conf.replaceItem(i, &Domain{dom.Name})
 		return false, nil
 	}
 
@@ -27,6 +86,22 @@ func (dom Domain) String() string {
 	return dom.Name
 }
 
+// MarshalText implements encoding.TextMarshaler
+func (dom Domain) MarshalText() ([]byte, error) {
+	return []byte(dom.Name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, text is checked like
+// by NewDomainStrict
+func (dom *Domain) UnmarshalText(text []byte) error {
+	d, err := NewDomainStrict(string(text))
+	if err != nil {
+		return err
+	}
+	*dom = *d
+	return nil
+}
+
 // Equal compares two domains with each other, returns true if equal
 func (dom Domain) Equal(b ConfItem) bool {
 	if item, ok := b.(*Domain); ok {
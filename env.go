@@ -0,0 +1,133 @@
+package resolvconf
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Environment variable names used by FromEnvironment, without prefix
+const (
+	EnvNameservers = "NAMESERVERS"
+	EnvSearch      = "SEARCH"
+	EnvOptions     = "OPTIONS"
+	EnvDomain      = "DOMAIN"
+)
+
+// Environment variables of glibc overriding the file, see resolv.conf(5)
+const (
+	EnvLocalDomain = "LOCALDOMAIN"
+	EnvResOptions  = "RES_OPTIONS"
+)
+
+// splitList splits on commas and whitespace
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// FromEnvironment builds a configuration from environment variables, with
+// prefix "DNS" these are
+//
+//	DNS_NAMESERVERS="10.0.0.1,10.0.0.2"
+//	DNS_SEARCH="a.example b.example"
+//	DNS_OPTIONS="ndots:2 rotate"
+//	DNS_DOMAIN="corp"
+//
+// Lists may be separated by commas and/or whitespace. Variables are looked
+// up with lookup, nil means os.LookupEnv. Errors name the variable and the
+// offending token, like ReadConf the Conf is returned along with the error
+// if some of the items could not be added
+func FromEnvironment(prefix string, lookup func(string) (string, bool)) (*Conf, error) {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	var res *multierror.Error
+	conf := New()
+	add := func(name, tok string, item ConfItem, err error) {
+		if err == nil {
+			err = conf.Add(item)
+		}
+		if err != nil {
+			res = multierror.Append(res, fmt.Errorf("%s%s: %q: %w", prefix, name, tok, err))
+		}
+	}
+
+	if val, ok := lookup(prefix + EnvNameservers); ok {
+		for _, tok := range splitList(val) {
+			ns, err := parseNameserver(tok)
+			add(EnvNameservers, tok, ns, err)
+		}
+	}
+	if val, ok := lookup(prefix + EnvDomain); ok {
+		if doms := splitList(val); len(doms) == 1 {
+			add(EnvDomain, doms[0], NewDomain(doms[0]), nil)
+		} else if len(doms) > 1 {
+			add(EnvDomain, val, nil, fmt.Errorf("%w: only one domain may be given", ErrInvalidValue))
+		}
+	}
+	if val, ok := lookup(prefix + EnvSearch); ok {
+		for _, tok := range splitList(val) {
+			add(EnvSearch, tok, NewSearchDomain(tok), nil)
+		}
+	}
+	if val, ok := lookup(prefix + EnvOptions); ok {
+		for _, tok := range splitList(val) {
+			opt, err := parseOption(tok)
+			add(EnvOptions, tok, opt, err)
+		}
+	}
+	return conf, res.ErrorOrNil()
+}
+
+// ApplyEnvironment returns the configuration a process sees with the
+// environment overrides of glibc, the Conf itself is not modified.
+// Variables are looked up with getenv, nil means os.Getenv, empty ones are
+// taken as not set.
+//
+// LOCALDOMAIN replaces the search list with its whitespace separated
+// domains, the domain line is dropped since glibc ignores it then.
+// RES_OPTIONS is applied after the file, its options are added or override
+// the values of the file. Malformed or unknown options are skipped with a
+// WarnOptionSkipped warning on the Conf returned, like glibc ignores them.
+// Search domains that can't be added are returned as error along with the
+// Conf
+func (conf *Conf) ApplyEnvironment(getenv func(string) string) (*Conf, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	c := conf.Clone()
+	var res *multierror.Error
+	if val := getenv(EnvLocalDomain); val != "" {
+		c.RemoveFunc(func(item ConfItem) bool {
+			k := keyOf(item).kind
+			return k == kindSearchDomain || k == kindDomain
+		})
+		for _, tok := range strings.Fields(val) {
+			if err := c.Add(NewSearchDomain(tok)); err != nil {
+				res = multierror.Append(res, fmt.Errorf("%s: %q: %w", EnvLocalDomain, tok, err))
+			}
+		}
+	}
+	for _, tok := range strings.Fields(getenv(EnvResOptions)) {
+		opt, err := parseOption(tok)
+		if err == nil {
+			err = c.Add(opt)
+		}
+		if err != nil {
+			msg := fmt.Sprintf("%s option %s is skipped: %s", EnvResOptions, tok, err)
+			if c.logger.enabled(LogWarn) {
+				c.logger.l.Warn(msg, "op", "environment", "item", tok, "error", err)
+			}
+			c.warn(Warning{Code: WarnOptionSkipped, Message: msg})
+		}
+	}
+	return c, res.ErrorOrNil()
+}
@@ -0,0 +1,94 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	conf, err := resolvconf.FromEnvironment("DNS", envLookup(map[string]string{
+		"DNS_NAMESERVERS": "10.0.0.1,10.0.0.2 , 2001:db8::1",
+		"DNS_SEARCH":      "a.example b.example,c.example",
+		"DNS_OPTIONS":     "ndots:2 rotate",
+		"DNS_DOMAIN":      "corp",
+	}))
+	assert.Nil(t, err)
+	str, _ := GetConf(conf)
+	assert.Equal(t, "domain corp\nnameserver 10.0.0.1\nnameserver 10.0.0.2\nnameserver 2001:db8::1\n\n"+
+		"search a.example b.example c.example\n\noptions ndots:2 rotate\n\n", str)
+}
+
+func TestFromEnvironmentEmpty(t *testing.T) {
+	conf, err := resolvconf.FromEnvironment("DNS_", envLookup(nil))
+	assert.Nil(t, err)
+	assert.Empty(t, conf.GetNameservers())
+}
+
+func TestFromEnvironmentErrors(t *testing.T) {
+	conf, err := resolvconf.FromEnvironment("DNS", envLookup(map[string]string{
+		"DNS_NAMESERVERS": "10.0.0.1 10.0.0",
+		"DNS_OPTIONS":     "ndots:2 bogus",
+		"DNS_DOMAIN":      "a b",
+	}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `DNS_NAMESERVERS: "10.0.0"`)
+	assert.Contains(t, err.Error(), `DNS_OPTIONS: "bogus"`)
+	assert.Contains(t, err.Error(), `DNS_DOMAIN`)
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnknownOption))
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Equal(t, 1, len(conf.GetOptions()))
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\ndomain corp.example\nsearch corp.example\noptions ndots:2 rotate\n"))
+	assert.Nil(t, err)
+	before := conf.String()
+	env := map[string]string{
+		resolvconf.EnvLocalDomain: "a.example  b.example",
+		resolvconf.EnvResOptions:  "ndots:5 bogus attempts:x timeout:3",
+	}
+	eff, err := conf.ApplyEnvironment(func(key string) string { return env[key] })
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 10.0.0.1\n\nsearch a.example b.example\n\noptions ndots:5 rotate timeout:3\n\n", eff.String())
+	var skipped []string
+	for _, w := range eff.Warnings() {
+		if w.Code == resolvconf.WarnOptionSkipped {
+			skipped = append(skipped, w.Message)
+		}
+	}
+	assert.Equal(t, 2, len(skipped))
+	assert.Contains(t, skipped[0], "bogus")
+	assert.Contains(t, skipped[1], "attempts:x")
+	// The Conf itself is left alone
+	assert.Equal(t, before, conf.String())
+	assert.Empty(t, conf.Warnings())
+
+	// Nothing set
+	eff, err = conf.ApplyEnvironment(func(string) string { return "" })
+	assert.Nil(t, err)
+	assert.True(t, conf.EqualStrict(eff))
+}
+
+func TestApplyEnvironmentSearchErrors(t *testing.T) {
+	conf := resolvconf.New()
+	eff, err := conf.ApplyEnvironment(func(key string) string {
+		if key == resolvconf.EnvLocalDomain {
+			return "a.example -bad.example"
+		}
+		return ""
+	})
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	assert.Contains(t, err.Error(), "LOCALDOMAIN")
+	assert.Equal(t, []resolvconf.SearchDomain{{Name: "a.example"}}, eff.GetSearchDomains())
+}
@@ -0,0 +1,141 @@
+package resolvconf
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Errors returned when adding, removing or parsing items, test for them with
+// errors.Is. The errors returned carry the details, e.g. which item failed.
+// Errors accumulated by Add, Remove and ReadConf are matched if any of the
+// accumulated errors is
+var (
+	// ErrDuplicateItem is returned by Add when an equal item is already
+	// present and can't be updated, e.g. a nameserver or search domain, and
+	// by RegisterOption for an option type already known
+	ErrDuplicateItem = errors.New("Item already exists")
+	// ErrUnknownOption is returned by Add and ReadConf for an option type
+	// not in resolv.conf(5) nor registered by RegisterOption, unless the
+	// Conf allows unknown options
+	ErrUnknownOption = errors.New("Unknown option")
+	// ErrUnknownKeyword is returned by ReadConf for a line starting with a
+	// keyword not in resolv.conf(5), the line is kept as a RawLine
+	ErrUnknownKeyword = errors.New("Unknown keyword")
+	// ErrUnsupportedKeyword is returned by Add for items glibc ignores,
+	// e.g. the OpenBSD lookup and family lines, with ProfileGlibc and
+	// LimitStrict. ReadConf keeps their lines as RawLines
+	ErrUnsupportedKeyword = errors.New("Unsupported keyword")
+	// ErrInvalidValue is returned by Add for nil items, unset nameserver
+	// addresses, invalid domain names and option values out of range, and
+	// when reading a malformed address, port or option value with ReadConf
+	// and the From* importers
+	ErrInvalidValue = errors.New("Invalid value")
+	// ErrNotFound is returned by Remove for items that aren't present
+	ErrNotFound = errors.New("Not found")
+	// ErrNoNameservers is returned by FilterLoopbackNameservers when no
+	// nameserver is left and no fallback is given
+	ErrNoNameservers = errors.New("No nameservers")
+	// ErrLimitExceeded is returned by Add when a limit of the profile of
+	// the Conf would be exceeded, e.g. the number of search domains
+	ErrLimitExceeded = errors.New("Limit exceeded")
+	// ErrTooManyNameservers is returned by Add when the Conf already has the
+	// maximum number of nameservers, it also matches ErrLimitExceeded
+	ErrTooManyNameservers error = limitError("Too many nameservers")
+)
+
+// limitError is a more specific ErrLimitExceeded
+type limitError string
+
+func (e limitError) Error() string {
+	return string(e)
+}
+
+// Is makes errors.Is(err, ErrLimitExceeded) true
+func (e limitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// ItemError is the error for a single item rejected by Add, Remove or
+// ReadConf, errors returned by these wrap one ItemError per rejected item.
+// Use errors.As to get at it. Err wraps one of the sentinels above
+type ItemError struct {
+	Item ConfItem // The rejected item, nil if a nil item or an unparsable string was given
+	Op   string   // "add" or "remove"
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns Err so errors.Is matches the sentinels
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// ItemErrors returns all ItemErrors in err, in the order the items were
+// given. Add, Remove and ReadConf report every rejected item in one
+// *multierror.Error, errors.As only finds the first
+func ItemErrors(err error) []*ItemError {
+	var merr *multierror.Error
+	if errors.As(err, &merr) {
+		var res []*ItemError
+		for _, e := range merr.Errors {
+			res = append(res, ItemErrors(e)...)
+		}
+		return res
+	}
+	var ie *ItemError
+	if errors.As(err, &ie) {
+		return []*ItemError{ie}
+	}
+	return nil
+}
+
+// ParseError is the error for a line ReadConf could not parse or whose
+// items could not be added. ReadConfFile sets Path, errors of a file are
+// rendered as path:line:column: error, or without path as line N. Err wraps
+// the sentinel, or the ItemError if the item was parsed but rejected
+type ParseError struct {
+	Path   string // File read, empty if read with ReadConf
+	Line   int    // From 1
+	Column int    // Of the offending token, from 1, 0 if the whole line
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	var pos string
+	switch {
+	case e.Path == "" && e.Column > 0:
+		pos = fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	case e.Path == "":
+		pos = fmt.Sprintf("line %d", e.Line)
+	case e.Column > 0:
+		pos = fmt.Sprintf("%s:%d:%d", e.Path, e.Line, e.Column)
+	default:
+		pos = fmt.Sprintf("%s:%d", e.Path, e.Line)
+	}
+	return pos + ": " + e.Err.Error()
+}
+
+// Unwrap returns Err so errors.Is and errors.As reach the sentinels and
+// the ItemError
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// setPath sets the path of the ParseErrors in err, which is returned by
+// readConf. Other errors already name the file, e.g. *os.PathError
+func setPath(err error, path string) {
+	var merr *multierror.Error
+	if !errors.As(err, &merr) {
+		return
+	}
+	for _, e := range merr.Errors {
+		var pe *ParseError
+		if errors.As(e, &pe) {
+			pe.Path = path
+		}
+	}
+}
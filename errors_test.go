@@ -0,0 +1,208 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAddSentinelErrors(t *testing.T) {
+	conf := resolvconf.New()
+	ns := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	assert.Nil(t, conf.Add(ns))
+
+	for _, tc := range []struct {
+		item resolvconf.ConfItem
+		want error
+	}{
+		{resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.ErrDuplicateItem},
+		{&resolvconf.Option{Type: "foo", Value: -1}, resolvconf.ErrUnknownOption},
+		{resolvconf.NewOption("ndots").Set(-3), resolvconf.ErrInvalidValue},
+		{&resolvconf.Option{Type: "timeout", Value: -2}, resolvconf.ErrInvalidValue},
+		{nil, resolvconf.ErrInvalidValue},
+	} {
+		err := conf.Add(tc.item)
+		assert.True(t, errors.Is(err, tc.want), "%v", err)
+	}
+
+	conf.Add(resolvconf.NewSearchDomain("example.com"), resolvconf.NewSortItem(net.ParseIP("10.0.0.0")))
+	for _, item := range []resolvconf.ConfItem{
+		resolvconf.NewSearchDomain("example.com"),
+		resolvconf.NewSortItem(net.ParseIP("10.0.0.0")),
+	} {
+		assert.True(t, errors.Is(conf.Add(item), resolvconf.ErrDuplicateItem))
+	}
+}
+
+func TestLimitSentinelErrors(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")),
+		resolvconf.NewNameserver(net.ParseIP("8.8.4.4")),
+		resolvconf.NewNameserver(net.ParseIP("1.1.1.1")))
+	err := conf.Add(resolvconf.NewNameserver(net.ParseIP("1.0.0.1")))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+	assert.True(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+	assert.False(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+
+	conf = resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
+	for i := 0; i < 6; i++ {
+		assert.Nil(t, conf.Add(resolvconf.NewSearchDomain("d"+strconv.Itoa(i)+".example")))
+	}
+	err = conf.Add(resolvconf.NewSearchDomain("d6.example"))
+	assert.True(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+	assert.False(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+
+	conf = resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
+	for _, c := range "abcd" {
+		assert.Nil(t, conf.Add(resolvconf.NewSearchDomain(strings.Repeat(string(c), 63))))
+	}
+	err = conf.Add(resolvconf.NewSearchDomain("e"))
+	assert.True(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+
+	conf = resolvconf.New()
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, conf.Add(resolvconf.NewSortItem(net.IPv4(10, 0, byte(i), 0))))
+	}
+	err = conf.Add(resolvconf.NewSortItem(net.IPv4(10, 0, 10, 0)))
+	assert.True(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+}
+
+func TestRemoveSentinelErrors(t *testing.T) {
+	conf := resolvconf.New()
+	err := conf.Remove(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.True(t, errors.Is(err, resolvconf.ErrNotFound))
+	assert.Contains(t, err.Error(), "8.8.8.8")
+	assert.True(t, errors.Is(conf.Remove(nil), resolvconf.ErrInvalidValue))
+}
+
+func TestReadConfSentinelErrors(t *testing.T) {
+	for in, want := range map[string]error{
+		"nameserver 8.8.8":                       resolvconf.ErrInvalidValue,
+		"nameserver [8.8.8.8]:foo":               resolvconf.ErrInvalidValue,
+		"nameserver [8.8.8.8":                    resolvconf.ErrInvalidValue,
+		"domain":                                 resolvconf.ErrInvalidValue,
+		"sortlist 10.0.0.x":                      resolvconf.ErrInvalidValue,
+		"sortlist 10.0.0.0/255.x":                resolvconf.ErrInvalidValue,
+		"sortlist 10.0.0.0/33":                   resolvconf.ErrInvalidValue,
+		"options ndots":                          resolvconf.ErrInvalidValue,
+		"options ndots:x":                        resolvconf.ErrInvalidValue,
+		"options ndots:-1":                       resolvconf.ErrInvalidValue,
+		"options foo":                            resolvconf.ErrUnknownOption,
+		"options debug:1":                        resolvconf.ErrUnknownOption,
+		"foo bar":                                resolvconf.ErrUnknownKeyword,
+		"search a.example a.example":             resolvconf.ErrDuplicateItem,
+		"nameserver 1.1.1.1\nnameserver 1.1.1.1": resolvconf.ErrDuplicateItem,
+	} {
+		_, err := resolvconf.ReadConf(strings.NewReader(in))
+		assert.True(t, errors.Is(err, want), "%q: %v", in, err)
+	}
+
+	_, err := resolvconf.ReadConf(strings.NewReader("nameserver 1.1.1.1\nnameserver 1.1.1.2\nnameserver 1.1.1.3\nnameserver 1.1.1.4"))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+}
+
+func TestImporterSentinelErrors(t *testing.T) {
+	_, err := resolvconf.FromDHClientLeases(strings.NewReader("lease {\n  option domain-name-servers 10.0.0.x;\n}\n"))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), "%v", err)
+}
+
+func TestItemErrorCarriesItem(t *testing.T) {
+	conf := resolvconf.New()
+	dup := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	err := conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewOption("debug"), dup)
+	var ie *resolvconf.ItemError
+	assert.True(t, errors.As(err, &ie))
+	assert.True(t, ie.Item == dup)
+	assert.Equal(t, "add", ie.Op)
+	assert.True(t, errors.Is(ie.Err, resolvconf.ErrDuplicateItem))
+	assert.Equal(t, "Item already exists: nameserver 8.8.8.8", ie.Error())
+
+	missing := resolvconf.NewSearchDomain("example.com")
+	err = conf.Remove(missing)
+	assert.True(t, errors.As(err, &ie))
+	assert.True(t, ie.Item == missing)
+	assert.Equal(t, "remove", ie.Op)
+	assert.True(t, errors.Is(ie, resolvconf.ErrNotFound))
+
+	// Items ReadConf could parse but not add
+	_, err = resolvconf.ReadConf(strings.NewReader("nameserver 1.1.1.1\nnameserver 1.1.1.1"))
+	assert.True(t, errors.As(err, &ie))
+	assert.Equal(t, "1.1.1.1", fmt.Sprint(ie.Item))
+
+	// Lines that could not be parsed have no item
+	_, err = resolvconf.ReadConf(strings.NewReader("options foo"))
+	assert.False(t, errors.As(err, &ie))
+}
+
+func TestAllRejectedItemsAreReported(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewSearchDomain("example.com"))
+
+	dupNs := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	badOpt := &resolvconf.Option{Type: "foo", Value: -1}
+	dupSd := resolvconf.NewSearchDomain("example.com")
+	err := conf.Add(dupNs, resolvconf.NewOption("debug"), badOpt, nil, dupSd)
+	errs := resolvconf.ItemErrors(err)
+	assert.Equal(t, 4, len(errs))
+	assert.True(t, errs[0].Item == dupNs)
+	assert.True(t, errs[1].Item == badOpt)
+	assert.Nil(t, errs[2].Item)
+	assert.True(t, errs[3].Item == dupSd)
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnknownOption))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+
+	err = conf.Remove(resolvconf.NewDomain("example.com"), resolvconf.NewOption("debug"), resolvconf.NewOption("rotate"))
+	errs = resolvconf.ItemErrors(err)
+	assert.Equal(t, 2, len(errs))
+	assert.Equal(t, "remove", errs[1].Op)
+	assert.True(t, errors.Is(err, resolvconf.ErrNotFound))
+
+	assert.Nil(t, resolvconf.ItemErrors(nil))
+	assert.Nil(t, resolvconf.ItemErrors(errors.New("other")))
+}
+
+func TestReadConfFileErrorContext(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/run/resolv.conf", []byte("# comment\nnameserver 8.8.8.8\n\noptions rotate foo ndots:2\nnameserver 8.8.8.8\n"), 0644))
+	assert.Nil(t, fsys.Symlink("/run/resolv.conf", "/etc/resolv.conf"))
+
+	_, err := resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.WithFileSystem(fsys))
+	assert.Equal(t, "2 errors occurred:\n"+
+		"\t* /run/resolv.conf:4:16: Unknown option foo\n"+
+		"\t* /run/resolv.conf:5: Item already exists: nameserver 8.8.8.8\n\n", err.Error())
+
+	var pe *resolvconf.ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, resolvconf.ParseError{Path: "/run/resolv.conf", Line: 4, Column: 16, Err: pe.Err}, *pe)
+	assert.True(t, errors.Is(pe, resolvconf.ErrUnknownOption))
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+	errs := resolvconf.ItemErrors(err)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "8.8.8.8", fmt.Sprint(errs[0].Item))
+
+	// Errors not about the content name the file once
+	_, err = resolvconf.ReadConfFile("/etc/missing.conf", resolvconf.WithFileSystem(fsys))
+	assert.Equal(t, 1, strings.Count(err.Error(), "/etc/missing.conf"), err.Error())
+	assert.False(t, errors.As(err, &pe))
+}
+
+func TestReadConfErrorContext(t *testing.T) {
+	_, err := resolvconf.ReadConf(strings.NewReader("  sortlist 10.0.0.0 10.0.0.x\nfoo bar\ndomain\n"))
+	assert.Equal(t, "3 errors occurred:\n"+
+		"\t* line 1, column 21: Invalid value: malformed IP address 10.0.0.x in sortlist\n"+
+		"\t* line 2, column 1: Unknown keyword foo\n"+
+		"\t* line 3: Invalid value: missing value for domain\n\n", err.Error())
+
+	_, err = resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8\nsearch " + strings.Repeat("a", 1<<20)))
+	var pe *resolvconf.ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 2, pe.Line)
+}
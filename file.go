@@ -0,0 +1,517 @@
+package resolvconf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileOption customizes functions reading files
+type FileOption interface {
+	applyFile(o *fileOptions)
+}
+
+type fileOptions struct {
+	root          string
+	probes        []Source
+	fs            FileSystem
+	system        string // Read by ReadSystemConf
+	followStub    bool
+	watchInterval time.Duration
+	read          readOptions
+}
+
+type fileOptionFunc func(o *fileOptions)
+
+func (f fileOptionFunc) applyFile(o *fileOptions) {
+	f(o)
+}
+
+func newFileOptions(opts []FileOption) *fileOptions {
+	o := &fileOptions{probes: DefaultProbeOrder, fs: OSFileSystem{}, system: SourceSystem.Path,
+		watchInterval: DefaultWatchInterval}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyFile(o)
+		}
+	}
+	return o
+}
+
+// path returns p relative to the root directory
+func (o *fileOptions) path(p string) string {
+	if o.root == "" {
+		return p
+	}
+	return filepath.Join(o.root, p)
+}
+
+// resolve follows the symlinks in every component of p as if root was
+// the root directory, so absolute symlink targets don't escape it. The
+// returned path is relative to the root. Without root p is returned as is
+// on the host file system, which resolves symlinks itself
+func (o *fileOptions) resolve(p string) (string, error) {
+	if _, ok := o.fs.(OSFileSystem); ok && o.root == "" {
+		return p, nil
+	}
+	links := 0
+	resolved := "/"
+	parts := splitPath(p)
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		if part == ".." {
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		fi, err := o.fs.Lstat(o.path(next))
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("Too many levels of symbolic links resolving %s", p)
+		}
+		target, err := o.fs.Readlink(o.path(next))
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+		parts = append(splitPath(target), parts...)
+		resolved = "/"
+	}
+	return resolved, nil
+}
+
+// splitPath splits p into its components, relative paths are taken as
+// relative to the root
+func splitPath(p string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(p), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// PathOption is an option accepted both by functions reading files and by
+// WriteFile
+type PathOption interface {
+	FileOption
+	WriteOption
+}
+
+type rootDir string
+
+func (r rootDir) applyFile(o *fileOptions) {
+	o.root = string(r)
+}
+
+func (r rootDir) applyWrite(o *writeOptions) {
+	o.root = string(r)
+}
+
+// RootDir makes all absolute paths relative to dir, e.g. to operate on
+// a mounted image or chroot. Symlinks are followed within dir, an absolute
+// target like /run/systemd/resolve/stub-resolv.conf is looked up in dir
+// and not on the live system
+func RootDir(dir string) PathOption {
+	return rootDir(dir)
+}
+
+// ReadConfFile reads the configuration from the file at path. Errors name
+// the file read, after following symlinks, parse errors are *ParseErrors
+// with Path set. A missing file is reported with an error matching
+// os.ErrNotExist. The Conf remembers path for Save
+func ReadConfFile(path string, opts ...FileOption) (*Conf, error) {
+	o := newFileOptions(opts)
+	resolved, err := o.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := o.fs.Open(o.path(resolved))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return o.readConf(f, path, resolved)
+}
+
+// readConf parses r, the content of path which resolved to resolved
+func (o *fileOptions) readConf(r io.Reader, path, resolved string) (*Conf, error) {
+	conf, err := ReadConf(r, o.read)
+	setPath(err, o.path(resolved))
+	if conf != nil {
+		conf.source = &confSource{path, o.path(resolved), o.root, o.fs}
+	}
+	return conf, err
+}
+
+// ReadPath reads the configuration from the file at path, it is
+// ReadConfFile without options
+func ReadPath(path string) (*Conf, error) {
+	return ReadConfFile(path)
+}
+
+// ReadSystemConf reads /etc/resolv.conf, or the file given with
+// SystemConfPath. With FollowStub the upstream file of systemd-resolved is
+// read instead if the file only lists its stub listeners
+func ReadSystemConf(opts ...FileOption) (*Conf, error) {
+	o := newFileOptions(opts)
+	conf, err := ReadConfFile(o.system, opts...)
+	if err != nil || !o.followStub || !conf.IsStubResolver() {
+		return conf, err
+	}
+	up, err := ReadConfFile(SystemdUplinkPath, opts...)
+	if errors.Is(err, os.ErrNotExist) {
+		if conf.logger.enabled(LogDebug) {
+			conf.logger.l.Debug(fmt.Sprintf("Not following stub %s, %s doesn't exist", o.system, SystemdUplinkPath), "op", "read", "path", o.system)
+		}
+		return conf, nil
+	}
+	if up != nil && up.logger.enabled(LogDebug) {
+		up.logger.l.Debug(fmt.Sprintf("Followed stub %s to %s", o.system, SystemdUplinkPath), "op", "read", "path", SystemdUplinkPath)
+	}
+	return up, err
+}
+
+// FollowStub makes ReadSystemConf read the file listing the servers
+// systemd-resolved forwards to when the system file only lists its stub
+// listeners, see IsStubResolver. The stub file is returned if there is no
+// upstream file, ParsedPath tells which one was read
+func FollowStub() FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.followStub = true
+	})
+}
+
+// SystemConfPath makes ReadSystemConf read path instead of
+// /etc/resolv.conf, e.g. in tests
+func SystemConfPath(path string) FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.system = path
+	})
+}
+
+// confSource is where a Conf was read from
+type confSource struct {
+	path   string
+	parsed string // After following symlinks, on the host
+	root   string
+	fs     FileSystem
+}
+
+// ErrNoSource is returned by Save for a Conf not read from a file
+var ErrNoSource = errors.New("Conf was not read from a file")
+
+// Source returns the path the Conf was read from by ReadConfFile, or an
+// empty string
+func (conf *Conf) Source() string {
+	if conf.source == nil {
+		return ""
+	}
+	return conf.source.path
+}
+
+// ParsedPath returns the file the Conf was parsed from, after following
+// symlinks and including the root directory, or an empty string if it
+// wasn't read by ReadConfFile
+func (conf *Conf) ParsedPath() string {
+	if conf.source == nil {
+		return ""
+	}
+	return conf.source.parsed
+}
+
+// Save writes the configuration back to the file it was read from with
+// WriteFile, keeping its mode and owner. The root directory and file
+// system it was read with are used, a symlink is followed like when it was
+// read unless ReplaceSymlink is given
+func (conf *Conf) Save(opts ...WriteOption) error {
+	if conf.source == nil {
+		return ErrNoSource
+	}
+	src := conf.source
+	opts = append([]WriteOption{rootDir(src.root), fileSystem{src.fs}, FollowSymlink()}, opts...)
+	return conf.WriteFile(src.path, 0, opts...)
+}
+
+// Source is a file a resolver configuration may be loaded from
+type Source struct {
+	Name string
+	Path string
+}
+
+// Known sources
+var (
+	// SourceSystemdResolved lists the upstream servers of systemd-resolved
+	SourceSystemdResolved = Source{"systemd-resolved", SystemdUplinkPath}
+	// SourceResolvconf is the file generated by resolvconf(8)
+	SourceResolvconf = Source{"resolvconf", "/run/resolvconf/resolv.conf"}
+	// SourceSystem is the file read by libc
+	SourceSystem = Source{"system", "/etc/resolv.conf"}
+)
+
+// DefaultProbeOrder is the order sources are probed in by LoadEffective
+var DefaultProbeOrder = []Source{SourceSystemdResolved, SourceResolvconf, SourceSystem}
+
+// ProbeOrder overrides the order sources are probed in by LoadEffective
+func ProbeOrder(sources ...Source) FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.probes = sources
+	})
+}
+
+// LoadEffective loads the configuration listing the real upstream servers.
+// Sources are probed in order and the first one that exists is read, by
+// default this prefers the systemd-resolved upstream file over the local
+// stub in /etc/resolv.conf. The source used is returned along with the Conf
+func LoadEffective(opts ...FileOption) (*Conf, Source, error) {
+	o := newFileOptions(opts)
+	for _, src := range o.probes {
+		p, err := o.resolve(src.Path)
+		if err == nil {
+			_, err = o.fs.Stat(o.path(p))
+		}
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, src, err
+		}
+		conf, err := ReadConfFile(src.Path, opts...)
+		return conf, src, err
+	}
+	return nil, Source{}, fmt.Errorf("No resolver configuration found: %w", os.ErrNotExist)
+}
+
+// WriteFile writes the configuration to the file at path. The content is
+// first written to a temporary file in the same directory, synced and then
+// renamed over path, so readers never see a partially written file. If
+// perm is 0 the mode and owner of the existing file are kept, a new file
+// gets mode 0644.
+// An *ImmutableFileError is returned without writing if path is immutable,
+// a *SymlinkError if path is a symlink unless ReplaceSymlink or
+// FollowSymlink is given. With RootDir path is taken relative to the root
+func (conf *Conf) WriteFile(path string, perm os.FileMode, opts ...WriteOption) error {
+	return conf.WriteFileContext(context.Background(), path, perm, opts...)
+}
+
+// WriteFileContext is WriteFile passing ctx to the AfterWrite hooks. Hooks
+// are not run once ctx is done, which fails the write like a hook error
+func (conf *Conf) WriteFileContext(ctx context.Context, path string, perm os.FileMode, opts ...WriteOption) error {
+	return conf.writeFileContext(ctx, path, perm, newWriteOptions(opts))
+}
+
+func (conf *Conf) writeFileContext(ctx context.Context, path string, perm os.FileMode, o *writeOptions) error {
+	changed, err := conf.writeFile(ctx, path, perm, o)
+	if o.dryRun != nil {
+		return err
+	}
+	instrumentation().ObserveWrite(path, changed, err)
+	if err != nil && conf.logger.enabled(LogWarn) {
+		conf.logger.l.Warn(fmt.Sprintf("Writing %s failed: %s", path, err), "op", "write", "path", path, "error", err)
+	} else if err == nil && conf.logger.enabled(LogInfo) {
+		conf.logger.l.Info(fmt.Sprintf("Wrote %s", path), "op", "write", "path", path, "changed", changed)
+	}
+	return err
+}
+
+// writeFile implements WriteFileContext, returns true if the file was
+// replaced
+func (conf *Conf) writeFile(ctx context.Context, path string, perm os.FileMode, o *writeOptions) (bool, error) {
+	fo := &fileOptions{root: o.root, fs: o.fs}
+	dir, err := fo.resolve(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	file := filepath.Join(dir, filepath.Base(path))
+	link := "" // Set if refusing to write a symlink
+	if fi, err := o.fs.Lstat(fo.path(file)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	} else if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		switch o.symlinks {
+		case symlinkRefuse:
+			if link, err = o.fs.Readlink(fo.path(file)); err != nil {
+				return false, err
+			}
+		case symlinkFollow:
+			if file, err = fo.followLinks(file); err != nil {
+				return false, err
+			}
+		}
+	}
+	target := fo.path(file)
+
+	if _, ok := o.fs.(OSFileSystem); ok {
+		if immutable, err := IsImmutable(target); err != nil {
+			return false, err
+		} else if immutable {
+			return false, &ImmutableFileError{path}
+		}
+	}
+	if o.respectGenerators {
+		m, err := DetectManager(path, rootDir(o.root), fileSystem{o.fs})
+		if err != nil {
+			return false, err
+		}
+		if m != ManagerNone {
+			if !o.forceGenerators {
+				return false, &ManagedByOtherError{path, m}
+			}
+			o.comments = append(o.comments, fmt.Sprintf("Previously managed by %s, overwritten by resolvconf", m))
+		}
+	}
+	if link != "" {
+		return false, &SymlinkError{path, link}
+	}
+
+	prev, err := o.fs.ReadFile(target)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	exists := err == nil
+	data, err := conf.fileContent(prev, exists, o)
+	if err != nil {
+		return false, err
+	}
+	if o.dryRun != nil {
+		*o.dryRun = data
+		return false, nil
+	}
+	var own *fileOwner
+	if perm == 0 {
+		perm = 0644
+		if fi, err := o.fs.Stat(target); err == nil {
+			perm = fi.Mode().Perm()
+			own = ownerOf(fi)
+		}
+	}
+	if o.onlyIfChanged && exists && bytes.Equal(prev, data) {
+		return false, nil
+	}
+	backup := ""
+	if o.backupSuffix != "" && exists {
+		backup = target + o.backupSuffix
+		if err := writeAtomic(o.fs, backup, prev, perm, own); err != nil {
+			return false, err
+		}
+	}
+	if err := writeAtomic(o.fs, target, data, perm, own); err != nil {
+		return false, err
+	}
+
+	for _, hook := range o.afterWrite {
+		err := ctx.Err()
+		if err == nil {
+			err = hook(ctx, path)
+		}
+		if err != nil {
+			if backup != "" {
+				if rerr := writeAtomic(o.fs, target, prev, perm, own); rerr != nil {
+					return true, fmt.Errorf("After write hook failed: %s, restoring backup failed: %w", err, rerr)
+				}
+				return false, fmt.Errorf("After write hook failed: %w", err)
+			}
+			return true, fmt.Errorf("After write hook failed: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// fileOwner is the owner of a file that is replaced
+type fileOwner struct {
+	uid, gid int
+}
+
+// writeAtomic writes data to a temporary file next to path and renames it
+// over path. The temporary file is unique to the call, writers in other
+// processes use their own. The owner is only set on the host file system
+func writeAtomic(fsys FileSystem, path string, data []byte, perm os.FileMode, own *fileOwner) error {
+	tmp, err := createTemp(fsys, path)
+	if err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(tmp, data, perm); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	if _, ok := fsys.(OSFileSystem); ok && own != nil {
+		if err := os.Lchown(tmp, own.uid, own.gid); err != nil {
+			fsys.Remove(tmp)
+			return err
+		}
+	}
+	if err := fsys.Rename(tmp, path); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// createTemp reserves a new temporary file name next to path. On the host
+// file system the file is created exclusively, others get a name not in use
+func createTemp(fsys FileSystem, path string) (string, error) {
+	dir, pattern := filepath.Dir(path), "."+filepath.Base(path)+".*.tmp"
+	if _, ok := fsys.(OSFileSystem); ok {
+		f, err := ioutil.TempFile(dir, pattern)
+		if err != nil {
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		return f.Name(), nil
+	}
+	for i := 0; i < 100; i++ {
+		tmp := filepath.Join(dir, strings.Replace(pattern, "*", strconv.FormatUint(uint64(rand.Uint32()), 10), 1))
+		if _, err := fsys.Lstat(tmp); os.IsNotExist(err) {
+			return tmp, nil
+		}
+	}
+	return "", fmt.Errorf("No temporary file name left for %s", path)
+}
+
+// OnlyIfChanged makes WriteFile leave the file, and run no AfterWrite
+// hooks, if it already has the content that would be written
+func OnlyIfChanged() WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.onlyIfChanged = true
+	})
+}
+
+// Backup makes WriteFile save the current content of the file, if any, to
+// the path with suffix appended before replacing it. The backup is
+// restored if an AfterWrite hook fails
+func Backup(suffix string) WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.backupSuffix = suffix
+	})
+}
+
+// WriteFileChecked validates the configuration with the given write options
+// and only writes it using WriteFile if no errors were found. Returned
+// error is of type Issues if validation failed
+func (conf *Conf) WriteFileChecked(path string, perm os.FileMode, opts ...WriteOption) error {
+	if err := conf.Validate(opts...).Err(); err != nil {
+		return err
+	}
+	return conf.WriteFile(path, perm, opts...)
+}
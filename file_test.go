@@ -0,0 +1,292 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	err := conf.WriteFile(path, 0644)
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "nameserver 8.8.8.8\n\n", string(b))
+
+	fi, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+
+	// No temp files left behind
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestWriteFileCheckedRefusesEmptyConf(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	conf := resolvconf.New()
+	conf.SetPolicy(resolvconf.RequireNameserver)
+	err := conf.WriteFileChecked(path, 0644)
+	assert.NotNil(t, err)
+	assert.IsType(t, resolvconf.Issues{}, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFileChecked(path, 0644))
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+}
+
+func fakeRoot(t *testing.T, files map[string]string) string {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		assert.Nil(t, ioutil.WriteFile(full, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestReadConfFile(t *testing.T) {
+	root := fakeRoot(t, map[string]string{"/etc/resolv.conf": "nameserver 8.8.8.8\n"})
+	defer os.RemoveAll(root)
+
+	conf, err := resolvconf.ReadConfFile(filepath.Join(root, "etc/resolv.conf"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+
+	conf, err = resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+}
+
+func TestLoadEffectivePrefersSystemdUpstream(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"/etc/resolv.conf":                 "nameserver 127.0.0.53\n",
+		"/run/systemd/resolve/resolv.conf": "nameserver 10.0.0.1\n",
+	})
+	defer os.RemoveAll(root)
+
+	conf, src, err := resolvconf.LoadEffective(resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.SourceSystemdResolved, src)
+	assert.Equal(t, "10.0.0.1", conf.GetNameservers()[0].String())
+
+	// Override probe order
+	conf, src, err = resolvconf.LoadEffective(resolvconf.RootDir(root),
+		resolvconf.ProbeOrder(resolvconf.SourceSystem))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.SourceSystem, src)
+	assert.Equal(t, "127.0.0.53", conf.GetNameservers()[0].String())
+}
+
+func TestLoadEffectiveFallbacks(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"/etc/resolv.conf":            "nameserver 10.0.0.1\n",
+		"/run/resolvconf/resolv.conf": "nameserver 10.0.0.2\n",
+	})
+	defer os.RemoveAll(root)
+	_, src, err := resolvconf.LoadEffective(resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.SourceResolvconf, src)
+
+	os.Remove(filepath.Join(root, "run/resolvconf/resolv.conf"))
+	_, src, err = resolvconf.LoadEffective(resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.SourceSystem, src)
+
+	os.Remove(filepath.Join(root, "etc/resolv.conf"))
+	_, _, err = resolvconf.LoadEffective(resolvconf.RootDir(root))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestIsImmutable(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	immutable, err := resolvconf.IsImmutable(path)
+	assert.Nil(t, err)
+	assert.False(t, immutable)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("nameserver 8.8.8.8\n"), 0644))
+	immutable, err = resolvconf.IsImmutable(path)
+	assert.Nil(t, err)
+	assert.False(t, immutable)
+}
+
+func TestImmutableFileError(t *testing.T) {
+	var err error = &resolvconf.ImmutableFileError{Path: "/etc/resolv.conf"}
+	assert.True(t, errors.Is(err, resolvconf.ErrImmutableFile))
+	assert.Equal(t, "File is immutable: /etc/resolv.conf", err.Error())
+}
+
+func TestWriteFileInRootDir(t *testing.T) {
+	root := fakeRoot(t, map[string]string{"/run/systemd/resolve/stub-resolv.conf": "nameserver 127.0.0.53\n"})
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.Symlink("/run/systemd/resolve/stub-resolv.conf", filepath.Join(root, "etc/resolv.conf"))
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	err := conf.WriteFile("/etc/resolv.conf", 0644, resolvconf.RootDir(root))
+	assert.True(t, errors.Is(err, resolvconf.ErrSymlink))
+	assert.Equal(t, "File is a symlink: /etc/resolv.conf -> /run/systemd/resolve/stub-resolv.conf", err.Error())
+	assert.Nil(t, conf.WriteFile("/etc/resolv.conf", 0644, resolvconf.RootDir(root), resolvconf.ReplaceSymlink()))
+
+	// The symlink is replaced, its target left alone
+	b, _ := ioutil.ReadFile(filepath.Join(root, "etc/resolv.conf"))
+	assert.Equal(t, "nameserver 8.8.8.8\n\n", string(b))
+	b, _ = ioutil.ReadFile(filepath.Join(root, "run/systemd/resolve/stub-resolv.conf"))
+	assert.Equal(t, "nameserver 127.0.0.53\n", string(b))
+}
+
+func TestWriteFileFollowSymlink(t *testing.T) {
+	root := fakeRoot(t, map[string]string{"/run/systemd/resolve/stub-resolv.conf": "nameserver 127.0.0.53\n"})
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.Symlink("/run/systemd/resolve/stub-resolv.conf", filepath.Join(root, "etc/resolv.conf"))
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFile("/etc/resolv.conf", 0644, resolvconf.RootDir(root), resolvconf.FollowSymlink()))
+
+	// The target is replaced, the symlink left alone
+	link, err := os.Readlink(filepath.Join(root, "etc/resolv.conf"))
+	assert.Nil(t, err)
+	assert.Equal(t, "/run/systemd/resolve/stub-resolv.conf", link)
+	b, _ := ioutil.ReadFile(filepath.Join(root, "run/systemd/resolve/stub-resolv.conf"))
+	assert.Equal(t, "nameserver 8.8.8.8\n\n", string(b))
+
+	// Dangling symlinks get their target created
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/run/other", nil, 0644))
+	assert.Nil(t, fsys.Symlink("../run/resolv.conf", "/etc/resolv.conf"))
+	assert.Nil(t, conf.WriteFile("/etc/resolv.conf", 0644, resolvconf.WithFileSystem(fsys), resolvconf.FollowSymlink()))
+	b, err = fsys.ReadFile("/run/resolv.conf")
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 8.8.8.8\n\n", string(b))
+}
+
+func TestWriteFileKeepsMode(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFile(path, 0))
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), fi.Mode())
+
+	assert.Nil(t, os.Chmod(path, 0640))
+	assert.Nil(t, conf.WriteFile(path, 0))
+	fi, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode())
+}
+
+func TestWriteFileOwnTempFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	// Another writer's file is not touched, none of ours is left
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ".resolv.conf.tmp"), []byte("nameser"), 0600))
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.Backup(".bak")))
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.Backup(".bak")))
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	var names []string
+	for _, fi := range files {
+		names = append(names, fi.Name())
+	}
+	assert.Equal(t, []string{".resolv.conf.tmp", "resolv.conf", "resolv.conf.bak"}, names)
+	b, _ := ioutil.ReadFile(filepath.Join(dir, ".resolv.conf.tmp"))
+	assert.Equal(t, "nameser", string(b))
+}
+
+func TestWriteFileConcurrentWriters(t *testing.T) {
+	// Each writer has its own temporary file, the result is one of them
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conf := resolvconf.New()
+			conf.Add(resolvconf.NewNameserver(net.IPv4(10, 0, 0, byte(i+1))))
+			assert.Nil(t, conf.WriteFile(path, 0644))
+		}(i)
+	}
+	wg.Wait()
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	conf, err := resolvconf.ReadConfFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+}
+
+func TestReadSystemConfAndSave(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/run/resolv.conf", []byte("# managed\nnameserver 10.0.0.1\n"), 0640))
+	assert.Nil(t, fsys.Symlink("/run/resolv.conf", "/etc/resolv.conf"))
+
+	conf, err := resolvconf.ReadSystemConf(resolvconf.WithFileSystem(fsys))
+	assert.Nil(t, err)
+	assert.Equal(t, "/etc/resolv.conf", conf.Source())
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	assert.Nil(t, conf.Save())
+
+	// Written through the symlink, keeping the mode
+	b, _ := fsys.ReadFile("/run/resolv.conf")
+	assert.Equal(t, "# managed\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n", string(b))
+	fi, _ := fsys.Lstat("/etc/resolv.conf")
+	assert.True(t, fi.Mode()&os.ModeSymlink != 0)
+	fi, _ = fsys.Stat("/run/resolv.conf")
+	assert.Equal(t, os.FileMode(0640), fi.Mode())
+
+	assert.True(t, errors.Is(resolvconf.New().Save(), resolvconf.ErrNoSource))
+}
+
+func TestReadPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("nameserver 10.0.0.1\n"), 0644))
+
+	conf, err := resolvconf.ReadPath(path)
+	assert.Nil(t, err)
+	assert.Equal(t, path, conf.Source())
+	conf, err = resolvconf.ReadSystemConf(resolvconf.SystemConfPath(path))
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", conf.GetNameservers()[0].String())
+
+	// A missing file is not a parse error
+	_, err = resolvconf.ReadSystemConf(resolvconf.SystemConfPath(filepath.Join(dir, "missing")))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	var pe *resolvconf.ParseError
+	assert.False(t, errors.As(err, &pe))
+	_, err = resolvconf.ReadPath(filepath.Join(dir, "missing"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,250 @@
+package resolvconf
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Report describes what an operation returning a modified copy of a Conf,
+// or modifying it like Normalize, changed
+type Report struct {
+	Removed         []ConfItem // Items dropped from the copy
+	Added           []ConfItem // Items added to the copy
+	Changed         []Change   // Items given another value, e.g. by Normalize
+	FallbackApplied bool       // True if fallback nameservers were added
+}
+
+// FilterOption customizes the nameserver filters
+type FilterOption func(o *filterOptions)
+
+type filterOptions struct {
+	linkLocal bool
+	probe     ConnectivityProbe
+}
+
+// ConnectivityProbe reports whether the host can reach the internet over
+// network, which is either "udp4" or "udp6"
+type ConnectivityProbe func(ctx context.Context, network string) bool
+
+// Well known addresses used by the default connectivity probe, nothing is
+// ever sent to them
+var probeAddrs = map[string]string{
+	"udp4": "8.8.8.8:53",
+	"udp6": "[2001:4860:4860::8888]:53",
+}
+
+// DefaultConnectivityProbe checks for a usable route by doing a UDP
+// connect, which doesn't send any data
+func DefaultConnectivityProbe(ctx context.Context, network string) bool {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, probeAddrs[network])
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// WithConnectivityProbe replaces the probe used by FilterByConnectivity
+func WithConnectivityProbe(p ConnectivityProbe) FilterOption {
+	return func(o *filterOptions) {
+		o.probe = p
+	}
+}
+
+// RemoveLinkLocal makes FilterForContainer also remove link-local
+// nameservers, e.g. fe80::1 or 169.254.169.254
+func RemoveLinkLocal() FilterOption {
+	return func(o *filterOptions) {
+		o.linkLocal = true
+	}
+}
+
+func newFilterOptions(opts []FilterOption) *filterOptions {
+	o := &filterOptions{probe: DefaultConnectivityProbe}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// FilterFunc returns a copy of the configuration with only the items for
+// which keep returns true, the configuration itself is not modified. keep
+// is called with the items of the copy, like for RemoveFunc the comment at
+// the end of the line of a dropped item goes with it
+func (conf *Conf) FilterFunc(keep func(ConfItem) bool) *Conf {
+	c := conf.Clone()
+	c.removeIf(func(item ConfItem) bool {
+		return !keep(item)
+	})
+	return c
+}
+
+// AddressFamily selects nameservers and sortlist pairs by the family of
+// their address, IPv4-mapped IPv6 addresses like ::ffff:10.0.0.1 count as
+// IPv4
+type AddressFamily int
+
+// Address families
+const (
+	IPv4 AddressFamily = iota + 1
+	IPv6
+)
+
+// has returns true if ip is of the family
+func (f AddressFamily) has(ip net.IP) bool {
+	switch f {
+	case IPv4:
+		return ip.To4() != nil
+	case IPv6:
+		return ip.To4() == nil && ip.To16() != nil
+	}
+	return false
+}
+
+// FilterFamily returns a copy of the configuration with only the
+// nameservers and sortlist pairs of family keep, e.g. IPv4 for a host
+// without IPv6 connectivity. The other items are kept, the configuration
+// itself is not modified
+func (conf *Conf) FilterFamily(keep AddressFamily) *Conf {
+	return conf.FilterFunc(func(item ConfItem) bool {
+		switch it := item.(type) {
+		case *Nameserver:
+			return keep.has(it.IP)
+		case *SortItem:
+			return keep.has(it.Address)
+		}
+		return true
+	})
+}
+
+// HasIPv4Nameserver returns true if there is a nameserver with an IPv4
+// address, IPv4-mapped IPv6 addresses included
+func (conf *Conf) HasIPv4Nameserver() bool {
+	return conf.hasNameserver(IPv4)
+}
+
+// HasIPv6Nameserver returns true if there is a nameserver with an IPv6
+// address that isn't IPv4-mapped
+func (conf *Conf) HasIPv6Nameserver() bool {
+	return conf.hasNameserver(IPv6)
+}
+
+func (conf *Conf) hasNameserver(f AddressFamily) bool {
+	conf.rlock()
+	defer conf.runlock()
+	for _, item := range conf.items {
+		if ns, ok := item.(*Nameserver); ok && f.has(ns.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterForContainer returns a copy of the configuration suitable for use
+// inside a container network namespace, where the loopback nameservers of
+// the host can't be reached. If no nameserver is left the fallback servers
+// are added instead, no public resolvers are added unless given. The
+// original configuration is not modified
+func (conf *Conf) FilterForContainer(fallback []net.IP, opts ...FilterOption) (*Conf, Report) {
+	o := newFilterOptions(opts)
+	c := conf.Clone()
+	c.lock()
+	defer c.unlock()
+	return c, c.filterForContainer(fallback, o.linkLocal)
+}
+
+// filterForContainer removes the nameservers FilterForContainer drops and
+// adds fallback if none is left. The Conf must be locked
+func (conf *Conf) filterForContainer(fallback []net.IP, linkLocal bool) Report {
+	var rep Report
+	// The comment at the end of the line of a removed nameserver goes with it
+	conf.dropIf(func(item ConfItem) bool {
+		ns, ok := item.(*Nameserver)
+		if ok && (ns.IP.IsLoopback() || (linkLocal && ns.IP.IsLinkLocalUnicast())) {
+			rep.Removed = append(rep.Removed, ns)
+			return true
+		}
+		return false
+	})
+	if len(rep.Removed) > 0 && conf.logger.enabled(LogDebug) {
+		conf.logger.l.Debug(fmt.Sprintf("Removed %d nameservers not reachable from a container", len(rep.Removed)),
+			"op", "remove", "count", len(rep.Removed))
+	}
+
+	if conf.count(kindNameserver) == 0 {
+		for _, ip := range fallback {
+			ns := NewNameserver(ip)
+			if conf.addItems([]ConfItem{ns}) == nil {
+				rep.Added = append(rep.Added, ns)
+				rep.FallbackApplied = true
+			}
+		}
+	}
+	return rep
+}
+
+// FilterLoopbackNameservers removes the nameservers with a loopback
+// address, e.g. 127.0.0.53 of systemd-resolved or ::1, and returns how
+// many. These can't be reached from another network namespace, e.g. of a
+// container. Like for FilterForContainer the fallback servers are added if
+// no nameserver is left, if there is still none an error wrapping
+// ErrNoNameservers is returned
+func (conf *Conf) FilterLoopbackNameservers(fallback ...net.IP) (int, error) {
+	conf.lock()
+	defer conf.unlock()
+	rep := conf.filterForContainer(fallback, false)
+	return len(rep.Removed), rep.noNameservers(conf)
+}
+
+// WithoutLoopbackNameservers returns a copy of the configuration without
+// the nameservers with a loopback address like FilterLoopbackNameservers,
+// the configuration itself is not modified. The copy is returned with the
+// error if no nameserver is left
+func (conf *Conf) WithoutLoopbackNameservers(fallback ...net.IP) (*Conf, error) {
+	c, rep := conf.FilterForContainer(fallback)
+	return c, rep.noNameservers(c)
+}
+
+// noNameservers returns an error if the filter that made rep left no
+// nameserver in conf
+func (rep Report) noNameservers(conf *Conf) error {
+	if len(rep.Removed) == 0 || conf.count(kindNameserver) > 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: all %d nameservers have a loopback address", ErrNoNameservers, len(rep.Removed))
+}
+
+// FilterByConnectivity returns a copy of the configuration without the
+// nameservers of address families the host has no route for, so that
+// queries don't wait for timeouts on unreachable servers. IPv4-mapped IPv6
+// addresses count as IPv4. If no nameserver would be left the copy keeps
+// all of them. The original configuration is not modified
+func (conf *Conf) FilterByConnectivity(ctx context.Context, opts ...FilterOption) (*Conf, error) {
+	o := newFilterOptions(opts)
+	reachable := make(map[bool]bool) // Keyed on is IPv4
+	for _, network := range []string{"udp4", "udp6"} {
+		ok := o.probe(ctx, network)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("Probing %s connectivity: %w", network, err)
+		}
+		reachable[network == "udp4"] = ok
+	}
+
+	c := conf.Clone()
+	unreachable := func(item ConfItem) bool {
+		ns, ok := item.(*Nameserver)
+		return ok && !reachable[ns.IP.To4() != nil]
+	}
+	removed := 0
+	for _, item := range c.items {
+		if unreachable(item) {
+			removed++
+		}
+	}
+	if removed < c.count(kindNameserver) {
+		c.dropIf(unreachable)
+	}
+	return c, nil
+}
@@ -0,0 +1,228 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestFilterForContainer(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 127.0.0.53\nnameserver 10.0.0.1\nnameserver ::1\nsearch foo.com"))
+	c, rep := conf.FilterForContainer(nil)
+	assert.Equal(t, 1, len(c.GetNameservers()))
+	assert.Equal(t, "10.0.0.1", c.GetNameservers()[0].String())
+	assert.Equal(t, 2, len(rep.Removed))
+	assert.False(t, rep.FallbackApplied)
+	assert.Equal(t, 1, len(c.GetSearchDomains()))
+
+	// Original untouched
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+}
+
+func TestFilterForContainerTrailingComment(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 1.1.1.1\nnameserver 127.0.0.53 # systemd stub\nnameserver 10.0.0.1 # lab\n"))
+	c, rep := conf.FilterForContainer(nil)
+	assert.Equal(t, 1, len(rep.Removed))
+	assert.Equal(t, "nameserver 1.1.1.1\nnameserver 10.0.0.1 # lab\n", c.String())
+}
+
+func TestFilterForContainerFallback(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 127.0.0.53"))
+	c, rep := conf.FilterForContainer(nil)
+	assert.Equal(t, 0, len(c.GetNameservers()))
+	assert.False(t, rep.FallbackApplied)
+
+	c, rep = conf.FilterForContainer([]net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")})
+	assert.Equal(t, 2, len(c.GetNameservers()))
+	assert.True(t, rep.FallbackApplied)
+	assert.Equal(t, 2, len(rep.Added))
+}
+
+func TestFilterForContainerLinkLocal(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 169.254.169.254\nnameserver 10.0.0.1"))
+	c, _ := conf.FilterForContainer(nil)
+	assert.Equal(t, 2, len(c.GetNameservers()))
+	c, rep := conf.FilterForContainer(nil, resolvconf.RemoveLinkLocal())
+	assert.Equal(t, 1, len(c.GetNameservers()))
+	assert.Equal(t, "169.254.169.254", rep.Removed[0].String())
+}
+
+func probeOnly(networks ...string) resolvconf.FilterOption {
+	return resolvconf.WithConnectivityProbe(func(ctx context.Context, network string) bool {
+		for _, n := range networks {
+			if n == network {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestFilterByConnectivity(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 2001:db8::1\nnameserver 10.0.0.1\nnameserver ::ffff:10.0.0.2"))
+	c, err := conf.FilterByConnectivity(context.Background(), probeOnly("udp4"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(c.GetNameservers()))
+	assert.Equal(t, "10.0.0.1", c.GetNameservers()[0].String())
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+
+	c, err = conf.FilterByConnectivity(context.Background(), probeOnly("udp6"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(c.GetNameservers()))
+	assert.Equal(t, "2001:db8::1", c.GetNameservers()[0].String())
+}
+
+func TestFilterByConnectivityTrailingComment(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\nnameserver 2001:db8::1 # v6 only\n"))
+	c, err := conf.FilterByConnectivity(context.Background(), probeOnly("udp4"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(c.GetNameservers()))
+	assert.NotContains(t, c.String(), "v6 only")
+}
+
+func TestFilterByConnectivityKeepsLastServer(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 2001:db8::1"))
+	c, err := conf.FilterByConnectivity(context.Background(), probeOnly("udp4"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(c.GetNameservers()))
+}
+
+func TestFilterByConnectivityCancelled(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := conf.FilterByConnectivity(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestRemoveFuncAndFilterFunc(t *testing.T) {
+	in := "# Managed\nnameserver 10.1.0.1\nnameserver 192.168.1.1 # home\nnameserver 10.2.0.1\n" +
+		"domain lab.corp\nsearch a.corp example.com b.corp\n"
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	inPrivate := func(item resolvconf.ConfItem) bool {
+		ns, ok := item.(*resolvconf.Nameserver)
+		return ok && private.Contains(ns.IP)
+	}
+	corp := func(item resolvconf.ConfItem) bool {
+		switch it := item.(type) {
+		case *resolvconf.SearchDomain:
+			return strings.HasSuffix(it.Name, ".corp")
+		case *resolvconf.Domain:
+			return strings.HasSuffix(it.Name, ".corp")
+		}
+		return false
+	}
+
+	conf, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Nil(t, err)
+	filtered := conf.FilterFunc(func(item resolvconf.ConfItem) bool { return !inPrivate(item) })
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+	assert.Equal(t, 1, len(filtered.GetNameservers()))
+	assert.Equal(t, 2, len(filtered.GetComments()))
+
+	assert.Equal(t, 2, conf.RemoveFunc(inPrivate))
+	assert.Equal(t, 3, conf.RemoveFunc(corp))
+	assert.Equal(t, 0, conf.RemoveFunc(corp))
+	assert.Equal(t, "# Managed\nnameserver 192.168.1.1 # home\nsearch example.com\n", conf.String())
+
+	// Comments are items too, the one at the end of a removed line goes with it
+	assert.Equal(t, 1, conf.RemoveFunc(func(item resolvconf.ConfItem) bool {
+		ns, ok := item.(*resolvconf.Nameserver)
+		return ok && ns.IP.Equal(net.ParseIP("192.168.1.1"))
+	}))
+	assert.Equal(t, 1, len(conf.GetComments()))
+}
+
+func TestFilterLoopbackNameservers(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 127.0.0.53 # resolved\nnameserver 10.0.0.1\nnameserver ::1\nsearch foo.com\n"))
+	n, err := conf.FilterLoopbackNameservers()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	// The comment of a removed nameserver goes with it
+	assert.Equal(t, "nameserver 10.0.0.1\n\nsearch foo.com\n\n", conf.String())
+
+	n, err = conf.FilterLoopbackNameservers()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	// Empty stays empty
+	n, err = resolvconf.New().FilterLoopbackNameservers()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestFilterLoopbackNameserversFallback(t *testing.T) {
+	// Like FilterForContainer, the error tells that none is left
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 127.0.0.1\nnameserver ::1\n"))
+	n, err := conf.FilterLoopbackNameservers()
+	assert.True(t, errors.Is(err, resolvconf.ErrNoNameservers))
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0, len(conf.GetNameservers()))
+
+	conf, _ = resolvconf.ReadConf(strings.NewReader("nameserver 127.0.0.1\nnameserver ::1\n"))
+	n, err = conf.FilterLoopbackNameservers(net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "nameserver 8.8.8.8\nnameserver 8.8.4.4\n\n", conf.String())
+}
+
+func TestWithoutLoopbackNameservers(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver ::1\nnameserver 2001:db8::1\n"))
+	c, err := conf.WithoutLoopbackNameservers()
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 2001:db8::1\n\n", c.String())
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+
+	conf, _ = resolvconf.ReadConf(strings.NewReader("nameserver ::1\n"))
+	c, err = conf.WithoutLoopbackNameservers()
+	assert.True(t, errors.Is(err, resolvconf.ErrNoNameservers))
+	assert.Equal(t, 0, len(c.GetNameservers()))
+	c, err = conf.WithoutLoopbackNameservers(net.ParseIP("2001:4860:4860::8888"))
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 2001:4860:4860::8888\n\n", c.String())
+	assert.Equal(t, "nameserver ::1\n\n", conf.String())
+
+	// The same servers are left as by FilterForContainer
+	fc, _ := conf.FilterForContainer(nil)
+	c, _ = conf.WithoutLoopbackNameservers()
+	assert.Equal(t, fc.String(), c.String())
+}
+
+func TestFilterFamily(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\nnameserver 2001:db8::1\nnameserver ::ffff:10.0.0.2\n" +
+		"domain corp.example\nsortlist 10.0.0.0/255.0.0.0\noptions ndots:2\n"))
+	assert.Nil(t, err)
+	before := conf.String()
+	assert.True(t, conf.HasIPv4Nameserver())
+	assert.True(t, conf.HasIPv6Nameserver())
+
+	v4 := conf.FilterFamily(resolvconf.IPv4)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, nsStrings(v4))
+	assert.False(t, v4.HasIPv6Nameserver())
+	assert.Equal(t, "corp.example", v4.GetDomain().Name)
+	assert.Equal(t, 1, len(v4.GetSortItems()))
+	assert.True(t, v4.HasOption("ndots"))
+
+	v6 := conf.FilterFamily(resolvconf.IPv6)
+	assert.Equal(t, []string{"2001:db8::1"}, nsStrings(v6))
+	assert.False(t, v6.HasIPv4Nameserver())
+	assert.Empty(t, v6.GetSortItems())
+	assert.Equal(t, "corp.example", v6.GetDomain().Name)
+	assert.Empty(t, v6.Validate().Err())
+
+	assert.Equal(t, before, conf.String())
+	assert.False(t, resolvconf.New().HasIPv4Nameserver())
+	assert.False(t, resolvconf.New().HasIPv6Nameserver())
+}
+
+func nsStrings(conf *resolvconf.Conf) []string {
+	var s []string
+	for _, ns := range conf.GetNameservers() {
+		s = append(s, ns.IP.String())
+	}
+	return s
+}
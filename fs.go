@@ -0,0 +1,113 @@
+package resolvconf
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem is the file system used to read and write configuration
+// files, see WithFileSystem. Paths are slash separated and absolute
+type FileSystem interface {
+	Open(name string) (io.ReadCloser, error)
+	ReadFile(name string) ([]byte, error)
+	// WriteFile creates or truncates name, which must end up with
+	// exactly the permissions perm. The data should be on stable storage
+	// when it returns
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+}
+
+// OSFileSystem is the FileSystem of the host, used by default
+type OSFileSystem struct{}
+
+// Open implements FileSystem
+func (OSFileSystem) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// ReadFile implements FileSystem
+func (OSFileSystem) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+// WriteFile implements FileSystem
+func (OSFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	// Not subject to the umask, unlike OpenFile
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Rename implements FileSystem, the directory is synced so the rename
+// survives a crash
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	if err := os.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	if d, err := os.Open(filepath.Dir(newpath)); err == nil {
+		// Not supported by all file systems, the rename is done anyway
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// Remove implements FileSystem
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// Stat implements FileSystem
+func (OSFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Lstat implements FileSystem
+func (OSFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// Readlink implements FileSystem
+func (OSFileSystem) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+type fileSystem struct {
+	fs FileSystem
+}
+
+func (f fileSystem) applyFile(o *fileOptions) {
+	o.fs = f.fs
+}
+
+func (f fileSystem) applyWrite(o *writeOptions) {
+	o.fs = f.fs
+}
+
+// WithFileSystem makes ReadConfFile, LoadEffective, ResolveSystemPath,
+// DetectManager and WriteFile use fsys instead of the host file system,
+// e.g. a MemFS in tests. The immutable attribute is only checked on the
+// host file system
+func WithFileSystem(fsys FileSystem) PathOption {
+	return fileSystem{fsys}
+}
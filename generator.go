@@ -1,32 +1,650 @@
 package resolvconf
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"strings"
+	"sync/atomic"
 	"text/template"
 )
 
 var templates = map[string]string{
 	"domain":     "{{if .GetDomain.Name}}domain {{ .GetDomain.Name }}\n{{end}}",
-	"Nameserver": "{{if .GetNameservers}}{{range $nameserver := .GetNameservers}}nameserver {{$nameserver.IP}}\n{{end}}\n{{end}}",
-	"options":    "{{if .GetOptions}}options{{range $opt := .GetOptions}} {{$opt}}{{end}}\n\n{{end}}",
-	"sortlist":   "{{if .GetSortItems}}sortlist{{range $pair := .GetSortItems}} {{$pair}}{{end}}\n\n{{end}}",
-	"search":     "{{if .GetSearchDomains}}search{{range $dom := .GetSearchDomains}} {{$dom.Name}}{{end}}\n\n{{end}}",
+	"Nameserver": "{{if .GetNameservers}}{{range $nameserver := .GetNameservers}}nameserver {{$nameserver}}\n{{end}}\n{{end}}",
+	"options":    "{{if .GetOptions}}{{range $line := .OptionLines}}{{$line}}\n{{end}}\n{{end}}",
+	"sortlist":   "{{if .GetSortItems}}{{range $line := .SortlistLines}}{{$line}}\n{{end}}\n{{end}}",
+	"search":     "{{if .GetSearchDomains}}{{range $line := .SearchLines}}{{$line}}\n{{end}}\n{{end}}",
+	"lookup":     "{{if .GetLookup.Sources}}lookup {{ .GetLookup }}\n{{end}}",
+	"family":     "{{if .GetFamily.Families}}family {{ .GetFamily }}\n{{end}}",
+}
+
+// WriteOption customizes how a configuration is rendered by Write,
+// WriteFile and Validate
+type WriteOption interface {
+	applyWrite(o *writeOptions)
+}
+
+type writeOptions struct {
+	nameserverFilter  func(Nameserver) bool
+	limitNameservers  bool
+	respectGenerators bool
+	forceGenerators   bool
+	comments          []string // Written as a header
+	root              string
+	onlyIfChanged     bool
+	backupSuffix      string
+	symlinks          symlinkPolicy
+	warnings          *[]Warning // Set by CollectWarnings
+	afterWrite        []func(ctx context.Context, path string) error
+	fs                FileSystem
+	format            Formatting
+	patch             bool    // Set by Patch
+	dryRun            *[]byte // Set by DryRun
+}
+
+type writeOptionFunc func(o *writeOptions)
+
+func (f writeOptionFunc) applyWrite(o *writeOptions) {
+	f(o)
+}
+
+// cacheable returns true if the output is the same as without options
+// and may be served from the render cache
+func (o *writeOptions) cacheable() bool {
+	return o.nameserverFilter == nil && !o.limitNameservers && len(o.comments) == 0 && o.format == Formatting{}
+}
+
+func newWriteOptions(opts []WriteOption) *writeOptions {
+	o := &writeOptions{fs: OSFileSystem{}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyWrite(o)
+		}
+	}
+	return o
+}
+
+// FilterNameservers only writes the nameservers for which keep returns
+// true, the configuration itself is not modified
+func FilterNameservers(keep func(Nameserver) bool) WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.nameserverFilter = keep
+	})
+}
+
+// LimitNameservers only writes as many nameservers as the limit of the
+// Conf allows, the first ones are written. It has no effect without
+// limits, e.g. with ProfileNone, and is only useful with LimitPermissive
+// since otherwise Add doesn't accept more
+func LimitNameservers() WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.limitNameservers = true
+	})
+}
+
+// WriteOrder selects the order in which Write and String write the items
+type WriteOrder int
+
+// Write orders
+const (
+	// OrderAuto writes the items in insertion order if the configuration
+	// has comments or raw lines, so these stay in place, and grouped by
+	// kind otherwise: domain, nameservers, sortlist, search, options and
+	// the OpenBSD lookup and family. This is the default
+	OrderAuto WriteOrder = iota
+	// OrderCanonical always writes the items grouped by kind, nameservers,
+	// domain, search, sortlist, options, lookup and family, each in
+	// insertion order. The comments before the first item are written
+	// first and the raw lines last, other comments are left out
+	OrderCanonical
+	// OrderInsertion always writes the items in the order they were read
+	// or added, see Items
+	OrderInsertion
+)
+
+// SetWriteOrder sets the order in which Write and String write the items
+func (conf *Conf) SetWriteOrder(o WriteOrder) {
+	conf.lock()
+	defer conf.unlock()
+	conf.order = o
+	conf.cache.reset()
+}
+
+// GetWriteOrder returns the current write order
+func (conf *Conf) GetWriteOrder() WriteOrder {
+	return conf.order
+}
+
+// Formatting is a WriteOption changing the layout of the lines written,
+// the zero value is the default layout
+type Formatting struct {
+	// SearchPerLine writes a search line per domain rather than one line
+	// with all of them. Note that glibc only reads the last search line
+	SearchPerLine bool
+	// OptionsPerLine writes an options line per option rather than one
+	// line with all of them, glibc combines them
+	OptionsPerLine bool
+	// SortlistPrefixLen writes the netmasks of the sortlist as prefix
+	// lengths, e.g. 10.0.0.0/8. Netmasks that are not a prefix are written
+	// as is. ReadConf reads both forms but glibc only the netmask
+	SortlistPrefixLen bool
+	// NoTrailingNewline leaves out the newlines at the end of the output
+	NoTrailingNewline bool
+}
+
+func (f Formatting) applyWrite(o *writeOptions) {
+	o.format = f
+}
+
+// renderSet is the set of items that will actually be written, e.g. after
+// all write options have been applied
+type renderSet struct {
+	domain        Domain
+	nameservers   []Nameserver
+	sortItems     []SortItem
+	searchDomains []SearchDomain
+	options       []Option
+	lookup        Lookup
+	family        Family
+	filtered      []Nameserver // Left out by the write options
+	truncated     []Nameserver // Left out by LimitNameservers
+	format        Formatting
+	items         []ConfItem // All items but the header read back
+	invalid       []string   // Left out as they can't be written, see invalidItem
+}
+
+func (conf *Conf) renderSet(o *writeOptions) *renderSet {
+	rs := &renderSet{
+		format: o.format,
+		items:  conf.items[conf.headerLen():],
+	}
+	var nameservers []Nameserver
+	for _, item := range conf.items {
+		if what := invalidItem(item, conf.unknown); what != "" {
+			rs.invalid = append(rs.invalid, what)
+			continue
+		}
+		switch it := item.(type) {
+		case *Nameserver:
+			nameservers = append(nameservers, *it)
+		case *Domain:
+			if rs.domain.Name == "" {
+				rs.domain = *it
+			}
+		case *SortItem:
+			rs.sortItems = append(rs.sortItems, *it)
+		case *SearchDomain:
+			rs.searchDomains = append(rs.searchDomains, *it)
+		case *Option:
+			rs.options = append(rs.options, *it)
+		case *Lookup:
+			rs.lookup = *it
+		case *Family:
+			rs.family = *it
+		}
+	}
+	limit := 0
+	if o.limitNameservers && conf.limited() {
+		limit = conf.nsLimit
+	}
+	for _, ns := range nameservers {
+		switch {
+		case o.nameserverFilter != nil && !o.nameserverFilter(ns):
+			rs.filtered = append(rs.filtered, ns)
+		case limit > 0 && len(rs.nameservers) == limit:
+			rs.truncated = append(rs.truncated, ns)
+		default:
+			rs.nameservers = append(rs.nameservers, ns)
+		}
+	}
+	return rs
+}
+
+// invalidItem describes item if it can't be written, e.g. a nameserver
+// without address, and returns "" otherwise. Such items can only be made
+// by changing them after they were added, they are left out by Write with
+// a note. Options of unknown type are valid if unknown is set
+func invalidItem(item ConfItem, unknown bool) string {
+	switch it := item.(type) {
+	case *Nameserver:
+		if it.IP == nil {
+			return "nameserver without address"
+		}
+	case *Domain:
+		if hasSpace(it.Name) {
+			return fmt.Sprintf("domain %q with whitespace", it.Name)
+		}
+	case *SearchDomain:
+		if it.Name == "" {
+			return "search domain without name"
+		}
+		if hasSpace(it.Name) {
+			return fmt.Sprintf("search domain %q with whitespace", it.Name)
+		}
+	case *SortItem:
+		if it.Address == nil {
+			return "sortlist pair without address"
+		}
+	case *Option:
+		if _, ok := lookupOption(it.Type); !ok && !(unknown && validUnknownOption(it.Type)) {
+			return fmt.Sprintf("unknown option %q", it.Type)
+		}
+	case *Lookup:
+		if checkTokens("lookup", it.Sources, lookupSources) != nil {
+			return fmt.Sprintf("lookup %q with invalid sources", strings.Join(it.Sources, " "))
+		}
+	case *Family:
+		if checkTokens("family", it.Families, familyNames) != nil {
+			return fmt.Sprintf("family %q with invalid families", strings.Join(it.Families, " "))
+		}
+	}
+	return ""
+}
+
+func hasSpace(s string) bool {
+	return strings.ContainsAny(s, " \t\r\n")
+}
+
+// GetDomain is used by the templates
+func (rs *renderSet) GetDomain() Domain { return rs.domain }
+
+// GetNameservers is used by the templates
+func (rs *renderSet) GetNameservers() []Nameserver { return rs.nameservers }
+
+// GetSortItems is used by the templates
+func (rs *renderSet) GetSortItems() []SortItem { return rs.sortItems }
+
+// GetSearchDomains is used by the templates
+func (rs *renderSet) GetSearchDomains() []SearchDomain { return rs.searchDomains }
+
+// GetOptions is used by the templates
+func (rs *renderSet) GetOptions() []Option { return rs.options }
+
+// GetLookup is used by the templates
+func (rs *renderSet) GetLookup() Lookup { return rs.lookup }
+
+// GetFamily is used by the templates
+func (rs *renderSet) GetFamily() Family { return rs.family }
+
+// SearchLines is used by the templates
+func (rs *renderSet) SearchLines() []string {
+	fields := make([]string, len(rs.searchDomains))
+	for i, sd := range rs.searchDomains {
+		fields[i] = sd.Name
+	}
+	return formatLines("search", fields, rs.format.SearchPerLine)
+}
+
+// OptionLines is used by the templates
+func (rs *renderSet) OptionLines() []string {
+	fields := make([]string, len(rs.options))
+	for i, opt := range rs.options {
+		fields[i] = opt.String()
+	}
+	return formatLines("options", fields, rs.format.OptionsPerLine)
+}
+
+// SortlistLines is used by the templates
+func (rs *renderSet) SortlistLines() []string {
+	fields := make([]string, len(rs.sortItems))
+	for i, si := range rs.sortItems {
+		fields[i] = rs.sortPair(si)
+	}
+	return formatLines("sortlist", fields, false)
+}
+
+// sortPair renders a sortlist pair, with a prefix length if formatted so
+func (rs *renderSet) sortPair(si SortItem) string {
+	if rs.format.SortlistPrefixLen {
+		if cidr, ok := si.CIDR(); ok {
+			return cidr
+		}
+	}
+	return si.String()
+}
+
+// formatLines returns the line of keyword with fields, or a line for each
+// field if perLine
+func formatLines(keyword string, fields []string, perLine bool) []string {
+	if !perLine {
+		return []string{keyword + " " + strings.Join(fields, " ")}
+	}
+	lines := make([]string, len(fields))
+	for i, f := range fields {
+		lines[i] = keyword + " " + f
+	}
+	return lines
 }
 
 // Write configuration to an io.Writer
 //
 // return an error if unsuccessful
-func (conf *Conf) Write(w io.Writer) error {
-	for _, key := range []string{"domain", "Nameserver", "sortlist", "search", "options"} {
+func (conf *Conf) Write(w io.Writer, opts ...WriteOption) error {
+	_, err := conf.writeTo(w, newWriteOptions(opts))
+	return err
+}
+
+// WriteTo writes the configuration to w like Write without options. It
+// implements io.WriterTo, the returned count is the number of bytes w
+// accepted, also when it failed part way
+func (conf *Conf) WriteTo(w io.Writer) (int64, error) {
+	return conf.writeTo(w, &writeOptions{})
+}
+
+// writeTo renders the whole configuration into memory and hands it to w
+// in one Write call, so a failing w sees no further writes and the error
+// can tell which line could not be written
+func (conf *Conf) writeTo(w io.Writer, o *writeOptions) (int64, error) {
+	b, err := conf.renderBytes(o)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return int64(n), fmt.Errorf("Writing %q after %d of %d bytes: %w", lineAt(b, n), n, len(b), err)
+	}
+	return int64(n), nil
+}
+
+// renderBytes renders the configuration with the options, the items are
+// locked while rendering but not while w is written to
+func (conf *Conf) renderBytes(o *writeOptions) ([]byte, error) {
+	conf.rlock()
+	defer conf.runlock()
+	if o.cacheable() {
+		return conf.rendered()
+	}
+	var buf bytes.Buffer
+	if err := conf.render(&buf, o); err != nil {
+		return nil, err
+	}
+	if o.format.NoTrailingNewline {
+		return bytes.TrimRight(buf.Bytes(), "\n"), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// lineAt returns the line of b at offset n, if it is blank the last non
+// blank line before it
+func lineAt(b []byte, n int) string {
+	if n >= len(b) {
+		n = len(b) - 1
+	}
+	for ; n >= 0; n-- {
+		start := bytes.LastIndexByte(b[:n], '\n') + 1
+		end := n
+		if i := bytes.IndexByte(b[n:], '\n'); i >= 0 {
+			end += i
+		} else {
+			end = len(b)
+		}
+		if line := bytes.TrimSpace(b[start:end]); len(line) > 0 {
+			return string(line)
+		}
+		n = start
+	}
+	return ""
+}
+
+// String returns the configuration as written by Write without options,
+// it implements fmt.Stringer. Items that can't be written, e.g. a
+// nameserver whose address was cleared after it was added, are left out
+// with a comment saying so
+func (conf *Conf) String() string {
+	if conf == nil {
+		return ""
+	}
+	conf.rlock()
+	defer conf.runlock()
+	b, err := conf.rendered()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// render executes the templates, the result isn't cached
+func (conf *Conf) render(w io.Writer, o *writeOptions) error {
+	for _, h := range conf.header {
+		if _, err := io.WriteString(w, h+"\n"); err != nil {
+			return err
+		}
+	}
+	for _, c := range o.comments {
+		if _, err := fmt.Fprintf(w, "# %s\n", c); err != nil {
+			return err
+		}
+	}
+	rs := conf.renderSet(o)
+	warn := func(w Warning) {
+		if o.warnings != nil {
+			*o.warnings = append(*o.warnings, w)
+		} else {
+			conf.warn(w)
+		}
+	}
+	for i := range rs.filtered {
+		ns := &rs.filtered[i]
+		if conf.logger.enabled(LogDebug) {
+			conf.logger.l.Debug(fmt.Sprintf("Not writing nameserver %s, filtered out", ns), "op", "write", "item", ns.String())
+		}
+		warn(Warning{Code: WarnNameserverFiltered, Item: ns, Message: fmt.Sprintf("Nameserver %s is not written, filtered out", ns)})
+	}
+	for i := range rs.truncated {
+		ns := &rs.truncated[i]
+		if conf.logger.enabled(LogDebug) {
+			conf.logger.l.Debug(fmt.Sprintf("Not writing nameserver %s, beyond the limit of %d", ns, conf.nsLimit),
+				"op", "write", "item", ns.String(), "max", conf.nsLimit)
+		}
+		warn(Warning{Code: WarnNameserverFiltered, Item: ns,
+			Message: fmt.Sprintf("Nameserver %s is not written, beyond the limit of %d", ns, conf.nsLimit)})
+	}
+	var err error
+	switch {
+	case conf.order == OrderInsertion, conf.order == OrderAuto && hasLines(rs.items):
+		err = conf.renderInOrder(w, rs)
+	case conf.order == OrderCanonical:
+		err = conf.renderCanonical(w, rs)
+	default:
+		err = renderSections(w, rs, "domain", "Nameserver", "sortlist", "search", "options", "lookup", "family")
+	}
+	if err != nil {
+		return err
+	}
+	for _, what := range rs.invalid {
+		if _, err := fmt.Fprintf(w, "# Left out %s\n", what); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderSections writes the sections of the templates with the given keys
+func renderSections(w io.Writer, rs *renderSet, keys ...string) error {
+	for _, key := range keys {
 		tmpl, err := template.New(key).Parse(templates[key])
 		if err != nil {
 			return err
 		}
 
-		if err := tmpl.Execute(w, conf); err != nil {
+		if err := tmpl.Execute(w, rs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderCanonical writes the comments before the first item, then the
+// sections in canonical order and last the raw lines
+func (conf *Conf) renderCanonical(w io.Writer, rs *renderSet) error {
+	var raw []string
+	header := true
+	for _, item := range rs.items {
+		switch it := item.(type) {
+		case *Comment:
+			if header {
+				if _, err := io.WriteString(w, it.Text+"\n"); err != nil {
+					return err
+				}
+			}
+			continue
+		case *RawLine:
+			raw = append(raw, it.Text)
+		}
+		header = false
+	}
+	if err := renderSections(w, rs, "Nameserver", "domain", "search", "sortlist", "options", "lookup", "family"); err != nil {
+		return err
+	}
+	for _, text := range raw {
+		if _, err := io.WriteString(w, text+"\n"); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
+// renderInOrder writes the items in the order they were added, so the
+// comments and raw lines of a file read by ReadConf stay where they were.
+// Search domains, sortlist pairs and options are written on one line at
+// the position of the first of their kind. A trailing comment is written
+// at the end of the line of the item before it, and not at all if that
+// item isn't written
+func (conf *Conf) renderInOrder(w io.Writer, rs *renderSet) error {
+	type line struct {
+		text, comment string
+	}
+	var lines []line
+	merged := make(map[itemKind]int) // Line of the kinds written on one line
+	last := -1                       // Line of the last item, -1 if not written
+	next := 0                        // Next nameserver of rs to write
+	for _, item := range rs.items {
+		if invalidItem(item, conf.unknown) != "" {
+			last = -1
+			continue
+		}
+		switch it := item.(type) {
+		case *Comment:
+			if !it.Trailing {
+				lines = append(lines, line{text: it.Text})
+				last = len(lines) - 1
+			} else if last >= 0 {
+				lines[last].comment += " " + it.Text
+			}
+			continue
+		case *Nameserver:
+			// rs has the written nameservers in item order
+			if next == len(rs.nameservers) || !rs.nameservers[next].Equal(it) {
+				last = -1
+				continue
+			}
+			next++
+		case *Domain:
+			if it.Name == "" {
+				last = -1
+				continue
+			}
+		case *SearchDomain, *SortItem, *Option:
+			text, field := itemLine(item), item.String()
+			if si, ok := item.(*SortItem); ok {
+				field = rs.sortPair(*si)
+				text = "sortlist " + field
+			}
+			kind := keyOf(item).kind
+			perLine := kind == kindSearchDomain && rs.format.SearchPerLine || kind == kindOption && rs.format.OptionsPerLine
+			if l, ok := merged[kind]; ok && !perLine {
+				lines[l].text += " " + field
+				last = l
+				continue
+			}
+			merged[kind] = len(lines)
+			lines = append(lines, line{text: text})
+			last = len(lines) - 1
+			continue
+		}
+		lines = append(lines, line{text: itemLine(item)})
+		last = len(lines) - 1
+	}
+	for _, l := range lines {
+		if _, err := io.WriteString(w, l.text+l.comment+"\n"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// renderCache holds the output of Write without options and the last
+// Snapshot, it is reset by every change to the items. Reads and resets
+// are atomic so concurrent calls to String and Write don't race on it
+//
+// Items handed out by Find may be changed at any time, once that happened
+// the cache is disabled for good
+type renderCache struct {
+	v        atomic.Value // []byte, nil if stale
+	snap     atomic.Value // *Snapshot, nil if stale
+	disabled int32
+}
+
+func (c *renderCache) load() []byte {
+	if c == nil || atomic.LoadInt32(&c.disabled) != 0 {
+		return nil
+	}
+	b, _ := c.v.Load().([]byte)
+	return b
+}
+
+func (c *renderCache) store(b []byte) {
+	if c != nil && atomic.LoadInt32(&c.disabled) == 0 {
+		c.v.Store(b)
+	}
+}
+
+func (c *renderCache) disable() {
+	if c != nil {
+		atomic.StoreInt32(&c.disabled, 1)
+	}
+}
+
+func (c *renderCache) loadSnapshot() *Snapshot {
+	if c == nil || atomic.LoadInt32(&c.disabled) != 0 {
+		return nil
+	}
+	s, _ := c.snap.Load().(*Snapshot)
+	return s
+}
+
+func (c *renderCache) storeSnapshot(s *Snapshot) {
+	if c != nil && atomic.LoadInt32(&c.disabled) == 0 {
+		c.snap.Store(s)
+	}
+}
+
+func (c *renderCache) reset() {
+	if c != nil && c.load() != nil {
+		c.v.Store([]byte(nil))
+	}
+	if c != nil && c.loadSnapshot() != nil {
+		c.snap.Store((*Snapshot)(nil))
+	}
+}
+
+// rendered returns the output of Write without options, rendering it if
+// the cache is stale. The returned slice must not be modified
+func (conf *Conf) rendered() ([]byte, error) {
+	if b := conf.cache.load(); b != nil {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if err := conf.render(&buf, &writeOptions{}); err != nil {
+		return nil, err
+	}
+	b := append(make([]byte, 0, buf.Len()), buf.Bytes()...)
+	conf.cache.store(b)
+	return b, nil
+}
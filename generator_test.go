@@ -3,8 +3,13 @@ package resolvconf_test
 import (
 	"." // import the main package
 	"bytes"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
 	"net"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -111,3 +116,236 @@ func TestSearchGeneration(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, str, "search foo.bar")
 }
+
+func TestStringIsWriteOutput(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Equal(t, "", conf.String())
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewOption("ndots").Set(2))
+	str, err := GetConf(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, str, conf.String())
+	assert.Equal(t, str, conf.String())
+}
+
+func TestStringGolden(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/string.golden.conf")
+	assert.Nil(t, err)
+	conf := exportConf(t)
+	assert.Equal(t, string(golden), conf.String())
+	str, err := GetConf(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, str, conf.String())
+}
+
+func TestStringLeavesOutInvalidItems(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/string-invalid.golden.conf")
+	assert.Nil(t, err)
+	conf := exportConf(t)
+	conf.Find(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))).(*resolvconf.Nameserver).IP = nil
+	conf.Find(resolvconf.NewSearchDomain("lab.example")).(*resolvconf.SearchDomain).Name = "lab example"
+	conf.Find(resolvconf.NewOption("rotate")).(*resolvconf.Option).Type = "bogus"
+	for _, si := range conf.GetSortItems() {
+		conf.Find(&si).(*resolvconf.SortItem).Address = nil
+	}
+	assert.Equal(t, string(golden), conf.String())
+	str, err := GetConf(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, str, conf.String())
+
+	// Invalid items are dropped from their line when written in order
+	conf.SetWriteOrder(resolvconf.OrderInsertion)
+	assert.Equal(t, "domain corp.example\nnameserver [2001:db8::1]:5353\nsearch corp.example\noptions ndots:2\n"+
+		"# Left out nameserver without address\n# Left out search domain \"lab example\" with whitespace\n"+
+		"# Left out sortlist pair without address\n# Left out unknown option \"bogus\"\n", conf.String())
+}
+
+func TestStringNilAndZeroConf(t *testing.T) {
+	var conf *resolvconf.Conf
+	assert.Equal(t, "", conf.String())
+	assert.Equal(t, "", new(resolvconf.Conf).String())
+}
+
+func TestRenderedOutputFollowsChanges(t *testing.T) {
+	conf := resolvconf.New()
+	ns := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	ns2 := resolvconf.NewNameserver(net.ParseIP("8.8.4.4"))
+	conf.Add(ns, resolvconf.NewOption("ndots").Set(2))
+	assert.Contains(t, conf.String(), "nameserver 8.8.8.8")
+
+	// Add, also when an existing item is only updated
+	conf.Add(ns2)
+	assert.Contains(t, conf.String(), "nameserver 8.8.4.4")
+	conf.Add(resolvconf.NewOption("ndots").Set(4))
+	assert.Contains(t, conf.String(), "ndots:4")
+
+	// Reorder
+	conf.SortNameserversByLatency([]resolvconf.NSHealth{{Nameserver: *ns2, OK: true}})
+	str, _ := GetConf(conf)
+	assert.Equal(t, "nameserver 8.8.4.4\nnameserver 8.8.8.8\n\noptions ndots:4\n\n", str)
+
+	// Remove
+	conf.Remove(ns2)
+	assert.NotContains(t, conf.String(), "8.8.4.4")
+
+	// Items changed through Find
+	conf.Find(resolvconf.NewOption("ndots")).(*resolvconf.Option).Set(1)
+	assert.Contains(t, conf.String(), "ndots:1")
+	conf.Find(resolvconf.NewOption("ndots")).(*resolvconf.Option).Set(3)
+	assert.Contains(t, conf.String(), "ndots:3")
+}
+
+func TestWriteOptionsBypassRenderedOutput(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewNameserver(net.ParseIP("8.8.4.4")))
+	assert.Contains(t, conf.String(), "8.8.4.4")
+
+	buf := new(bytes.Buffer)
+	err := conf.Write(buf, resolvconf.FilterNameservers(func(ns resolvconf.Nameserver) bool {
+		return ns.IP.Equal(net.ParseIP("8.8.8.8"))
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 8.8.8.8\n\n", buf.String())
+	assert.Contains(t, conf.String(), "8.8.4.4")
+}
+
+func TestConcurrentString(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	want, _ := GetConf(conf)
+	conf.Add(resolvconf.NewDomain("example.com"))
+	conf.Remove(resolvconf.NewDomain("example.com"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.Equal(t, want, conf.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// failingWriter accepts n bytes and then fails
+type failingWriter struct {
+	n     int
+	buf   bytes.Buffer
+	calls int
+}
+
+var errWriterFull = errors.New("writer full")
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.calls++
+	if len(b) > w.n {
+		w.buf.Write(b[:w.n])
+		n := w.n
+		w.n = 0
+		return n, errWriterFull
+	}
+	w.n -= len(b)
+	return w.buf.Write(b)
+}
+
+func TestWriteToFailingWriter(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")),
+		resolvconf.NewNameserver(net.ParseIP("8.8.4.4")),
+		resolvconf.NewOption("ndots").Set(2))
+	full, _ := GetConf(conf)
+
+	for _, tc := range []struct {
+		n    int
+		line string
+	}{
+		{0, "nameserver 8.8.8.8"},
+		{5, "nameserver 8.8.8.8"},
+		{20, "nameserver 8.8.4.4"},
+		{len(full) - 3, "options ndots:2"},
+	} {
+		w := &failingWriter{n: tc.n}
+		n, err := conf.WriteTo(w)
+		assert.True(t, errors.Is(err, errWriterFull))
+		assert.Contains(t, err.Error(), tc.line)
+		assert.Equal(t, int64(tc.n), n)
+		assert.Equal(t, full[:tc.n], w.buf.String())
+		assert.Equal(t, 1, w.calls)
+
+		// Write fails the same way, also with options bypassing the cache
+		assert.True(t, errors.Is(conf.Write(&failingWriter{n: tc.n}), errWriterFull))
+		assert.True(t, errors.Is(conf.Write(&failingWriter{n: tc.n}, resolvconf.FilterNameservers(func(resolvconf.Nameserver) bool { return true })), errWriterFull))
+	}
+
+	w := &failingWriter{n: len(full)}
+	n, err := conf.WriteTo(w)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(full)), n)
+	assert.Equal(t, full, w.buf.String())
+}
+
+// shortWriter discards the last byte without an error
+type shortWriter struct{}
+
+func (shortWriter) Write(b []byte) (int, error) {
+	return len(b) - 1, nil
+}
+
+func TestWriteToShortWrite(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	n, err := conf.WriteTo(shortWriter{})
+	assert.True(t, errors.Is(err, io.ErrShortWrite))
+	assert.Equal(t, int64(len("nameserver 8.8.8.8\n\n")-1), n)
+}
+
+func TestWriteOrder(t *testing.T) {
+	in := "# Generated\noptions rotate\nsearch b.com\nnameserver 10.0.0.2 # second\n# Primary\nnameserver 10.0.0.1\ndomain lab.example\nlookup file bind\nsearch c.com a.com\n"
+	conf, _ := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Equal(t, resolvconf.OrderAuto, conf.GetWriteOrder())
+	assert.Equal(t, "# Generated\noptions rotate\nnameserver 10.0.0.2 # second\n# Primary\nnameserver 10.0.0.1\ndomain lab.example\nlookup file bind\nsearch c.com a.com\n", conf.String())
+
+	conf.SetWriteOrder(resolvconf.OrderCanonical)
+	canonical := "# Generated\nnameserver 10.0.0.2\nnameserver 10.0.0.1\n\ndomain lab.example\nsearch c.com a.com\n\noptions rotate\n\nlookup file bind\n"
+	assert.Equal(t, canonical, conf.String())
+	var buf bytes.Buffer
+	assert.Nil(t, conf.Write(&buf))
+	assert.Equal(t, canonical, buf.String())
+
+	// Reading the output back gives the same output
+	again, _ := resolvconf.ReadConf(strings.NewReader(canonical))
+	again.SetWriteOrder(resolvconf.OrderCanonical)
+	assert.Equal(t, canonical, again.String())
+
+	plain := resolvconf.New()
+	plain.Add(resolvconf.NewOption("rotate"), resolvconf.NewSearchDomain("a.com"), resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	assert.Equal(t, "nameserver 10.0.0.1\n\nsearch a.com\n\noptions rotate\n\n", plain.String())
+	plain.SetWriteOrder(resolvconf.OrderInsertion)
+	assert.Equal(t, "options rotate\nsearch a.com\nnameserver 10.0.0.1\n", plain.String())
+}
+
+func TestFormatting(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\nsearch a.com b.com\nsortlist 10.0.0.0/255.0.0.0 10.1.0.0/255.255.0.0 192.168.1.1\noptions ndots:2 rotate\n"))
+	def := conf.String()
+	var buf bytes.Buffer
+	assert.Nil(t, conf.Write(&buf, resolvconf.Formatting{}))
+	assert.Equal(t, def, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, conf.Write(&buf, resolvconf.Formatting{SearchPerLine: true, OptionsPerLine: true, SortlistPrefixLen: true, NoTrailingNewline: true}))
+	out := "nameserver 10.0.0.1\n\nsortlist 10.0.0.0/8 10.1.0.0/16 192.168.1.1\n\nsearch a.com\nsearch b.com\n\noptions ndots:2\noptions rotate"
+	assert.Equal(t, out, buf.String())
+	assert.Equal(t, def, conf.String())
+
+	// Prefix lengths are read back
+	back, err := resolvconf.ReadConf(strings.NewReader(out))
+	assert.Nil(t, err)
+	assert.Equal(t, conf.GetSortItems(), back.GetSortItems())
+
+	// Files with comments keep their layout
+	conf, _ = resolvconf.ReadConf(strings.NewReader("# Top\nsearch a.com b.com # corp\noptions rotate\nsortlist 10.0.0.0/255.255.0.0\n"))
+	buf.Reset()
+	assert.Nil(t, conf.Write(&buf, resolvconf.Formatting{SearchPerLine: true, SortlistPrefixLen: true}))
+	assert.Equal(t, "# Top\nsearch a.com\nsearch b.com # corp\noptions rotate\nsortlist 10.0.0.0/16\n", buf.String())
+}
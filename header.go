@@ -0,0 +1,66 @@
+package resolvconf
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// SetHeader sets comment lines Write writes at the top, e.g. to tell the
+// file is generated. Lines are prefixed with "# " unless they start with
+// a comment marker. The comments at the top of a file read back that match
+// the header, ignoring RFC 3339 timestamps, are not written again. Without
+// lines the header is removed
+func (conf *Conf) SetHeader(lines ...string) {
+	conf.lock()
+	defer conf.unlock()
+	conf.header = nil
+	for _, l := range lines {
+		conf.header = append(conf.header, NewComment(l).Text)
+	}
+	conf.cache.reset()
+}
+
+// GetHeader returns the header lines, with their comment markers
+func (conf *Conf) GetHeader() []string {
+	conf.rlock()
+	defer conf.runlock()
+	return append([]string(nil), conf.header...)
+}
+
+// DefaultHeader returns a header for SetHeader naming tool and the time
+// it was written
+func DefaultHeader(tool string) []string {
+	return DefaultHeaderAt(tool, time.Now())
+}
+
+// DefaultHeaderAt is DefaultHeader with the time given, e.g. for
+// reproducible output
+func DefaultHeaderAt(tool string, t time.Time) []string {
+	return []string{
+		fmt.Sprintf("Generated by %s on %s", tool, t.Format(time.RFC3339)),
+		"Do not edit, changes will be overwritten",
+	}
+}
+
+var timestampRe = regexp.MustCompile(`\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`)
+
+// headerLen returns the number of comments at the top of the items that
+// are the header written before, 0 unless all of its lines match
+func (conf *Conf) headerLen() int {
+	if len(conf.header) == 0 {
+		return 0
+	}
+	n := 0
+	for n < len(conf.items) && n < len(conf.header) {
+		c, ok := conf.items[n].(*Comment)
+		if !ok || c.Trailing || timestampRe.ReplaceAllString(c.Text, "") != timestampRe.ReplaceAllString(conf.header[n], "") {
+			break
+		}
+		n++
+	}
+	if n < len(conf.header) {
+		return 0
+	}
+	return n
+}
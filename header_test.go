@@ -0,0 +1,49 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeader(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com"))
+	conf.SetHeader("Managed by netctl", "; keep")
+	assert.Equal(t, []string{"# Managed by netctl", "; keep"}, conf.GetHeader())
+	out := "# Managed by netctl\n; keep\nnameserver 10.0.0.1\n\nsearch a.com\n\n"
+	assert.Equal(t, out, conf.String())
+
+	var buf bytes.Buffer
+	assert.Nil(t, conf.Write(&buf))
+	assert.Equal(t, out, buf.String())
+
+	conf.SetHeader()
+	assert.Equal(t, "nameserver 10.0.0.1\n\nsearch a.com\n\n", conf.String())
+}
+
+func TestDefaultHeaderIsNotDuplicated(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"Generated by netctl on 2024-03-01T12:00:00Z", "Do not edit, changes will be overwritten"},
+		resolvconf.DefaultHeaderAt("netctl", at))
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	conf.SetHeader(resolvconf.DefaultHeaderAt("netctl", at)...)
+	first := conf.String()
+
+	// Written again later by the same tool
+	back, err := resolvconf.ReadConf(strings.NewReader(first))
+	assert.Nil(t, err)
+	back.SetHeader(resolvconf.DefaultHeaderAt("netctl", at.Add(time.Hour))...)
+	assert.Equal(t, strings.Replace(first, "12:00:00Z", "13:00:00Z", 1), back.String())
+
+	// Other comments at the top are kept
+	back, _ = resolvconf.ReadConf(strings.NewReader("# Generated by netctl\n" + first))
+	back.SetHeader(resolvconf.DefaultHeaderAt("netctl", at)...)
+	assert.Equal(t, 3, strings.Count(back.String(), "Generated by netctl"))
+}
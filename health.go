@@ -0,0 +1,186 @@
+package resolvconf
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNS constants used by the health check
+const (
+	dnsTypeSOA    = 6
+	dnsClassIN    = 1
+	dnsRcodeOK    = 0
+	dnsRcodeNX    = 3
+	dnsHeaderSize = 12
+)
+
+// NSHealth is the result of checking one nameserver
+type NSHealth struct {
+	Nameserver Nameserver
+	OK         bool          // True if the server answered the query
+	RTT        time.Duration // Round trip time, only set if OK
+	Err        error         // Why the check failed, nil if OK
+}
+
+// CheckNameservers sends a SOA query for probe, "." if empty, to every
+// nameserver over UDP and reports if and how fast each answered. All
+// servers are queried concurrently, each with a timeout taken from the
+// timeout option. Results are in nameserver order and the Conf is not
+// modified.
+//
+// The returned error is only set if ctx was done before all checks
+// completed, the results are returned anyway
+func (conf *Conf) CheckNameservers(ctx context.Context, probe string) ([]NSHealth, error) {
+	if probe == "" {
+		probe = "."
+	}
+	query, id, err := buildQuery(probe, dnsTypeSOA)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(conf.EffectiveTimeout()) * time.Second
+
+	nss := conf.GetNameservers()
+	res := make([]NSHealth, len(nss))
+	var wg sync.WaitGroup
+	for i, ns := range nss {
+		wg.Add(1)
+		go func(i int, ns Nameserver) {
+			defer wg.Done()
+			res[i] = NSHealth{Nameserver: ns}
+			res[i].RTT, res[i].Err = queryNameserver(ctx, ns, query, id, timeout)
+			res[i].OK = res[i].Err == nil
+			if !res[i].OK {
+				res[i].RTT = 0
+			}
+		}(i, ns)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return res, fmt.Errorf("Checking nameservers: %w", err)
+	}
+	return res, nil
+}
+
+// SortNameserversByLatency reorders the nameservers using the results of
+// CheckNameservers: responsive servers first, fastest first, then servers
+// without a result and last the unresponsive ones. The sort is stable and
+// other items keep their positions
+func (conf *Conf) SortNameserversByLatency(results []NSHealth) {
+	const (
+		responsive = iota
+		missing
+		unresponsive
+	)
+	type ranked struct {
+		ns    ConfItem
+		class int
+		rtt   time.Duration
+	}
+	conf.reorder(kindNameserver, func(items []ConfItem) ([]ConfItem, error) {
+		rs := make([]ranked, len(items))
+		for i, item := range items {
+			rs[i] = ranked{ns: item, class: missing}
+			for _, res := range results {
+				if item.Equal(&res.Nameserver) {
+					rs[i].class, rs[i].rtt = unresponsive, res.RTT
+					if res.OK {
+						rs[i].class = responsive
+					}
+					break
+				}
+			}
+		}
+		sort.SliceStable(rs, func(i, j int) bool {
+			if rs[i].class != rs[j].class {
+				return rs[i].class < rs[j].class
+			}
+			return rs[i].class == responsive && rs[i].rtt < rs[j].rtt
+		})
+		ordered := make([]ConfItem, len(rs))
+		for i, r := range rs {
+			ordered[i] = r.ns
+		}
+		return ordered, nil
+	})
+}
+
+func queryNameserver(ctx context.Context, ns Nameserver, query []byte, id uint16, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "udp", ns.Addr())
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		c.SetDeadline(dl)
+	}
+	// Unblock the read as soon as the context is cancelled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	start := time.Now()
+	if _, err := c.Write(query); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 512)
+	for {
+		n, err := c.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, err
+		}
+		if n < dnsHeaderSize || binary.BigEndian.Uint16(buf) != id || buf[2]&0x80 == 0 {
+			continue // Not our answer
+		}
+		switch rcode := buf[3] & 0x0f; rcode {
+		case dnsRcodeOK, dnsRcodeNX:
+			return time.Since(start), nil
+		default:
+			return 0, fmt.Errorf("Nameserver %s answered with rcode %d", ns, rcode)
+		}
+	}
+}
+
+// buildQuery builds a recursive DNS query message for name
+func buildQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	id := uint16(rand.Intn(1 << 16))
+	msg := make([]byte, dnsHeaderSize, 64)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	msg[2] = 0x01                          // RD
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, 0, fmt.Errorf("Malformed probe name %s", name)
+			}
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+	}
+	msg = append(msg, 0)
+	msg = append(msg, byte(qtype>>8), byte(qtype), 0, dnsClassIN)
+	return msg, id, nil
+}
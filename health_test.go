@@ -0,0 +1,111 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeDNS answers every query with the given rcode, or not at all if
+// rcode is negative
+func fakeDNS(t *testing.T, rcode int) (*resolvconf.Nameserver, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if rcode < 0 {
+				continue
+			}
+			resp := append([]byte(nil), buf[:n]...)
+			resp[2] |= 0x80
+			resp[3] = byte(rcode)
+			pc.WriteTo(resp, addr)
+		}
+	}()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	return resolvconf.NewNameserver(net.ParseIP("127.0.0.1")).SetPort(port), func() { pc.Close() }
+}
+
+func TestCheckNameservers(t *testing.T) {
+	ok, stop1 := fakeDNS(t, 0)
+	defer stop1()
+	refused, stop2 := fakeDNS(t, 5)
+	defer stop2()
+	silent, stop3 := fakeDNS(t, -1)
+	defer stop3()
+
+	conf := resolvconf.New()
+	conf.Add(ok, refused, silent, resolvconf.NewOption("timeout").Set(1))
+	res, err := conf.CheckNameservers(context.Background(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(res))
+	assert.True(t, res[0].OK)
+	assert.True(t, res[0].RTT > 0)
+	assert.False(t, res[1].OK)
+	assert.NotNil(t, res[1].Err)
+	assert.False(t, res[2].OK)
+	assert.NotNil(t, res[2].Err)
+	assert.Equal(t, *silent, res[2].Nameserver)
+}
+
+func TestCheckNameserversCancelled(t *testing.T) {
+	silent, stop := fakeDNS(t, -1)
+	defer stop()
+
+	conf := resolvconf.New()
+	conf.Add(silent)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, err := conf.CheckNameservers(ctx, "example.com")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second)
+	assert.False(t, res[0].OK)
+}
+
+func TestCheckNameserversBadProbe(t *testing.T) {
+	conf := resolvconf.New()
+	_, err := conf.CheckNameservers(context.Background(), "foo..bar")
+	assert.NotNil(t, err)
+}
+
+func TestNameserverPort(t *testing.T) {
+	ns := resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))
+	assert.Equal(t, "10.0.0.1:53", ns.Addr())
+	ns.SetPort(5353)
+	assert.Equal(t, "10.0.0.1:5353", ns.Addr())
+	assert.Equal(t, "[10.0.0.1]:5353", ns.String())
+	assert.False(t, ns.Equal(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))))
+}
+
+func TestSortNameserversByLatency(t *testing.T) {
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	var nss []*resolvconf.Nameserver
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"} {
+		ns := resolvconf.NewNameserver(net.ParseIP(ip))
+		nss = append(nss, ns)
+	}
+	conf.Add(nss[0], resolvconf.NewDomain("foo.com"), nss[1], nss[2], nss[3], nss[4])
+	results := []resolvconf.NSHealth{
+		{Nameserver: *nss[0], OK: false, Err: errors.New("timeout")},
+		{Nameserver: *nss[1], OK: true, RTT: 30 * time.Millisecond},
+		{Nameserver: *nss[2], OK: false, Err: errors.New("refused")},
+		{Nameserver: *nss[4], OK: true, RTT: 10 * time.Millisecond},
+	}
+	conf.SortNameserversByLatency(results)
+	var order []string
+	for _, ns := range conf.GetNameservers() {
+		order = append(order, ns.String())
+	}
+	assert.Equal(t, []string{"10.0.0.5", "10.0.0.2", "10.0.0.4", "10.0.0.1", "10.0.0.3"}, order)
+	assert.Equal(t, "foo.com", conf.GetDomain().Name)
+}
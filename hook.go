@@ -0,0 +1,42 @@
+package resolvconf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// AfterWrite makes WriteFile call fn once the file has been written, e.g.
+// to reload a local forwarder. It is not called if nothing was written
+// because of OnlyIfChanged. If fn fails the write is reported as failed and
+// the previous file is restored when a Backup was taken. Hooks run in the
+// order given
+func AfterWrite(fn func(ctx context.Context, path string) error) WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.afterWrite = append(o.afterWrite, fn)
+	})
+}
+
+// RunCommand returns an AfterWrite hook running the command name with args,
+// e.g. RunCommand("resolvectl", "flush-caches"). The command is killed if
+// the context is done, its output is included in the error if it fails
+func RunCommand(name string, args ...string) func(ctx context.Context, path string) error {
+	return func(ctx context.Context, path string) error {
+		var out bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("Running %s: %w", name, ctx.Err())
+			}
+			if msg := strings.TrimSpace(out.String()); msg != "" {
+				return fmt.Errorf("Running %s: %w: %s", name, err, msg)
+			}
+			return fmt.Errorf("Running %s: %w", name, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAfterWriteOnlyIfChanged(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	calls := 0
+	hook := resolvconf.AfterWrite(func(ctx context.Context, p string) error {
+		assert.Equal(t, path, p)
+		calls++
+		return nil
+	})
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.OnlyIfChanged(), hook))
+	assert.Equal(t, 1, calls)
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.OnlyIfChanged(), hook))
+	assert.Equal(t, 1, calls)
+
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.4.4")))
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.OnlyIfChanged(), hook))
+	assert.Equal(t, 2, calls)
+}
+
+func TestAfterWriteFailureRestoresBackup(t *testing.T) {
+	dir := fakeRoot(t, map[string]string{"resolv.conf": "nameserver 10.0.0.1\n"})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	boom := errors.New("boom")
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	err := conf.WriteFile(path, 0644, resolvconf.Backup(".bak"),
+		resolvconf.AfterWrite(func(ctx context.Context, p string) error { return boom }))
+	assert.True(t, errors.Is(err, boom))
+
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "nameserver 10.0.0.1\n", string(b))
+	b, _ = ioutil.ReadFile(path + ".bak")
+	assert.Equal(t, "nameserver 10.0.0.1\n", string(b))
+}
+
+func TestRunCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	assert.Nil(t, resolvconf.RunCommand("/bin/sh", "-c", "exit 0")(context.Background(), ""))
+	err := resolvconf.RunCommand("/bin/sh", "-c", "echo failed >&2; exit 3")(context.Background(), "")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotNil(t, resolvconf.RunCommand("/bin/sh", "-c", "sleep 10")(ctx, ""))
+}
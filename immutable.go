@@ -0,0 +1,32 @@
+package resolvconf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrImmutableFile is matched, using errors.Is, by errors returned when
+// the target file has the immutable attribute set, e.g. with chattr +i
+var ErrImmutableFile = errors.New("File is immutable")
+
+// ImmutableFileError is returned by WriteFile when the file to replace is
+// immutable
+type ImmutableFileError struct {
+	Path string
+}
+
+func (e *ImmutableFileError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrImmutableFile, e.Path)
+}
+
+// Is makes errors.Is(err, ErrImmutableFile) true
+func (e *ImmutableFileError) Is(target error) bool {
+	return target == ErrImmutableFile
+}
+
+// IsImmutable reports if the file at path has the immutable attribute set.
+// A missing file or a filesystem without attribute support is reported as
+// not immutable. Always false on platforms other than Linux
+func IsImmutable(path string) (bool, error) {
+	return isImmutable(path)
+}
@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package resolvconf
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// FS_IOC_GETFLAGS is _IOR('f', 1, long)
+	fsIocGetFlags = 2<<30 | uintptr(unsafe.Sizeof(uintptr(0)))<<16 | 'f'<<8 | 1
+	fsImmutableFl = 0x00000010
+)
+
+func isImmutable(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	var flags int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocGetFlags, uintptr(unsafe.Pointer(&flags)))
+	switch errno {
+	case 0:
+		return flags&fsImmutableFl != 0, nil
+	case syscall.ENOTTY, syscall.EINVAL, syscall.EOPNOTSUPP:
+		return false, nil
+	}
+	return false, &os.PathError{Op: "ioctl", Path: path, Err: errno}
+}
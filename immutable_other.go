@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package resolvconf
+
+func isImmutable(path string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,339 @@
+package resolvconf
+
+import (
+	"net"
+)
+
+// itemKind is the kind of a ConfItem, used by the index
+type itemKind uint8
+
+// Item kinds
+const (
+	kindOther itemKind = iota
+	kindNameserver
+	kindDomain
+	kindSearchDomain
+	kindSortItem
+	kindOption
+	kindLookup
+	kindFamily
+	kindComment
+	kindRawLine
+	kindCount
+)
+
+// itemKey identifies an item in the index. Items that are Equal have the
+// same key but items with the same key may not be Equal, candidates are
+// always checked with Equal
+type itemKey struct {
+	kind itemKind
+	ip   [net.IPv6len]byte
+	name string
+	port int
+}
+
+func ipKey(ip net.IP) (k [net.IPv6len]byte) {
+	copy(k[:], ip.To16())
+	return k
+}
+
+func keyOf(item ConfItem) itemKey {
+	switch it := item.(type) {
+	case *Nameserver:
+		return itemKey{kind: kindNameserver, ip: ipKey(it.IP), port: it.Port}
+	case Nameserver:
+		return itemKey{kind: kindNameserver, ip: ipKey(it.IP), port: it.Port}
+	case *Domain, Domain:
+		return itemKey{kind: kindDomain}
+	case *SearchDomain:
+		return itemKey{kind: kindSearchDomain, name: it.Name}
+	case SearchDomain:
+		return itemKey{kind: kindSearchDomain, name: it.Name}
+	case *SortItem:
+		return itemKey{kind: kindSortItem, ip: ipKey(it.Address)}
+	case SortItem:
+		return itemKey{kind: kindSortItem, ip: ipKey(it.Address)}
+	case *Option:
+		return itemKey{kind: kindOption, name: it.Type}
+	case *Lookup, Lookup:
+		return itemKey{kind: kindLookup}
+	case *Family, Family:
+		return itemKey{kind: kindFamily}
+	case *Comment:
+		return itemKey{kind: kindComment, name: it.Text}
+	case Comment:
+		return itemKey{kind: kindComment, name: it.Text}
+	case *RawLine:
+		return itemKey{kind: kindRawLine, name: it.Text}
+	case RawLine:
+		return itemKey{kind: kindRawLine, name: it.Text}
+	}
+	return itemKey{}
+}
+
+// confIndex indexes the items of a Conf by kind and identity so that
+// duplicate and limit checks don't need to walk all items. Items handed
+// out by Find may be modified, they stay indexed by the key they had until
+// a lookup misses or the length of the search list is needed, then those
+// that changed are moved to their new key
+type confIndex struct {
+	byKey       map[itemKey][]ConfItem // In item order
+	counts      [kindCount]int
+	searchChars int                  // Bytes of the search domains, without separators
+	lent        map[ConfItem]itemKey // Items handed out, with the key they are indexed by
+	at          map[ConfItem]int     // Position of the items held by pointer, see position
+	removed     int                  // Items removed since at was exact
+	low         int                  // Lowest position removed from since then
+	dirty       bool
+}
+
+// maxRemoved is the number of removals after which the positions are
+// recomputed rather than searched for
+const maxRemoved = 32
+
+// place records the position of an item held by pointer
+func (idx *confIndex) place(item ConfItem, i int) {
+	if isPointer(item) {
+		idx.at[item] = i
+	}
+}
+
+func (idx *confIndex) insert(item ConfItem) {
+	k := keyOf(item)
+	idx.byKey[k] = append(idx.byKey[k], item)
+	idx.counts[k.kind]++
+	if k.kind == kindSearchDomain {
+		idx.searchChars += len(k.name)
+	}
+}
+
+func (idx *confIndex) delete(item ConfItem) {
+	k, ok := idx.lent[item]
+	if !ok {
+		k = keyOf(item)
+	}
+	idx.unlink(item, k)
+	if isPointer(item) {
+		delete(idx.at, item)
+	}
+	idx.counts[k.kind]--
+	if k.kind == kindSearchDomain {
+		idx.searchChars -= len(k.name)
+	}
+	delete(idx.lent, item)
+}
+
+// unlink removes item from the items of key k
+func (idx *confIndex) unlink(item ConfItem, k itemKey) {
+	items := idx.byKey[k]
+	for i, it := range items {
+		if it == item {
+			items = append(items[:i], items[i+1:]...)
+			break
+		}
+	}
+	if len(items) == 0 {
+		delete(idx.byKey, k)
+	} else {
+		idx.byKey[k] = items
+	}
+}
+
+// find returns the first item indexed by k that is Equal to o
+func (idx *confIndex) find(o ConfItem, k itemKey) ConfItem {
+	for _, item := range idx.byKey[k] {
+		if o.Equal(item) {
+			return item
+		}
+	}
+	return nil
+}
+
+// rekey moves the lent items that were changed to their new key, it
+// returns false if the index must be rebuilt to keep the items of a key in
+// order
+func (idx *confIndex) rekey() bool {
+	for item, old := range idx.lent {
+		k := keyOf(item)
+		if k == old {
+			continue
+		}
+		if len(idx.byKey[k]) > 0 {
+			return false
+		}
+		idx.unlink(item, old)
+		idx.byKey[k] = []ConfItem{item}
+		if k.kind == kindSearchDomain {
+			idx.searchChars += len(k.name) - len(old.name)
+		}
+		idx.lent[item] = k
+	}
+	return true
+}
+
+func (idx *confIndex) lend(item ConfItem) {
+	if idx.lent == nil {
+		idx.lent = make(map[ConfItem]itemKey)
+	}
+	if _, ok := idx.lent[item]; !ok {
+		idx.lent[item] = keyOf(item)
+	}
+}
+
+// index returns the index of the configuration, lent items may still be
+// indexed by their old keys. It may be rebuilt so callers must hold the
+// lock for changing the items
+func (conf *Conf) index() *confIndex {
+	if conf.idx == nil {
+		conf.idx = &confIndex{dirty: true}
+	}
+	if conf.idx.dirty {
+		lent := conf.idx.lent
+		*conf.idx = confIndex{byKey: make(map[itemKey][]ConfItem, cap(conf.items)), at: make(map[ConfItem]int, cap(conf.items))}
+		for i, item := range conf.items {
+			conf.idx.insert(item)
+			conf.idx.place(item, i)
+			if _, ok := lent[item]; ok {
+				conf.idx.lend(item)
+			}
+		}
+	}
+	return conf.idx
+}
+
+// freshIndex is index with the lent items moved to their current keys
+func (conf *Conf) freshIndex() *confIndex {
+	idx := conf.index()
+	if !idx.rekey() {
+		idx.dirty = true
+		idx = conf.index()
+	}
+	return idx
+}
+
+// lend hands item out to the caller, who may change it. The rendered output
+// is no longer cached, the Conf must be locked for changing the items
+func (conf *Conf) lend(item ConfItem) {
+	conf.index().lend(item)
+	conf.cache.disable()
+}
+
+// invalidate marks the index and the rendered output as stale, e.g. after
+// pointers to items were handed out or items were replaced wholesale
+func (conf *Conf) invalidate() {
+	if conf.idx != nil {
+		conf.idx.dirty = true
+	}
+	conf.cache.reset()
+}
+
+// releaseIndex frees the index, it is rebuilt on next use. Parsed Confs
+// are often only read, there is no need to keep the index of those alive
+func (conf *Conf) releaseIndex() {
+	if conf.idx != nil {
+		*conf.idx = confIndex{dirty: true}
+	}
+}
+
+// lookup returns the first item Equal to o, like Find, without marking
+// the index dirty
+func (conf *Conf) lookup(o ConfItem) ConfItem {
+	k := keyOf(o)
+	idx := conf.index()
+	if item := idx.find(o, k); item != nil || len(idx.lent) == 0 {
+		return item
+	}
+	// A lent item may have been changed to match
+	return conf.freshIndex().find(o, k)
+}
+
+// position returns the position of item in the items, -1 if it isn't held.
+// Removals move the items after them down without updating at, an item
+// recorded at p past idx.low is at most idx.removed below it
+func (conf *Conf) position(item ConfItem) int {
+	idx := conf.index()
+	if isPointer(item) {
+		if p, ok := idx.at[item]; ok {
+			if idx.removed == 0 || p < idx.low {
+				return p
+			}
+			for i := p; i >= 0 && i >= p-idx.removed; i-- {
+				if i < len(conf.items) && conf.items[i] == item {
+					return i
+				}
+			}
+		}
+	}
+	for i, it := range conf.items {
+		if it == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// count returns the number of items of kind k
+func (conf *Conf) count(k itemKind) int {
+	return conf.index().counts[k]
+}
+
+// appendItem adds item last and indexes it
+func (conf *Conf) appendItem(item ConfItem) {
+	idx := conf.index()
+	conf.items = append(conf.items, item)
+	idx.insert(item)
+	idx.place(item, len(conf.items)-1)
+	conf.cache.reset()
+	conf.place(item)
+	conf.changed(OpAdd, item)
+}
+
+// removeItem removes the item at position i
+func (conf *Conf) removeItem(i int) {
+	idx := conf.index()
+	idx.delete(conf.items[i])
+	conf.unplace(conf.items[i])
+	conf.changed(OpRemove, conf.items[i])
+	conf.items = append(conf.items[:i], conf.items[i+1:]...)
+	// The items after i moved down by one
+	if idx.removed == 0 || i < idx.low {
+		idx.low = i
+	}
+	if idx.removed++; idx.removed > maxRemoved {
+		for j := idx.low; j < len(conf.items); j++ {
+			idx.place(conf.items[j], j)
+		}
+		idx.removed = 0
+	}
+	conf.cache.reset()
+}
+
+// replaceItem replaces the item at position i
+func (conf *Conf) replaceItem(i int, item ConfItem) {
+	idx := conf.index()
+	idx.delete(conf.items[i])
+	conf.unplace(conf.items[i])
+	conf.items[i] = item
+	idx.insert(item)
+	idx.place(item, i)
+	conf.cache.reset()
+	conf.place(item)
+	conf.changed(OpUpdate, item)
+}
+
+// grow reserves room for n more items
+func (conf *Conf) grow(n int) {
+	if cap(conf.items)-len(conf.items) >= n {
+		return
+	}
+	items := make([]ConfItem, len(conf.items), len(conf.items)+n)
+	copy(items, conf.items)
+	conf.setItems(items)
+}
+
+// setItems replaces all items
+func (conf *Conf) setItems(items []ConfItem) {
+	conf.items = items
+	conf.invalidate()
+	conf.prunePositions()
+}
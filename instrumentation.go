@@ -0,0 +1,57 @@
+package resolvconf
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Instrumentation receives events from the package, e.g. to export
+// metrics. Implementations must be safe for concurrent use, embed
+// NopInstrumentation to only implement some of the methods
+type Instrumentation interface {
+	// ObserveParse is called after every ReadConf, err is what it returned
+	ObserveParse(d time.Duration, err error)
+	// ObserveWrite is called after every WriteFile, changed is false if
+	// nothing was written, e.g. because of OnlyIfChanged or an error
+	// before the file was replaced
+	ObserveWrite(path string, changed bool, err error)
+	// ObserveValidation is called after every Validate with the number of
+	// issues found
+	ObserveValidation(issues int)
+}
+
+// NopInstrumentation ignores all events, it is used by default
+type NopInstrumentation struct{}
+
+// ObserveParse implements Instrumentation
+func (NopInstrumentation) ObserveParse(time.Duration, error) {}
+
+// ObserveWrite implements Instrumentation
+func (NopInstrumentation) ObserveWrite(string, bool, error) {}
+
+// ObserveValidation implements Instrumentation
+func (NopInstrumentation) ObserveValidation(int) {}
+
+// instrumentationHolder keeps the stored type constant for atomic.Value
+type instrumentationHolder struct {
+	i Instrumentation
+}
+
+var currentInstrumentation atomic.Value
+
+func init() {
+	currentInstrumentation.Store(instrumentationHolder{NopInstrumentation{}})
+}
+
+// SetInstrumentation sets the Instrumentation used by the package, nil
+// restores the default no-op one
+func SetInstrumentation(i Instrumentation) {
+	if i == nil {
+		i = NopInstrumentation{}
+	}
+	currentInstrumentation.Store(instrumentationHolder{i})
+}
+
+func instrumentation() Instrumentation {
+	return currentInstrumentation.Load().(instrumentationHolder).i
+}
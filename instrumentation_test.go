@@ -0,0 +1,69 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingInstrumentation struct {
+	resolvconf.NopInstrumentation
+	mu          sync.Mutex
+	parses      int
+	parseErrors int
+	writes      []bool
+	issues      int
+}
+
+func (c *countingInstrumentation) ObserveParse(d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.parses++
+	if err != nil {
+		c.parseErrors++
+	}
+}
+
+func (c *countingInstrumentation) ObserveWrite(path string, changed bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes = append(c.writes, changed)
+}
+
+func (c *countingInstrumentation) ObserveValidation(issues int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.issues += issues
+}
+
+func TestInstrumentation(t *testing.T) {
+	c := &countingInstrumentation{}
+	resolvconf.SetInstrumentation(c)
+	defer resolvconf.SetInstrumentation(nil)
+
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8\n"))
+	resolvconf.ReadConf(strings.NewReader("nameserver bogus\n"))
+	assert.Equal(t, 2, c.parses)
+	assert.Equal(t, 1, c.parseErrors)
+
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	conf.WriteFile(path, 0644, resolvconf.OnlyIfChanged())
+	conf.WriteFile(path, 0644, resolvconf.OnlyIfChanged())
+	assert.Equal(t, []bool{true, false}, c.writes)
+
+	empty := resolvconf.New()
+	empty.SetPolicy(resolvconf.RequireNameserver)
+	empty.Validate()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.4.4")))
+	conf.Validate()
+	assert.Equal(t, 1, c.issues)
+}
@@ -0,0 +1,149 @@
+package resolvconf
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Default interface orders, same as openresolv
+var (
+	DefaultInterfaceOrder = []string{"lo", "lo[0-9]*"}
+	DefaultDynamicOrder   = []string{"tap[0-9]*", "tun[0-9]*", "vpn", "vpn[0-9]*", "wg[0-9]*", "ppp[0-9]*", "ippp[0-9]*"}
+)
+
+// InterfaceConf is the resolver configuration supplied for one interface,
+// what resolvconf(8) receives with "resolvconf -a"
+type InterfaceConf struct {
+	Name      string
+	Metric    int // Lower metric is processed first, like "resolvconf -m"
+	Conf      *Conf
+	Exclusive bool // Only use this interface, like "resolvconf -x"
+	Private   bool // Keep domains out of the search list, like "resolvconf -p"
+}
+
+// MergeConfig controls how MergeInterfaces orders interfaces
+type MergeConfig struct {
+	// InterfaceOrder lists shell patterns of interfaces that are always
+	// processed first, nil means DefaultInterfaceOrder
+	InterfaceOrder []string
+	// DynamicOrder lists shell patterns of interfaces processed next unless
+	// they have a metric, nil means DefaultDynamicOrder
+	DynamicOrder []string
+	// Profile of the merged Conf, with ProfileGlibc merging more than
+	// three nameservers fails
+	Profile Profile
+}
+
+// MergeInterfaces merges per interface configurations the way openresolv
+// does:
+//
+//  1. If any fragment is exclusive only the last exclusive one is used
+//  2. Interfaces matching InterfaceOrder are processed first, in pattern order
+//  3. Interfaces without metric matching DynamicOrder are processed next
+//  4. Remaining interfaces are processed by metric, then by name
+//
+// Nameservers, sortlist pairs and options are merged in processing order
+// with duplicates dropped. Domain and search entries of interfaces that are
+// not private are merged into the search list.
+func MergeInterfaces(fragments []InterfaceConf, cfg MergeConfig) (*Conf, error) {
+	if cfg.InterfaceOrder == nil {
+		cfg.InterfaceOrder = DefaultInterfaceOrder
+	}
+	if cfg.DynamicOrder == nil {
+		cfg.DynamicOrder = DefaultDynamicOrder
+	}
+
+	for i := len(fragments) - 1; i >= 0; i-- {
+		if fragments[i].Exclusive {
+			fragments = fragments[i : i+1]
+			break
+		}
+	}
+
+	conf := New(WithProfile(cfg.Profile))
+	for _, frag := range orderInterfaces(fragments, cfg) {
+		if frag.Conf == nil {
+			continue
+		}
+		var items []ConfItem
+		for _, ns := range frag.Conf.GetNameservers() {
+			items = append(items, NewNameserver(ns.IP))
+		}
+		if !frag.Private {
+			if dom := frag.Conf.GetDomain(); dom.Name != "" {
+				items = append(items, NewSearchDomain(dom.Name))
+			}
+			for _, sd := range frag.Conf.GetSearchDomains() {
+				items = append(items, NewSearchDomain(sd.Name))
+			}
+		}
+		for _, si := range frag.Conf.GetSortItems() {
+			items = append(items, NewSortItem(si.Address).SetNetmask(si.Netmask))
+		}
+		for _, opt := range frag.Conf.GetOptions() {
+			items = append(items, &Option{opt.Type, opt.Value})
+		}
+
+		for _, item := range items {
+			if conf.lookup(item) != nil {
+				if conf.logger.enabled(LogDebug) {
+					conf.logger.l.Debug(fmt.Sprintf("Skipped %s %s from interface %s, already merged", typeName(item), item, frag.Name),
+						"op", "merge", "source", frag.Name, "item", item.String())
+				}
+				conf.warn(Warning{Code: WarnMergeDuplicate, Item: item,
+					Message: fmt.Sprintf("%s %s from interface %s is skipped, already merged", typeName(item), item, frag.Name)})
+				continue
+			}
+			if err := conf.Add(item); err != nil {
+				return conf, fmt.Errorf("Merging interface %s: %w", frag.Name, err)
+			}
+		}
+	}
+	return conf, nil
+}
+
+func matchAny(patterns []string, name string) int {
+	for i, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func orderInterfaces(fragments []InterfaceConf, cfg MergeConfig) []InterfaceConf {
+	type ranked struct {
+		frag    InterfaceConf
+		class   int
+		pattern int
+	}
+	rs := make([]ranked, len(fragments))
+	for i, frag := range fragments {
+		r := ranked{frag: frag, class: 2}
+		if p := matchAny(cfg.InterfaceOrder, frag.Name); p != -1 {
+			r.class, r.pattern = 0, p
+		} else if p := matchAny(cfg.DynamicOrder, frag.Name); p != -1 && frag.Metric == 0 {
+			r.class, r.pattern = 1, p
+		}
+		rs[i] = r
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		if a.class < 2 && a.pattern != b.pattern {
+			return a.pattern < b.pattern
+		}
+		if a.class == 2 && a.frag.Metric != b.frag.Metric {
+			return a.frag.Metric < b.frag.Metric
+		}
+		return a.frag.Name < b.frag.Name
+	})
+	ret := make([]InterfaceConf, len(rs))
+	for i, r := range rs {
+		ret[i] = r.frag
+	}
+	return ret
+}
@@ -0,0 +1,65 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func ifConf(t *testing.T, str string) *resolvconf.Conf {
+	conf, err := resolvconf.ReadConf(strings.NewReader(str))
+	assert.Nil(t, err)
+	return conf
+}
+
+func TestMergeInterfacesOrder(t *testing.T) {
+	frags := []resolvconf.InterfaceConf{
+		{Name: "eth1", Metric: 200, Conf: ifConf(t, "nameserver 10.0.1.1\nsearch b.example")},
+		{Name: "eth0", Metric: 100, Conf: ifConf(t, "nameserver 10.0.0.1\nsearch a.example")},
+		{Name: "tun0", Conf: ifConf(t, "nameserver 10.8.0.1\ndomain vpn.example")},
+		{Name: "lo", Conf: ifConf(t, "nameserver 127.0.0.1")},
+	}
+	conf, err := resolvconf.MergeInterfaces(frags, resolvconf.MergeConfig{Profile: resolvconf.ProfileNone})
+	assert.Nil(t, err)
+	str, _ := GetConf(conf)
+	assert.Equal(t, "nameserver 127.0.0.1\nnameserver 10.8.0.1\nnameserver 10.0.0.1\nnameserver 10.0.1.1\n\n"+
+		"search vpn.example a.example b.example\n\n", str)
+}
+
+func TestMergeInterfacesExclusive(t *testing.T) {
+	frags := []resolvconf.InterfaceConf{
+		{Name: "eth0", Conf: ifConf(t, "nameserver 10.0.0.1")},
+		{Name: "tun0", Conf: ifConf(t, "nameserver 10.8.0.1"), Exclusive: true},
+		{Name: "tun1", Conf: ifConf(t, "nameserver 10.9.0.1"), Exclusive: true},
+	}
+	conf, err := resolvconf.MergeInterfaces(frags, resolvconf.MergeConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Equal(t, "10.9.0.1", conf.GetNameservers()[0].String())
+}
+
+func TestMergeInterfacesPrivateAndDuplicates(t *testing.T) {
+	frags := []resolvconf.InterfaceConf{
+		{Name: "eth0", Conf: ifConf(t, "nameserver 10.0.0.1\nsearch a.example\noptions rotate")},
+		{Name: "eth1", Conf: ifConf(t, "nameserver 10.0.0.1\nsearch secret.example a.example\noptions rotate"),
+			Private: true},
+	}
+	conf, err := resolvconf.MergeInterfaces(frags, resolvconf.MergeConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Equal(t, 1, len(conf.GetSearchDomains()))
+	assert.Equal(t, "a.example", conf.GetSearchDomains()[0].Name)
+	assert.Equal(t, 1, len(conf.GetOptions()))
+}
+
+func TestMergeInterfacesGlibcLimit(t *testing.T) {
+	var frags []resolvconf.InterfaceConf
+	for _, n := range []string{"eth0", "eth1", "eth2", "eth3"} {
+		frags = append(frags, resolvconf.InterfaceConf{Name: n,
+			Conf: ifConf(t, "nameserver 10.0.0.1"+n[3:])})
+	}
+	_, err := resolvconf.MergeInterfaces(frags, resolvconf.MergeConfig{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "eth3")
+}
@@ -0,0 +1,116 @@
+package resolvconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+)
+
+// jsonConf is the JSON form of a Conf, items are encoded as written in a
+// resolv.conf file
+type jsonConf struct {
+	Nameservers []string `json:"nameservers,omitempty"`
+	Domain      string   `json:"domain,omitempty"`
+	Search      []string `json:"search,omitempty"`
+	Sortlist    []string `json:"sortlist,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	Lookup      []string `json:"lookup,omitempty"`
+	Family      []string `json:"family,omitempty"`
+}
+
+// MarshalJSON encodes the configuration as a JSON object with the
+// following fields, empty ones are omitted:
+//
+//	nameservers  array of strings, e.g. "8.8.8.8" or "[::1]:5353"
+//	domain       string
+//	search       array of strings
+//	sortlist     array of strings, e.g. "10.0.0.0/255.0.0.0"
+//	options      array of strings, e.g. "ndots:5" or "rotate"
+//	lookup       array of strings, OpenBSD only, e.g. "file" and "bind"
+//	family       array of strings, OpenBSD only, e.g. "inet6" and "inet4"
+//
+// Comments and raw lines are not encoded.
+func (conf *Conf) MarshalJSON() ([]byte, error) {
+	conf.rlock()
+	defer conf.runlock()
+	var jc jsonConf
+	for _, ns := range conf.nameserversInto(nil) {
+		jc.Nameservers = append(jc.Nameservers, ns.String())
+	}
+	jc.Domain = conf.domain().Name
+	for _, sd := range conf.searchDomainsInto(nil) {
+		jc.Search = append(jc.Search, sd.Name)
+	}
+	for _, si := range conf.sortItemsInto(nil) {
+		jc.Sortlist = append(jc.Sortlist, si.String())
+	}
+	for _, opt := range conf.optionsInto(nil) {
+		jc.Options = append(jc.Options, opt.String())
+	}
+	if l, ok := conf.first(kindLookup).(*Lookup); ok {
+		jc.Lookup = l.Sources
+	}
+	if f, ok := conf.first(kindFamily).(*Family); ok {
+		jc.Family = f.Families
+	}
+	return json.Marshal(jc)
+}
+
+// UnmarshalJSON replaces the items of the configuration with those of the
+// JSON object MarshalJSON encodes. The items are added like by Add, so all
+// limits are enforced. Errors are accumulated and name the offending
+// field, e.g. nameservers[3], valid items are added anyway
+func (conf *Conf) UnmarshalJSON(b []byte) error {
+	var jc jsonConf
+	if err := json.Unmarshal(b, &jc); err != nil {
+		return err
+	}
+	if conf.idx == nil {
+		// Zero Conf, e.g. a field of a struct being decoded
+		*conf = *New()
+	}
+	conf.lock()
+	defer conf.unlock()
+	for _, item := range conf.items {
+		conf.changed(OpRemove, item)
+	}
+	conf.setItems(nil)
+
+	var err *multierror.Error
+	add := func(field string, item ConfItem, e error) {
+		if e == nil {
+			e = conf.addItems([]ConfItem{item})
+			if ies := ItemErrors(e); len(ies) > 0 {
+				e = ies[0]
+			}
+		}
+		if e != nil {
+			err = multierror.Append(err, fmt.Errorf("%s: %w", field, e))
+		}
+	}
+	for i, s := range jc.Nameservers {
+		ns, e := NewNameserverFromString(s)
+		add(fmt.Sprintf("nameservers[%d]", i), ns, e)
+	}
+	if jc.Domain != "" {
+		add("domain", NewDomain(jc.Domain), nil)
+	}
+	for i, s := range jc.Search {
+		add(fmt.Sprintf("search[%d]", i), NewSearchDomain(s), nil)
+	}
+	for i, s := range jc.Sortlist {
+		si, e := parseSortItem(s)
+		add(fmt.Sprintf("sortlist[%d]", i), si, e)
+	}
+	for i, s := range jc.Options {
+		opt, e := parseConfOption(s, conf.unknown)
+		add(fmt.Sprintf("options[%d]", i), opt, e)
+	}
+	if len(jc.Lookup) > 0 {
+		add("lookup", NewLookup(jc.Lookup...), nil)
+	}
+	if len(jc.Family) > 0 {
+		add("family", NewFamily(jc.Family...), nil)
+	}
+	return err.ErrorOrNil()
+}
@@ -0,0 +1,51 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	conf := readConf(t, "# Generated\nnameserver 8.8.8.8\ndomain example.com\nsearch a.com\nsortlist 10.0.0.0/255.0.0.0\noptions ndots:5 rotate\n")
+	b, err := json.Marshal(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"nameservers":["8.8.8.8"],"domain":"example.com","search":["a.com"],"sortlist":["10.0.0.0/255.0.0.0"],"options":["ndots:5","rotate"]}`, string(b))
+
+	b, err = json.Marshal(resolvconf.New())
+	assert.Nil(t, err)
+	assert.Equal(t, `{}`, string(b))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	conf := readConf(t, "# Generated\nnameserver 10.0.0.2\nnameserver fe80::1%eth0\nnameserver [::1]:5353\nsearch b.com a.com\nsortlist 10.0.0.0/255.0.0.0 192.168.1.1\noptions ndots:5 rotate\n")
+	b, err := json.Marshal(conf)
+	assert.Nil(t, err)
+
+	var back resolvconf.Conf
+	assert.Nil(t, json.Unmarshal(b, &back))
+	assert.True(t, conf.Equal(&back))
+	assert.Equal(t, conf.GetNameservers(), back.GetNameservers())
+
+	// Unmarshal replaces the items
+	other := readConf(t, "nameserver 1.1.1.1\n")
+	assert.False(t, conf.Equal(other))
+	assert.Nil(t, json.Unmarshal(b, other))
+	assert.True(t, conf.Equal(other))
+}
+
+func TestUnmarshalJSONValidates(t *testing.T) {
+	conf := resolvconf.New()
+	err := json.Unmarshal([]byte(`{"nameservers":["10.0.0.1","10.0.0.1","x","10.0.0.2","10.0.0.3","10.0.0.4"],"search":["foo bar"],"options":["foo"]}`), conf)
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnknownOption))
+	for _, field := range []string{"nameservers[1]", "nameservers[2]", "nameservers[5]", "search[0]", "options[0]"} {
+		assert.Contains(t, err.Error(), field+": ")
+	}
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"nameservers":"10.0.0.1"}`), conf))
+}
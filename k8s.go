@@ -0,0 +1,97 @@
+package resolvconf
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"net"
+	"strconv"
+)
+
+// PodDNSConfig mirrors the Kubernetes core/v1 PodDNSConfig type, field
+// names and json tags match so it can be marshalled into a pod spec
+type PodDNSConfig struct {
+	Nameservers []string             `json:"nameservers,omitempty"`
+	Searches    []string             `json:"searches,omitempty"`
+	Options     []PodDNSConfigOption `json:"options,omitempty"`
+}
+
+// PodDNSConfigOption mirrors the Kubernetes core/v1 PodDNSConfigOption type
+type PodDNSConfigOption struct {
+	Name  string  `json:"name,omitempty"`
+	Value *string `json:"value,omitempty"`
+}
+
+// ToPodDNSConfig converts the configuration to a Kubernetes pod DNS
+// config. Items that can't be represented, the domain, sortlist pairs and
+// nameservers with a non standard port, are returned as well. IPv6 zones
+// are kept, e.g. fe80::1%eth0
+func (conf *Conf) ToPodDNSConfig() (*PodDNSConfig, []ConfItem) {
+	conf.rlock()
+	defer conf.runlock()
+	cfg := new(PodDNSConfig)
+	var dropped []ConfItem
+	for _, item := range conf.items {
+		switch it := item.(type) {
+		case *Nameserver:
+			if it.Port != 0 && it.Port != DefaultPort {
+				dropped = append(dropped, it)
+				continue
+			}
+			cfg.Nameservers = append(cfg.Nameservers, it.host())
+		case *SearchDomain:
+			cfg.Searches = append(cfg.Searches, it.Name)
+		case *Option:
+			opt := PodDNSConfigOption{Name: it.Type}
+			if it.Value >= 0 {
+				val := strconv.Itoa(it.Value)
+				opt.Value = &val
+			}
+			cfg.Options = append(cfg.Options, opt)
+		default:
+			dropped = append(dropped, it)
+		}
+	}
+	return cfg, dropped
+}
+
+// FromPodDNSConfig creates a configuration from a Kubernetes pod DNS config.
+// The Conf uses ProfileNone since Kubernetes has its own limits. Like
+// ReadConf the Conf is returned along with the error if some of the items
+// could not be added
+func FromPodDNSConfig(cfg *PodDNSConfig) (*Conf, error) {
+	var res *multierror.Error
+	conf := New(WithProfile(ProfileNone))
+	if cfg == nil {
+		return conf, nil
+	}
+	for _, s := range cfg.Nameservers {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			res = multierror.Append(res, fmt.Errorf("%w: malformed IP address: %s", ErrInvalidValue, s))
+			continue
+		}
+		if err := conf.Add(NewNameserver(ip)); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	for _, s := range cfg.Searches {
+		if err := conf.Add(NewSearchDomain(s)); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	for _, o := range cfg.Options {
+		str := o.Name
+		if o.Value != nil {
+			str += ":" + *o.Value
+		}
+		opt, err := parseOption(str)
+		if err != nil {
+			res = multierror.Append(res, err)
+			continue
+		}
+		if err := conf.Add(opt); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	return conf, res.ErrorOrNil()
+}
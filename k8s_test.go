@@ -0,0 +1,53 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestToPodDNSConfig(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("domain corp.example\nnameserver 10.0.0.1\n" +
+		"nameserver [10.0.0.2]:5353\nsearch a.example b.example\nsortlist 10.0.0.0\noptions ndots:2 rotate"))
+	cfg, dropped := conf.ToPodDNSConfig()
+	b, err := json.Marshal(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"nameservers":["10.0.0.1"],"searches":["a.example","b.example"],`+
+		`"options":[{"name":"ndots","value":"2"},{"name":"rotate"}]}`, string(b))
+	assert.Equal(t, 3, len(dropped))
+}
+
+func TestToPodDNSConfigZone(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader("nameserver fe80::1%eth0\nnameserver 10.0.0.1\n"))
+	cfg, dropped := conf.ToPodDNSConfig()
+	assert.Empty(t, dropped)
+	assert.Equal(t, []string{"fe80::1%eth0", "10.0.0.1"}, cfg.Nameservers)
+}
+
+func TestFromPodDNSConfig(t *testing.T) {
+	var cfg resolvconf.PodDNSConfig
+	err := json.Unmarshal([]byte(`{"nameservers":["10.0.0.1","2001:db8::1"],"searches":["a.example"],`+
+		`"options":[{"name":"ndots","value":"5"},{"name":"edns0"}]}`), &cfg)
+	assert.Nil(t, err)
+	conf, err := resolvconf.FromPodDNSConfig(&cfg)
+	assert.Nil(t, err)
+	str, _ := GetConf(conf)
+	assert.Equal(t, "nameserver 10.0.0.1\nnameserver 2001:db8::1\n\nsearch a.example\n\noptions ndots:5 edns0\n\n", str)
+
+	// Round trip
+	back, dropped := conf.ToPodDNSConfig()
+	assert.Empty(t, dropped)
+	assert.Equal(t, cfg, *back)
+}
+
+func TestFromPodDNSConfigErrors(t *testing.T) {
+	five := "five"
+	conf, err := resolvconf.FromPodDNSConfig(&resolvconf.PodDNSConfig{
+		Nameservers: []string{"10.0.0"},
+		Options:     []resolvconf.PodDNSConfigOption{{Name: "ndots", Value: &five}, {Name: "foo"}, {Name: "timeout"}, {Name: "rotate"}},
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(conf.GetOptions()))
+}
@@ -0,0 +1,65 @@
+package resolvconf
+
+import (
+	"errors"
+	"io"
+	"sort"
+)
+
+// Issue codes of Lint, the codes of the warnings of the Conf are used as
+// is, e.g. WarnValueCapped
+const (
+	IssueInvalidValue   = "invalid-value"
+	IssueUnknownOption  = "unknown-option"
+	IssueUnknownKeyword = "unknown-keyword"
+	IssueDuplicateItem  = "duplicate-item"
+	IssueLimitExceeded  = "limit-exceeded"
+	IssueUnsupported    = "unsupported-keyword"
+)
+
+// Lint reads a whole configuration and reports every problem found, it is
+// meant for checking resolv.conf templates. Lines that could not be parsed
+// are errors, items that glibc would ignore, e.g. a fourth nameserver, a
+// duplicate or an OpenBSD lookup line, are warnings. So are search lists
+// beyond the limits of glibc before 2.26 and the findings of Validate.
+// Issues are sorted by line, those about the whole Conf last.
+//
+// The error is only about reading r, use Issues.Err to fail on errors
+func Lint(r io.Reader) (Issues, error) {
+	conf, err := readConf(r, readOptions{lenient: true, search: SearchWarnOnly})
+	if err != nil {
+		return nil, err
+	}
+	var iss Issues
+	for _, pw := range conf.ParseWarnings() {
+		is := Issue{Severity: SeverityError, Message: pw.Err.Error(), Line: pw.Line, Column: pw.Column}
+		var ie *ItemError
+		if errors.As(pw.Err, &ie) {
+			is.Item = ie.Item
+		}
+		switch {
+		case errors.Is(pw.Err, ErrLimitExceeded):
+			is.Code, is.Severity = IssueLimitExceeded, SeverityWarning
+		case errors.Is(pw.Err, ErrDuplicateItem):
+			is.Code, is.Severity = IssueDuplicateItem, SeverityWarning
+		case errors.Is(pw.Err, ErrUnknownOption):
+			is.Code = IssueUnknownOption
+		case errors.Is(pw.Err, ErrUnknownKeyword):
+			is.Code = IssueUnknownKeyword
+		case errors.Is(pw.Err, ErrUnsupportedKeyword):
+			is.Code, is.Severity = IssueUnsupported, SeverityWarning
+		default:
+			is.Code = IssueInvalidValue
+		}
+		iss = append(iss, is)
+	}
+	for _, w := range conf.Warnings() {
+		iss = append(iss, Issue{Code: w.Code, Severity: SeverityWarning, Message: w.Message, Line: w.Line, Item: w.Item})
+	}
+	iss = append(iss, conf.Validate()...)
+	sort.SliceStable(iss, func(i, j int) bool {
+		li, lj := iss[i].Line, iss[j].Line
+		return li != 0 && (lj == 0 || li < lj)
+	})
+	return iss, nil
+}
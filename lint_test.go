@@ -0,0 +1,47 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	search := "search"
+	for i := 0; i < 7; i++ {
+		search += " d" + string(rune('0'+i)) + ".example.com"
+	}
+	in := "nameserver 10.0.0.1\nnameserver 10.0.0.x\nnameserver 10.0.0.1\n" +
+		"nameserver 10.0.0.2\nnameserver 10.0.0.3\nnameserver 10.0.0.4\n" +
+		"options rotate bogus ndots:20\n" + search + "\nsortlist 10.0.0.1/255.0.0.0\n"
+	iss, err := resolvconf.Lint(strings.NewReader(in))
+	assert.Nil(t, err)
+
+	var got []string
+	for _, is := range iss {
+		got = append(got, is.Code+" "+is.Severity.String())
+	}
+	assert.Equal(t, []string{
+		"invalid-value error",
+		"duplicate-item warning",
+		"limit-exceeded warning",
+		"unknown-option error",
+		"limit-exceeded warning",
+		"sortlist-host-bits warning",
+	}, got)
+	assert.Equal(t, `error: line 2, column 12: Invalid value: malformed IP address: 10.0.0.x`, iss[0].String())
+	assert.Equal(t, "10.0.0.4", iss[2].Item.String())
+	assert.Equal(t, 8, iss[4].Line)
+	assert.True(t, iss.HasErrors())
+	assert.Equal(t, 2, len(iss.Err().(resolvconf.Issues)))
+
+	b, err := json.Marshal(iss[:1])
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"line":2,"column":12`)
+
+	iss, err = resolvconf.Lint(strings.NewReader("nameserver 10.0.0.1\noptions ndots:2\n"))
+	assert.Nil(t, err)
+	assert.Empty(t, iss)
+}
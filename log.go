@@ -0,0 +1,150 @@
+package resolvconf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger receives the diagnostics of a Conf. Messages come with key value
+// pairs, keys are strings, e.g. "op", "item" and "path". A *slog.Logger
+// implements it as is, other structured loggers need a small adapter
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+}
+
+// WithLogger sends the diagnostics of the new Conf to l
+func WithLogger(l Logger) ConfOption {
+	return func(conf *Conf) {
+		conf.logger.set(l)
+	}
+}
+
+// StdLogger adapts a *log.Logger to Logger. Key value pairs are appended
+// to the message as key=value, warnings are prefixed with [WARN]
+func StdLogger(l *log.Logger) Logger {
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s stdLogger) Debug(msg string, kv ...interface{}) { s.print("", msg, kv) }
+func (s stdLogger) Info(msg string, kv ...interface{})  { s.print("", msg, kv) }
+func (s stdLogger) Warn(msg string, kv ...interface{})  { s.print("[WARN] ", msg, kv) }
+
+func (s stdLogger) print(prefix, msg string, kv []interface{}) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(msg)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", kv[i], kv[i+1])
+	}
+	s.l.Print(b.String())
+}
+
+// LogLevel is the lowest level of messages passed to the Logger
+type LogLevel int
+
+// Log levels
+const (
+	LogDebug LogLevel = iota // Every accepted item and every decision
+	LogInfo                  // Files written
+	LogWarn                  // Items rejected, values changed and lines skipped
+	LogOff                   // Nothing
+)
+
+// SetLogLevel only passes messages of level and above to the logger, the
+// default is LogDebug
+func (conf *Conf) SetLogLevel(level LogLevel) {
+	if conf.logger == nil {
+		conf.logger = newConfLogger()
+	}
+	conf.logger.level = level
+}
+
+// debugLoggerHolder keeps the stored type constant for atomic.Value
+type debugLoggerHolder struct {
+	l Logger
+}
+
+var debugLogger atomic.Value
+
+func init() {
+	debugLogger.Store(debugLoggerHolder{})
+}
+
+// EnableDebug logs the debug output of the package to w, nil disables it
+// again. Confs created afterwards, also those returned by ReadConf, log
+// there unless given another logger. Functions without a Conf, like
+// BuildSplitDNS, log there too
+func EnableDebug(w io.Writer) {
+	var l Logger
+	if w != nil && w != ioutil.Discard {
+		l = StdLogger(log.New(w, "[resolvconf] ", log.LstdFlags))
+	}
+	debugLogger.Store(debugLoggerHolder{l})
+}
+
+// confLogger wraps the logger of a Conf so messages are only built when
+// they would be logged, by default there is no logger
+type confLogger struct {
+	l     Logger
+	level LogLevel
+}
+
+// newConfLogger returns a logger using the one set by EnableDebug, if any
+func newConfLogger() *confLogger {
+	return &confLogger{l: debugLogger.Load().(debugLoggerHolder).l}
+}
+
+// packageLogger returns the logger of functions without a Conf
+func packageLogger() *confLogger {
+	return newConfLogger()
+}
+
+func (cl *confLogger) set(l Logger) {
+	cl.l = l
+}
+
+// setOutput logs to w with a standard logger, logging is disabled if w
+// discards it
+func (cl *confLogger) setOutput(w io.Writer) {
+	if w == nil || w == ioutil.Discard {
+		cl.l = nil
+		return
+	}
+	cl.l = StdLogger(log.New(w, "[resolvconf] ", log.LstdFlags))
+}
+
+// enabled returns true if messages of level are logged. Check it before
+// building messages and fields, passing them boxes the values even if
+// they are not logged
+func (cl *confLogger) enabled(level LogLevel) bool {
+	return cl != nil && cl.l != nil && level >= cl.level && level < LogOff
+}
+
+// logWarn logs a warning about an item being added, with the line being
+// parsed if ReadConf adds it. Check enabled(LogWarn) first
+func (conf *Conf) logWarn(msg string, kv ...interface{}) {
+	if conf.warnings != nil && conf.warnings.line > 0 {
+		kv = append(kv, "line", conf.warnings.line)
+	}
+	conf.logger.l.Warn(msg, kv...)
+}
+
+// typeName returns the lower case type name of item, e.g. nameserver
+func typeName(item ConfItem) string {
+	t := reflect.TypeOf(item)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(t.Name())
+}
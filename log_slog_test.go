@@ -0,0 +1,29 @@
+//go:build go1.21
+// +build go1.21
+
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	conf := resolvconf.New(resolvconf.WithLogger(l))
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Equal(t, "level=DEBUG msg=\"Added nameserver 8.8.8.8\" op=add kind=nameserver item=8.8.8.8\n", buf.String())
+}
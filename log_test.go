@@ -0,0 +1,184 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type logEntry struct {
+	level string
+	msg   string
+	kv    map[string]interface{}
+}
+
+// recordingLogger keeps all entries
+type recordingLogger struct {
+	entries []logEntry
+}
+
+func (r *recordingLogger) add(level, msg string, kv []interface{}) {
+	e := logEntry{level, msg, map[string]interface{}{}}
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.kv[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+	r.entries = append(r.entries, e)
+}
+
+func (r *recordingLogger) Debug(msg string, kv ...interface{}) { r.add("debug", msg, kv) }
+func (r *recordingLogger) Info(msg string, kv ...interface{})  { r.add("info", msg, kv) }
+func (r *recordingLogger) Warn(msg string, kv ...interface{})  { r.add("warn", msg, kv) }
+
+func TestWithLoggerGetsFields(t *testing.T) {
+	rec := &recordingLogger{}
+	conf := resolvconf.New(resolvconf.WithLogger(rec))
+	ns := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	conf.Add(ns, resolvconf.NewOption("ndots").Set(20))
+	conf.Remove(ns)
+
+	assert.Equal(t, 4, len(rec.entries))
+	assert.Equal(t, logEntry{"debug", "Added nameserver 8.8.8.8", map[string]interface{}{"op": "add", "kind": "nameserver", "item": "8.8.8.8"}}, rec.entries[0])
+	assert.Equal(t, "warn", rec.entries[1].level)
+	assert.Equal(t, 20, rec.entries[1].kv["value"])
+	assert.Equal(t, "remove", rec.entries[3].kv["op"])
+
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	assert.Nil(t, conf.WriteFile(path, 0644))
+	last := rec.entries[len(rec.entries)-1]
+	assert.Equal(t, "info", last.level)
+	assert.Equal(t, path, last.kv["path"])
+	assert.Equal(t, true, last.kv["changed"])
+}
+
+func TestSetLogger(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8"))
+	assert.Nil(t, err)
+	rec := &recordingLogger{}
+	conf.SetLogger(rec)
+	conf.Add(resolvconf.NewSearchDomain("example.com"))
+	assert.Equal(t, 1, len(rec.entries))
+	assert.Equal(t, "searchdomain", rec.entries[0].kv["kind"])
+
+	conf.SetLogger(nil)
+	conf.Add(resolvconf.NewDomain("example.com"))
+	assert.Equal(t, 1, len(rec.entries))
+}
+
+func TestStdLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	conf := resolvconf.New(resolvconf.WithLogger(resolvconf.StdLogger(log.New(buf, "", 0))))
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewOption("timeout").Set(40))
+	assert.Equal(t, "Added nameserver 8.8.8.8 op=add kind=nameserver item=8.8.8.8\n"+
+		"[WARN] Option timeout is capped to 30, set value is 40 op=add item=timeout value=40 max=30\n"+
+		"Added option timeout:30 op=add kind=option item=timeout:30\n", buf.String())
+}
+
+func (r *recordingLogger) levels() map[string]string {
+	res := map[string]string{}
+	for _, e := range r.entries {
+		res[e.msg] = e.level
+	}
+	return res
+}
+
+func TestLogLevels(t *testing.T) {
+	rec := &recordingLogger{}
+	conf := resolvconf.New(resolvconf.WithLogger(rec))
+	ns := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	conf.Add(ns, ns, resolvconf.NewOption("ndots").Set(2), resolvconf.NewOption("ndots").Set(30))
+	conf.Write(ioutil.Discard, resolvconf.FilterNameservers(func(resolvconf.Nameserver) bool { return false }))
+	assert.Equal(t, map[string]string{
+		"Added nameserver 8.8.8.8": "debug",
+		"Rejected nameserver 8.8.8.8: Item already exists: nameserver 8.8.8.8": "warn",
+		"Added option ndots:2":                          "debug",
+		"Option ndots is capped to 15, set value is 30": "warn",
+		"Updated existing option with ndots:15":         "debug",
+		"Not writing nameserver 8.8.8.8, filtered out":  "debug",
+	}, rec.levels())
+
+	// Only warnings
+	rec.entries = nil
+	conf.SetLogLevel(resolvconf.LogWarn)
+	conf.Add(resolvconf.NewSearchDomain("example.com"), resolvconf.NewOption("timeout").Set(60))
+	assert.Equal(t, map[string]string{"Option timeout is capped to 30, set value is 60": "warn"}, rec.levels())
+
+	rec.entries = nil
+	conf.SetLogLevel(resolvconf.LogOff)
+	conf.Add(resolvconf.NewOption("attempts").Set(60))
+	assert.Equal(t, 0, len(rec.entries))
+}
+
+func TestEnableDebugLogsParse(t *testing.T) {
+	buf := new(bytes.Buffer)
+	resolvconf.EnableDebug(buf)
+	defer resolvconf.EnableDebug(nil)
+
+	_, err := resolvconf.ReadConf(strings.NewReader("# comment\n\nnameserver 8.8.8.8\nnameserver 8.8.8\nnameserver 8.8.8.8\n"))
+	assert.NotNil(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "Parsed nameserver 8.8.8.8 from line 3 op=parse line=3")
+	assert.Contains(t, out, "[WARN] Skipped line 4: Invalid value: malformed IP address: 8.8.8 op=parse line=4")
+	assert.Contains(t, out, "[WARN] Rejected 8.8.8.8 from line 5: Item already exists: nameserver 8.8.8.8 op=parse line=5")
+
+	resolvconf.EnableDebug(nil)
+	buf.Reset()
+	resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8"))
+	assert.Equal(t, "", buf.String())
+}
+
+func TestParseWarningsLogLine(t *testing.T) {
+	rec := &recordingLogger{}
+	_, err := resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8\noptions ndots:30\n"), resolvconf.WithLogger(rec))
+	assert.Nil(t, err)
+	var warns []logEntry
+	for _, e := range rec.entries {
+		if e.level == "warn" {
+			warns = append(warns, e)
+		}
+	}
+	assert.Equal(t, 1, len(warns))
+	assert.Equal(t, "Option ndots is capped to 15, set value is 30", warns[0].msg)
+	assert.Equal(t, 2, warns[0].kv["line"])
+
+	// Not when added by the caller
+	rec.entries = nil
+	conf := resolvconf.New(resolvconf.WithLogger(rec))
+	conf.Add(resolvconf.NewOption("ndots").Set(30))
+	assert.Nil(t, rec.entries[0].kv["line"])
+}
+
+func TestSplitDNSLogsDefaultChoice(t *testing.T) {
+	buf := new(bytes.Buffer)
+	resolvconf.EnableDebug(buf)
+	defer resolvconf.EnableDebug(nil)
+
+	a, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\nsearch a.example b.example"))
+	b, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.2\nsearch c.example"))
+	_, err := resolvconf.BuildSplitDNS(map[string]*resolvconf.Conf{"a": a, "b": b})
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "Default route from b, it claims 1 of the suffixes")
+}
+
+func TestMergeLogsSkippedItems(t *testing.T) {
+	buf := new(bytes.Buffer)
+	resolvconf.EnableDebug(buf)
+	defer resolvconf.EnableDebug(nil)
+
+	frags := []resolvconf.InterfaceConf{
+		{Name: "eth0", Metric: 100, Conf: ifConf(t, "nameserver 10.0.0.1")},
+		{Name: "eth1", Metric: 200, Conf: ifConf(t, "nameserver 10.0.0.1")},
+	}
+	_, err := resolvconf.MergeInterfaces(frags, resolvconf.MergeConfig{})
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "Skipped nameserver 10.0.0.1 from interface eth1, already merged op=merge source=eth1")
+}
@@ -0,0 +1,132 @@
+package resolvconf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Manager is a tool generating resolv.conf
+type Manager string
+
+// Known managers
+const (
+	ManagerNone            Manager = "" // Not generated, or by an unknown tool
+	ManagerSystemdResolved Manager = "systemd-resolved"
+	ManagerNetworkManager  Manager = "NetworkManager"
+	ManagerResolvconf      Manager = "resolvconf"
+	ManagerNetconfig       Manager = "netconfig"
+	ManagerConnman         Manager = "connman"
+	ManagerWSL             Manager = "WSL"
+	ManagerDocker          Manager = "Docker"
+)
+
+// Directories the managers generate their files in, used when
+// resolv.conf is a symlink
+var managerDirs = []struct {
+	prefix  string
+	manager Manager
+}{
+	{"/run/systemd/resolve/", ManagerSystemdResolved},
+	{"/run/NetworkManager/", ManagerNetworkManager},
+	{"/var/run/NetworkManager/", ManagerNetworkManager},
+	{"/run/resolvconf/", ManagerResolvconf},
+	{"/etc/resolvconf/run/", ManagerResolvconf},
+	{"/run/netconfig/", ManagerNetconfig},
+	{"/var/run/netconfig/", ManagerNetconfig},
+	{"/run/connman/", ManagerConnman},
+}
+
+// Header comments written by the managers, matched case insensitively
+var managerMarkers = []struct {
+	marker  string
+	manager Manager
+}{
+	{"managed by man:systemd-resolved", ManagerSystemdResolved},
+	{"generated by networkmanager", ManagerNetworkManager},
+	{"generated by resolvconf", ManagerResolvconf},
+	{"autogenerated by netconfig", ManagerNetconfig},
+	{"generated by connection manager", ManagerConnman},
+	{"automatically generated by wsl", ManagerWSL},
+	{"generated by docker", ManagerDocker},
+}
+
+// DetectManager tells which tool, if any, generated the resolv.conf at
+// path. The symlink chain is followed first, then the leading comments of
+// the file are inspected. A missing file is reported as ManagerNone
+func DetectManager(path string, opts ...FileOption) (Manager, error) {
+	o := newFileOptions(opts)
+	info, err := ResolveSystemPath(path, opts...)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ManagerNone, nil
+		}
+		return ManagerNone, err
+	}
+	if info.Mode == ModeStub || info.Mode == ModeUplink {
+		return ManagerSystemdResolved, nil
+	}
+	if info.Mode == ModeForeign {
+		for _, d := range managerDirs {
+			if strings.HasPrefix(info.LibcPath, d.prefix) {
+				return d.manager, nil
+			}
+		}
+	}
+
+	f, err := o.fs.Open(o.path(info.LibcPath))
+	if err != nil {
+		return ManagerNone, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] != '#' && line[0] != ';' {
+			break // Header ends at the first configuration line
+		}
+		line = strings.ToLower(line)
+		for _, m := range managerMarkers {
+			if strings.Contains(line, m.marker) {
+				return m.manager, nil
+			}
+		}
+	}
+	return ManagerNone, scanner.Err()
+}
+
+// ErrManagedByOther is matched, using errors.Is, by errors returned when
+// refusing to overwrite a file generated by another tool
+var ErrManagedByOther = errors.New("File is managed by another tool")
+
+// ManagedByOtherError is returned by WriteFile when RespectGenerators
+// detected that the file is generated by another tool
+type ManagedByOtherError struct {
+	Path    string
+	Manager Manager
+}
+
+func (e *ManagedByOtherError) Error() string {
+	return fmt.Sprintf("%s: %s is managed by %s", ErrManagedByOther, e.Path, e.Manager)
+}
+
+// Is makes errors.Is(err, ErrManagedByOther) true
+func (e *ManagedByOtherError) Is(target error) bool {
+	return target == ErrManagedByOther
+}
+
+// RespectGenerators makes WriteFile check the file currently on disk with
+// DetectManager before replacing it. If another tool manages it a
+// *ManagedByOtherError is returned, unless force is set in which case the
+// file is written with a comment naming the previous manager
+func RespectGenerators(force bool) WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.respectGenerators = true
+		o.forceGenerators = force
+	})
+}
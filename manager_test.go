@@ -0,0 +1,77 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectManagerFromHeader(t *testing.T) {
+	files := map[string]string{
+		"nm":       "# Generated by NetworkManager\nsearch example.com\nnameserver 10.0.0.1\n",
+		"wsl":      "# This file was automatically generated by WSL. To stop automatic generation of this file, add the following entry to /etc/wsl.conf:\n# [network]\n# generateResolvConf = false\nnameserver 172.20.0.1\n",
+		"resolved": "# This is /run/systemd/resolve/stub-resolv.conf managed by man:systemd-resolved(8).\n# Do not edit.\nnameserver 127.0.0.53\n",
+		"debian":   "# Dynamic resolv.conf(5) file for glibc resolver(3) generated by resolvconf(8)\n#     DO NOT EDIT THIS FILE BY HAND -- YOUR CHANGES WILL BE OVERWRITTEN\nnameserver 10.0.0.1\n",
+		"plain":    "# My own resolver config\nnameserver 10.0.0.1\n# Generated by NetworkManager\n",
+	}
+	dir := fakeRoot(t, files)
+	defer os.RemoveAll(dir)
+
+	for name, want := range map[string]resolvconf.Manager{
+		"nm":       resolvconf.ManagerNetworkManager,
+		"wsl":      resolvconf.ManagerWSL,
+		"resolved": resolvconf.ManagerSystemdResolved,
+		"debian":   resolvconf.ManagerResolvconf,
+		"plain":    resolvconf.ManagerNone,
+		"missing":  resolvconf.ManagerNone,
+	} {
+		m, err := resolvconf.DetectManager(filepath.Join(dir, name))
+		assert.Nil(t, err, name)
+		assert.Equal(t, want, m, name)
+	}
+}
+
+func TestWriteFileRespectGenerators(t *testing.T) {
+	dir := fakeRoot(t, map[string]string{"resolv.conf": "# Generated by NetworkManager\nnameserver 10.0.0.1\n"})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	err := conf.WriteFile(path, 0644, resolvconf.RespectGenerators(false))
+	assert.True(t, errors.Is(err, resolvconf.ErrManagedByOther))
+	var merr *resolvconf.ManagedByOtherError
+	assert.True(t, errors.As(err, &merr))
+	assert.Equal(t, resolvconf.ManagerNetworkManager, merr.Manager)
+	b, _ := ioutil.ReadFile(path)
+	assert.True(t, strings.HasPrefix(string(b), "# Generated by NetworkManager"))
+
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.RespectGenerators(true)))
+	b, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "# Previously managed by NetworkManager, overwritten by resolvconf\nnameserver 8.8.8.8\n\n", string(b))
+
+	// Our own file is not foreign
+	assert.Nil(t, conf.WriteFile(path, 0644, resolvconf.RespectGenerators(false)))
+}
+
+func TestDetectManagerSymlinkInRootDir(t *testing.T) {
+	root := fakeRoot(t, map[string]string{"/run/NetworkManager/resolv.conf": "nameserver 10.0.0.1\n"})
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.Symlink("/run/NetworkManager/resolv.conf", filepath.Join(root, "etc/resolv.conf"))
+
+	m, err := resolvconf.DetectManager("/etc/resolv.conf", resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ManagerNetworkManager, m)
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	err = conf.WriteFile("/etc/resolv.conf", 0644, resolvconf.RootDir(root), resolvconf.RespectGenerators(false))
+	assert.True(t, errors.Is(err, resolvconf.ErrManagedByOther))
+}
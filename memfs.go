@@ -0,0 +1,216 @@
+package resolvconf
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFS is an in memory FileSystem, e.g. for testing code using this
+// package without temporary directories. Directories exist implicitly
+// when they contain a file. It is safe for concurrent use
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	target  string // Set for symlinks
+	modTime time.Time
+}
+
+// NewMemFS returns an empty MemFS
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]*memFile)}
+}
+
+func memPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+// Symlink creates newname as a symlink to oldname
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	newname = memPath(newname)
+	if _, ok := m.files[newname]; ok {
+		return &os.PathError{Op: "symlink", Path: newname, Err: os.ErrExist}
+	}
+	m.files[newname] = &memFile{mode: os.ModeSymlink | 0777, target: oldname, modTime: time.Now()}
+	return nil
+}
+
+// Files returns the paths of all files and symlinks, sorted
+func (m *MemFS) Files() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ret := make([]string, 0, len(m.files))
+	for name := range m.files {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// follow resolves symlinks at name, the caller holds the lock
+func (m *MemFS) follow(op, name string) (string, *memFile, error) {
+	name = memPath(name)
+	for i := 0; i <= maxSymlinks; i++ {
+		f, ok := m.files[name]
+		if !ok {
+			return name, nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+		if f.target == "" {
+			return name, f, nil
+		}
+		if path.IsAbs(f.target) {
+			name = memPath(f.target)
+		} else {
+			name = memPath(path.Join(path.Dir(name), f.target))
+		}
+	}
+	return name, nil, &os.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+}
+
+func (m *MemFS) isDir(name string) bool {
+	if name == "/" {
+		return true
+	}
+	for p := range m.files {
+		if strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Open implements FileSystem
+func (m *MemFS) Open(name string) (io.ReadCloser, error) {
+	data, err := m.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// ReadFile implements FileSystem
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, f, err := m.follow("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// WriteFile implements FileSystem, symlinks at name are followed
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target, _, err := m.follow("open", name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if m.isDir(target) {
+		return &os.PathError{Op: "open", Path: target, Err: syscall.EISDIR}
+	}
+	m.files[target] = &memFile{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// Rename implements FileSystem
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = memPath(oldpath), memPath(newpath)
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = f
+	return nil
+}
+
+// Remove implements FileSystem
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = memPath(name)
+	if _, ok := m.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// Stat implements FileSystem
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target, f, err := m.follow("stat", name)
+	if err != nil {
+		if m.isDir(target) {
+			return memFileInfo{name: path.Base(target), mode: os.ModeDir | 0755}, nil
+		}
+		return nil, err
+	}
+	return newMemFileInfo(path.Base(target), f), nil
+}
+
+// Lstat implements FileSystem
+func (m *MemFS) Lstat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = memPath(name)
+	if f, ok := m.files[name]; ok {
+		return newMemFileInfo(path.Base(name), f), nil
+	}
+	if m.isDir(name) {
+		return memFileInfo{name: path.Base(name), mode: os.ModeDir | 0755}, nil
+	}
+	return nil, &os.PathError{Op: "lstat", Path: name, Err: os.ErrNotExist}
+}
+
+// Readlink implements FileSystem
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = memPath(name)
+	f, ok := m.files[name]
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+	if f.target == "" {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return f.target, nil
+}
+
+type memFileInfo struct {
+	name    string
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+}
+
+func newMemFileInfo(name string, f *memFile) memFileInfo {
+	return memFileInfo{name, f.mode, int64(len(f.data)), f.modTime}
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi memFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,52 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestMemFSReadWrite(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/run/systemd/resolve/stub-resolv.conf", []byte("nameserver 127.0.0.53\n"), 0644))
+	assert.Nil(t, fsys.Symlink("../run/systemd/resolve/stub-resolv.conf", "/etc/resolv.conf"))
+
+	info, err := resolvconf.ResolveSystemPath("/etc/resolv.conf", resolvconf.WithFileSystem(fsys))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ModeStub, info.Mode)
+
+	conf, err := resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.WithFileSystem(fsys))
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.53", conf.GetNameservers()[0].String())
+
+	m, err := resolvconf.DetectManager("/etc/resolv.conf", resolvconf.WithFileSystem(fsys))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ManagerSystemdResolved, m)
+
+	conf = resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFile("/etc/resolv.conf", 0600, resolvconf.WithFileSystem(fsys), resolvconf.Backup(".bak"), resolvconf.ReplaceSymlink()))
+	b, err := fsys.ReadFile("/etc/resolv.conf")
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 8.8.8.8\n\n", string(b))
+	fi, err := fsys.Lstat("/etc/resolv.conf")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	assert.Equal(t, []string{"/etc/resolv.conf", "/etc/resolv.conf.bak", "/run/systemd/resolve/stub-resolv.conf"}, fsys.Files())
+}
+
+func TestMemFSDirectories(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte("nameserver 10.0.0.1\n"), 0644))
+	fi, err := fsys.Stat("/etc")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	_, err = fsys.Stat("/run")
+	assert.True(t, os.IsNotExist(err))
+
+	_, src, err := resolvconf.LoadEffective(resolvconf.WithFileSystem(fsys))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.SourceSystem, src)
+}
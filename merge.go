@@ -0,0 +1,101 @@
+package resolvconf
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+)
+
+// MergePolicy tells Merge what to do with the items of the other Conf that
+// conflict with an item of the Conf, e.g. a different domain or another
+// value of the same option
+type MergePolicy int
+
+// Merge policies
+const (
+	// KeepExisting keeps the domain and the option values of the Conf
+	KeepExisting MergePolicy = iota
+	// PreferOther takes the domain and the option values of the other Conf
+	PreferOther
+	// Append is KeepExisting but adds a conflicting domain to the search
+	// list as the domain is single valued
+	Append
+)
+
+// Merge adds the items of other to the Conf. Nameservers, search domains
+// and sortlist pairs not in the Conf yet are appended in order, items
+// already in it are skipped. Conflicting items are resolved by policy.
+// Comments and raw lines of other are not merged.
+//
+// The items are added like by Add so all limits are enforced, the errors
+// are accumulated and name the items that were dropped. Merging a Conf
+// into itself does nothing
+func (conf *Conf) Merge(other *Conf, policy MergePolicy) error {
+	if other == nil || other == conf {
+		return nil
+	}
+	// A copy so other isn't locked along with the Conf
+	other = other.Clone()
+	conf.lock()
+	defer conf.unlock()
+	var err *multierror.Error
+	for _, item := range other.items {
+		switch it := item.(type) {
+		case *Comment, *RawLine:
+			continue
+		case *Domain:
+			cur := conf.domain()
+			if cur.Name == "" || cur.Name == it.Name || policy == PreferOther {
+				break
+			}
+			if policy != Append {
+				conf.skipMerged(item, "conflicts with domain "+cur.Name)
+				continue
+			}
+			item = NewSearchDomain(it.Name)
+		case *Lookup, *Family:
+			if cur := conf.first(keyOf(it).kind); cur != nil && !cur.Equal(it) && policy != PreferOther {
+				conf.skipMerged(item, "conflicts with "+itemLine(cur))
+				continue
+			}
+		case *Option:
+			if cur := conf.lookup(it); cur != nil && !cur.(*Option).ValueEqual(it) && policy != PreferOther {
+				conf.skipMerged(item, "conflicts with "+cur.String())
+				continue
+			}
+		}
+		if conf.has(item) {
+			if conf.logger.enabled(LogDebug) {
+				conf.logger.l.Debug(fmt.Sprintf("Skipped %s %s, already merged", typeName(item), item),
+					"op", "merge", "item", item.String())
+			}
+			conf.warn(Warning{Code: WarnMergeDuplicate, Item: item,
+				Message: fmt.Sprintf("%s %s is skipped, already merged", typeName(item), item)})
+			continue
+		}
+		if e := conf.addItems([]ConfItem{item}); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+	return err.ErrorOrNil()
+}
+
+// has returns true if the Conf has an item equal to item, for options the
+// value must be the same too
+func (conf *Conf) has(item ConfItem) bool {
+	cur := conf.lookup(item)
+	if opt, ok := item.(*Option); ok && cur != nil {
+		return cur.(*Option).ValueEqual(opt)
+	}
+	return cur != nil
+}
+
+// skipMerged logs and warns about an item of another Conf not merged
+// because of the policy
+func (conf *Conf) skipMerged(item ConfItem, reason string) {
+	if conf.logger.enabled(LogDebug) {
+		conf.logger.l.Debug(fmt.Sprintf("Skipped %s %s, %s", typeName(item), item, reason),
+			"op", "merge", "item", item.String())
+	}
+	conf.warn(Warning{Code: WarnMergeConflict, Item: item,
+		Message: fmt.Sprintf("%s %s is skipped, %s", typeName(item), item, reason)})
+}
@@ -0,0 +1,64 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func mergeSources(t *testing.T) (*resolvconf.Conf, *resolvconf.Conf) {
+	static, err := resolvconf.ReadConf(strings.NewReader("domain example.com\nnameserver 10.0.0.1\nsearch example.com\noptions ndots:2 rotate\n"))
+	assert.Nil(t, err)
+	vpn, err := resolvconf.ReadConf(strings.NewReader("domain corp.example\nnameserver 10.0.0.1\nnameserver 10.8.0.1\nsearch corp.example\noptions ndots:5 rotate\n"))
+	assert.Nil(t, err)
+	return static, vpn
+}
+
+func TestMergeKeepExisting(t *testing.T) {
+	conf, vpn := mergeSources(t)
+	assert.Nil(t, conf.Merge(vpn, resolvconf.KeepExisting))
+	assert.Equal(t, "domain example.com\nnameserver 10.0.0.1\nnameserver 10.8.0.1\n\n"+
+		"search example.com corp.example\n\noptions ndots:2 rotate\n\n", conf.String())
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+	assert.Equal(t, 2, len(vpn.GetNameservers()))
+}
+
+func TestMergePreferOther(t *testing.T) {
+	conf, vpn := mergeSources(t)
+	assert.Nil(t, conf.Merge(vpn, resolvconf.PreferOther))
+	assert.Equal(t, "corp.example", conf.GetDomain().Name)
+	assert.Equal(t, 5, conf.GetOptions()[0].Value)
+	assert.Equal(t, 2, len(conf.GetOptions()))
+}
+
+func TestMergeAppend(t *testing.T) {
+	conf, vpn := mergeSources(t)
+	assert.Nil(t, conf.Merge(vpn, resolvconf.Append))
+	assert.Equal(t, "example.com", conf.GetDomain().Name)
+	assert.Equal(t, []resolvconf.SearchDomain{{Name: "example.com"}, {Name: "corp.example"}}, conf.GetSearchDomains())
+	assert.Equal(t, 2, conf.GetOptions()[0].Value)
+}
+
+func TestMergeEnforcesLimits(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\nnameserver 10.0.0.2\n"))
+	assert.Nil(t, err)
+	other, err := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.2\nnameserver 10.0.0.3\nnameserver 10.0.0.4\n"))
+	assert.Nil(t, err)
+	err = conf.Merge(other, resolvconf.KeepExisting)
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+	assert.Contains(t, err.Error(), "10.0.0.4")
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+}
+
+func TestMergeIntoItself(t *testing.T) {
+	conf, _ := mergeSources(t)
+	same, _ := mergeSources(t)
+	want := conf.String()
+	for _, policy := range []resolvconf.MergePolicy{resolvconf.KeepExisting, resolvconf.PreferOther, resolvconf.Append} {
+		assert.Nil(t, conf.Merge(conf, policy))
+		assert.Nil(t, conf.Merge(same, policy))
+		assert.Equal(t, want, conf.String())
+	}
+}
@@ -2,27 +2,96 @@ package resolvconf
 
 import (
 	"fmt"
+	"github.com/hashicorp/go-multierror"
 	"net"
+	"strconv"
+	"strings"
 )
 
+// DefaultPort is the port nameservers listen on unless another one is set
+const DefaultPort = 53
+
 // Nameserver is the nameserver type
 type Nameserver struct {
-	IP net.IP // IP address
+	IP   net.IP // IP address
+	Zone string // IPv6 zone, e.g. eth0 for fe80::1%eth0
+	Port int    // Port, 0 means DefaultPort
 }
 
 // NewNameserver creates a new Nameserver item
 func NewNameserver(IP net.IP) *Nameserver {
-	return &Nameserver{IP}
+	return &Nameserver{IP: IP}
 }
 
-func (ns Nameserver) applyLimits(conf *Conf) (bool, error) {
+// NewNameserverFromString creates a new Nameserver item from an IPv4 or
+// IPv6 address, optionally with a zone like fe80::1%eth0 or a port in the
+// [addr]:port form. Surrounding whitespace is ignored, an error wrapping
+// ErrInvalidValue is returned if s isn't a valid address
+func NewNameserverFromString(s string) (*Nameserver, error) {
+	ns, err := parseNameserver(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if ns.IP.IsUnspecified() {
+		return nil, fmt.Errorf("%w: unspecified nameserver address: %s", ErrInvalidValue, s)
+	}
+	return ns, nil
+}
+
+// AddNameservers parses the addresses like NewNameserverFromString and adds
+// them. Every address is tried, the errors are accumulated like by Add and
+// name the address they are about
+func (conf *Conf) AddNameservers(addrs ...string) error {
+	var err *multierror.Error
+	for _, addr := range addrs {
+		ns, e := NewNameserverFromString(addr)
+		if e != nil {
+			err = multierror.Append(err, &ItemError{nil, "add", e})
+			continue
+		}
+		if e := conf.Add(ns); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+	return err.ErrorOrNil()
+}
+
+// SetPort sets a non standard port for the nameserver, note that glibc
+// does not support this and it will be written in OpenBSD [addr]:port style
+func (ns *Nameserver) SetPort(port int) *Nameserver {
+	ns.Port = port
+	return ns
+}
+
+// Addr returns the host:port address of the nameserver
+func (ns Nameserver) Addr() string {
+	port := ns.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(ns.host(), strconv.Itoa(port))
+}
 
-	if len(conf.GetNameservers()) == nameserversMaxCount {
-		return false, fmt.Errorf("Too many Nameserver configs, max is %d", nameserversMaxCount)
+func (ns Nameserver) applyLimits(conf *Conf) (bool, error) {
+	if ns.IP == nil || ns.IP.IsUnspecified() {
+		return false, fmt.Errorf("%w: nameserver address %s is not set", ErrInvalidValue, ns)
 	}
 	// Search if conf Nameserver is already added
-	if conf.Find(ns) != nil {
-		return false, fmt.Errorf("Nameserver %s already exists in conf", ns.IP)
+	if conf.duplicate(ns) {
+		if conf.dups == DuplicateIgnore {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: nameserver %s", ErrDuplicateItem, ns.IP)
+	}
+	if conf.limited() && conf.nsLimit > 0 && conf.count(kindNameserver) >= conf.nsLimit {
+		if conf.mode != LimitPermissive {
+			return false, fmt.Errorf("%w, max is %d: nameserver %s", ErrTooManyNameservers, conf.nsLimit, ns)
+		}
+		msg := fmt.Sprintf("Nameserver %s is beyond the limit of %d, glibc ignores it", ns, conf.nsLimit)
+		if conf.logger.enabled(LogWarn) {
+			conf.logWarn(msg, "op", "add", "item", ns.String(), "max", conf.nsLimit)
+		}
+		conf.warn(Warning{Code: WarnLimitExceeded, Item: &ns, Message: msg})
 	}
 
 	return true, nil
@@ -31,11 +100,39 @@ func (ns Nameserver) applyLimits(conf *Conf) (bool, error) {
 // Equal compares to nameservers with eachother, returns true if equal
 func (ns Nameserver) Equal(b ConfItem) bool {
 	if item, ok := b.(*Nameserver); ok {
-		return ns.IP.Equal(item.IP)
+		return ns.IP.Equal(item.IP) && ns.Zone == item.Zone && ns.Port == item.Port
 	}
 	return false
 }
 
 func (ns Nameserver) String() string {
+	if ns.Port != 0 {
+		return fmt.Sprintf("[%s]:%d", ns.host(), ns.Port)
+	}
+	return ns.host()
+}
+
+// MarshalText implements encoding.TextMarshaler, the text is as written in
+// a resolv.conf file
+func (ns Nameserver) MarshalText() ([]byte, error) {
+	return []byte(ns.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, text is parsed like by
+// NewNameserverFromString
+func (ns *Nameserver) UnmarshalText(text []byte) error {
+	n, err := NewNameserverFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*ns = *n
+	return nil
+}
+
+// host returns the address with the zone, if any
+func (ns Nameserver) host() string {
+	if ns.Zone != "" {
+		return ns.IP.String() + "%" + ns.Zone
+	}
 	return ns.IP.String()
 }
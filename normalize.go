@@ -0,0 +1,112 @@
+package resolvconf
+
+// Normalize repairs the configuration so that it holds within the limits
+// of its profile, e.g. after reading it with Lenient or adding items with
+// LimitPermissive. Duplicates are removed, the first one is kept and for
+// options it gets the value of the last one like glibc uses. Nameservers,
+// search domains and sortlist pairs beyond the limits are removed, as are
+// items that can't be written and sortlist pairs glibc ignores. Option
+// values beyond their maximum are capped.
+//
+// The Report lists every change, normalizing again changes nothing. The
+// error holds the errors of Validate that are left, e.g. a missing
+// nameserver required by the policy
+func (conf *Conf) Normalize() (Report, error) {
+	conf.lock()
+	rep := conf.normalize()
+	conf.unlock()
+	return rep, conf.Validate().Err()
+}
+
+func (conf *Conf) normalize() Report {
+	var rep Report
+	drop := make(map[ConfItem]bool)
+	remove := func(item ConfItem) {
+		drop[item] = true
+		rep.Removed = append(rep.Removed, cloneItem(item))
+	}
+	last := make(map[string]*Option) // Last option of each type
+	for _, item := range conf.items {
+		if opt, ok := item.(*Option); ok {
+			last[opt.Type] = opt
+		}
+	}
+	limited := conf.limited()
+	legacy := limited && conf.search != SearchModern
+	seen := make(map[itemKey][]ConfItem)
+	var nameservers, searchDomains, searchChars, sortItems int
+	for _, item := range conf.items {
+		switch item.(type) {
+		case *Comment, *RawLine:
+			continue
+		}
+		if invalidItem(item, conf.unknown) != "" {
+			remove(item)
+			continue
+		}
+		k := keyOf(item)
+		dup := false
+		for _, s := range seen[k] {
+			dup = dup || item.Equal(s)
+		}
+		if dup {
+			remove(item)
+			continue
+		}
+		seen[k] = append(seen[k], item)
+
+		switch it := item.(type) {
+		case *Nameserver:
+			if limited && conf.nsLimit > 0 && nameservers == conf.nsLimit {
+				remove(item)
+				continue
+			}
+			nameservers++
+		case *Domain:
+			if checkName(it.Name) != nil {
+				remove(item)
+			}
+		case *SearchDomain:
+			// Like glibc the bytes are counted with the spaces in between
+			chars := searchChars + searchDomains + len(it.Name)
+			if checkName(it.Name) != nil || legacy && (searchDomains == searchDomainMaxCount || chars > searchDomainMaxCharCount) {
+				remove(item)
+				continue
+			}
+			searchDomains++
+			searchChars += len(it.Name)
+		case *SortItem:
+			if it.check() != nil || limited && (it.Address.To4() == nil || sortItems == sortListMaxCount) {
+				remove(item)
+				continue
+			}
+			sortItems++
+		case *Option:
+			meta, _ := lookupOption(it.Type)
+			want := Option{it.Type, last[it.Type].Value}
+			if meta.hasValue && want.Value < 0 {
+				// No value, glibc ignores it
+				remove(item)
+				continue
+			}
+			if meta.hasValue && meta.max > 0 && want.Value > meta.max {
+				want.Value = meta.max
+			}
+			if want != *it {
+				rep.Changed = append(rep.Changed, Change{From: cloneItem(it), To: cloneItem(&want)})
+				*it = want
+				conf.place(it)
+				conf.changed(OpUpdate, it)
+			}
+		}
+	}
+	if len(drop) > 0 {
+		conf.dropIf(func(item ConfItem) bool {
+			return drop[item]
+		})
+	}
+	if len(rep.Changed) > 0 {
+		conf.cache.reset()
+	}
+	return rep
+}
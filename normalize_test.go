@@ -0,0 +1,79 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetLimitMode(resolvconf.LimitPermissive)
+	conf.SetDuplicatePolicy(resolvconf.DuplicateAllow)
+	conf.SetSearchPolicy(resolvconf.SearchWarnOnly)
+	_, err := conf.ReadFrom(strings.NewReader("nameserver 10.0.0.1\nnameserver 10.0.0.1 # again\nnameserver 10.0.0.2\n" +
+		"nameserver 10.0.0.3\nnameserver 10.0.0.4\nsearch a.com b.com a.com c.com d.com e.com f.com g.com\n" +
+		"sortlist 10.0.0.0/255.0.0.0 2001:db8::/32\noptions ndots:2 timeout:3\n"))
+	assert.Nil(t, err)
+	conf.Find(resolvconf.NewOption("timeout")).(*resolvconf.Option).Value = 40
+
+	rep, err := conf.Normalize()
+	assert.Nil(t, err)
+	var removed []string
+	for _, item := range rep.Removed {
+		removed = append(removed, item.String())
+	}
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.4", "a.com", "g.com", "2001:db8::/ffff:ffff::"}, removed)
+	assert.Equal(t, 1, len(rep.Changed))
+	assert.Equal(t, "timeout:40", rep.Changed[0].From.String())
+	assert.Equal(t, "timeout:30", rep.Changed[0].To.String())
+	// The comment went with the duplicate
+	assert.Equal(t, "nameserver 10.0.0.1\nnameserver 10.0.0.2\nnameserver 10.0.0.3\n\nsortlist 10.0.0.0/255.0.0.0\n\n"+
+		"search a.com b.com c.com d.com e.com f.com\n\noptions ndots:2 timeout:30\n\n", conf.String())
+
+	// Idempotent
+	out := conf.String()
+	rep, err = conf.Normalize()
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.Report{}, rep)
+	assert.Equal(t, out, conf.String())
+}
+
+func TestNormalizeValidConf(t *testing.T) {
+	conf := exportConf(t)
+	out := conf.String()
+	rep, err := conf.Normalize()
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.Report{}, rep)
+	assert.Equal(t, out, conf.String())
+
+	rep, err = resolvconf.New().Normalize()
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.Report{}, rep)
+}
+
+func TestNormalizeInvalidItems(t *testing.T) {
+	conf := exportConf(t)
+	conf.Find(resolvconf.NewSearchDomain("lab.example")).(*resolvconf.SearchDomain).Name = "-lab.example"
+	conf.Find(resolvconf.NewOption("ndots")).(*resolvconf.Option).Value = -1
+	rep, err := conf.Normalize()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rep.Removed))
+	assert.Equal(t, []resolvconf.SearchDomain{{Name: "corp.example"}}, conf.GetSearchDomains())
+	assert.False(t, conf.HasOption("ndots"))
+	assert.True(t, conf.HasOption("rotate"))
+}
+
+func TestNormalizeRegisteredOption(t *testing.T) {
+	// Registered int options have no cap, their value is kept
+	assert.Nil(t, resolvconf.RegisterOption("x-normalize-level", resolvconf.OptionInt))
+	conf, err := resolvconf.ReadConf(strings.NewReader("options x-normalize-level:3 ndots:2\n"))
+	assert.Nil(t, err)
+	rep, err := conf.Normalize()
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.Report{}, rep)
+	opt, ok := conf.GetOption("x-normalize-level")
+	assert.True(t, ok)
+	assert.Equal(t, 3, opt.Get())
+}
@@ -0,0 +1,170 @@
+package resolvconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tokens of the OpenBSD lookup and family keywords, see resolv.conf(5) of
+// OpenBSD
+var (
+	lookupSources = []string{"bind", "file"}
+	familyNames   = []string{"inet4", "inet6"}
+)
+
+// Lookup is the OpenBSD lookup line, the sources of host names in the
+// order they are tried, e.g. file bind. There is only one in a file
+type Lookup struct {
+	Sources []string
+}
+
+// NewLookup creates a lookup line trying the sources in the given order,
+// each of bind and file may be given once. The sources are checked when
+// added
+func NewLookup(sources ...string) *Lookup {
+	return &Lookup{Sources: sources}
+}
+
+func (l Lookup) applyLimits(conf *Conf) (bool, error) {
+	if err := checkTokens("lookup", l.Sources, lookupSources); err != nil {
+		return false, err
+	}
+	return conf.applyOpenBSD(&Lookup{append([]string(nil), l.Sources...)}, kindLookup)
+}
+
+func (l Lookup) String() string {
+	return strings.Join(l.Sources, " ")
+}
+
+// Equal compares two lookup lines, returns true if they have the same
+// sources in the same order
+func (l Lookup) Equal(b ConfItem) bool {
+	if item, ok := b.(*Lookup); ok {
+		return equalTokens(l.Sources, item.Sources)
+	}
+	return false
+}
+
+// Family is the OpenBSD family line, the address families preferred when
+// resolving in the order given, e.g. inet6 inet4. There is only one in a
+// file
+type Family struct {
+	Families []string
+}
+
+// NewFamily creates a family line preferring the families in the given
+// order, each of inet4 and inet6 may be given once. The families are
+// checked when added
+func NewFamily(families ...string) *Family {
+	return &Family{Families: families}
+}
+
+func (f Family) applyLimits(conf *Conf) (bool, error) {
+	if err := checkTokens("family", f.Families, familyNames); err != nil {
+		return false, err
+	}
+	return conf.applyOpenBSD(&Family{append([]string(nil), f.Families...)}, kindFamily)
+}
+
+func (f Family) String() string {
+	return strings.Join(f.Families, " ")
+}
+
+// Equal compares two family lines, returns true if they have the same
+// families in the same order
+func (f Family) Equal(b ConfItem) bool {
+	if item, ok := b.(*Family); ok {
+		return equalTokens(f.Families, item.Families)
+	}
+	return false
+}
+
+// GetLookup returns the lookup line, the zero Lookup if there is none
+func (conf *Conf) GetLookup() Lookup {
+	conf.rlock()
+	defer conf.runlock()
+	if l, ok := conf.first(kindLookup).(*Lookup); ok {
+		return *l
+	}
+	return Lookup{}
+}
+
+// GetFamily returns the family line, the zero Family if there is none
+func (conf *Conf) GetFamily() Family {
+	conf.rlock()
+	defer conf.runlock()
+	if f, ok := conf.first(kindFamily).(*Family); ok {
+		return *f
+	}
+	return Family{}
+}
+
+// first returns the first item of kind, nil if there is none
+func (conf *Conf) first(kind itemKind) ConfItem {
+	for _, item := range conf.items {
+		if keyOf(item).kind == kind {
+			return item
+		}
+	}
+	return nil
+}
+
+// applyOpenBSD checks an OpenBSD only item against the profile and
+// replaces the one of its kind if there is one, like a domain
+func (conf *Conf) applyOpenBSD(item ConfItem, kind itemKind) (bool, error) {
+	if conf.limited() {
+		if conf.mode != LimitPermissive {
+			return false, fmt.Errorf("%w: %s is OpenBSD only, glibc ignores it", ErrUnsupportedKeyword, itemLine(item))
+		}
+		msg := fmt.Sprintf("%s is OpenBSD only, glibc ignores it", itemLine(item))
+		if conf.logger.enabled(LogWarn) {
+			conf.logWarn(msg, "op", "add", "item", item.String())
+		}
+		conf.warn(Warning{Code: WarnOpenBSDOnly, Item: item, Message: msg})
+	}
+	if cur := conf.first(kind); cur != nil {
+		for i, it := range conf.items {
+			if it == cur {
+				conf.replaceItem(i, item)
+				break
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// checkTokens checks the tokens of an OpenBSD keyword, at least one must
+// be given and each of the allowed ones at most once
+func checkTokens(keyword string, tokens, allowed []string) error {
+	if len(tokens) == 0 {
+		return fmt.Errorf("%w: %s needs at least one of %s", ErrInvalidValue, keyword, strings.Join(allowed, " "))
+	}
+	for i, tok := range tokens {
+		known := false
+		for _, a := range allowed {
+			known = known || tok == a
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown %s %s, must be one of %s", ErrInvalidValue, keyword, tok, strings.Join(allowed, " "))
+		}
+		for _, prev := range tokens[:i] {
+			if prev == tok {
+				return fmt.Errorf("%w: %s %s is given twice", ErrInvalidValue, keyword, tok)
+			}
+		}
+	}
+	return nil
+}
+
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const openBSDConf = "nameserver 10.0.0.1\nlookup file bind\nfamily inet6 inet4\n"
+
+func TestLookupAndFamily(t *testing.T) {
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Nil(t, conf.Add(resolvconf.NewLookup("bind", "file"), resolvconf.NewFamily("inet4")))
+	assert.Equal(t, []string{"bind", "file"}, conf.GetLookup().Sources)
+	assert.Equal(t, []string{"inet4"}, conf.GetFamily().Families)
+
+	// There is at most one of each, a later one replaces it
+	assert.Nil(t, conf.Add(resolvconf.NewLookup("file", "bind"), resolvconf.NewFamily("inet6", "inet4")))
+	assert.Equal(t, "file bind", conf.GetLookup().String())
+	assert.Equal(t, "inet6 inet4", conf.GetFamily().String())
+	assert.Equal(t, 2, len(conf.Items()))
+	assert.Equal(t, "lookup file bind\nfamily inet6 inet4\n", conf.String())
+
+	for _, item := range []resolvconf.ConfItem{
+		resolvconf.NewLookup(),
+		resolvconf.NewLookup("yp"),
+		resolvconf.NewLookup("file", "file"),
+		resolvconf.NewFamily(),
+		resolvconf.NewFamily("inet5"),
+		resolvconf.NewFamily("inet4", "inet6", "inet4"),
+	} {
+		assert.True(t, errors.Is(conf.Add(item), resolvconf.ErrInvalidValue), item.String())
+	}
+	assert.Equal(t, "file bind", conf.GetLookup().String())
+
+	assert.True(t, resolvconf.New().GetLookup().Equal(&resolvconf.Lookup{}))
+	assert.Nil(t, resolvconf.New().GetFamily().Families)
+}
+
+func TestLookupAndFamilyOnGlibc(t *testing.T) {
+	conf := resolvconf.New()
+	err := conf.Add(resolvconf.NewLookup("file", "bind"))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnsupportedKeyword))
+	assert.Empty(t, conf.Items())
+
+	conf.SetLimitMode(resolvconf.LimitPermissive)
+	assert.Nil(t, conf.Add(resolvconf.NewFamily("inet6", "inet4")))
+	ws := conf.Warnings()
+	assert.Equal(t, 1, len(ws))
+	assert.Equal(t, resolvconf.WarnOpenBSDOnly, ws[0].Code)
+	assert.Equal(t, "inet6 inet4", conf.GetFamily().String())
+
+	// Invalid tokens are an error in any mode
+	err = conf.Add(resolvconf.NewFamily("inet5"))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+}
+
+func TestReadLookupAndFamily(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader(openBSDConf), resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Nil(t, err)
+	assert.Equal(t, "file bind", conf.GetLookup().String())
+	assert.Equal(t, "inet6 inet4", conf.GetFamily().String())
+	assert.Equal(t, "nameserver 10.0.0.1\n\nlookup file bind\nfamily inet6 inet4\n", conf.String())
+
+	_, err = resolvconf.ReadConf(strings.NewReader("lookup ldap\n"), resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	_, err = resolvconf.ReadConf(strings.NewReader("family\n"), resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+
+	// For glibc the lines are kept as raw lines
+	conf, err = resolvconf.ReadConf(strings.NewReader(openBSDConf))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnsupportedKeyword))
+	assert.Nil(t, conf.GetLookup().Sources)
+	assert.Equal(t, []resolvconf.RawLine{{Text: "lookup file bind"}, {Text: "family inet6 inet4"}}, conf.GetRawLines())
+	assert.Equal(t, openBSDConf, conf.String())
+
+	conf, err = resolvconf.ReadConf(strings.NewReader(openBSDConf), resolvconf.Lenient())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(conf.ParseWarnings()))
+}
+
+func TestLintLookupAndFamily(t *testing.T) {
+	iss, err := resolvconf.Lint(strings.NewReader(openBSDConf))
+	assert.Nil(t, err)
+	assert.Nil(t, iss.Err())
+	assert.Equal(t, 2, len(iss))
+	for _, is := range iss {
+		assert.Equal(t, resolvconf.IssueUnsupported, is.Code)
+		assert.Equal(t, resolvconf.SeverityWarning, is.Severity)
+	}
+}
+
+func TestLookupAndFamilyJSON(t *testing.T) {
+	conf, _ := resolvconf.ReadConf(strings.NewReader(openBSDConf), resolvconf.WithProfile(resolvconf.ProfileNone))
+	b, err := json.Marshal(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"nameservers":["10.0.0.1"],"lookup":["file","bind"],"family":["inet6","inet4"]}`, string(b))
+	back := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Nil(t, json.Unmarshal(b, back))
+	assert.True(t, conf.Equal(back))
+	assert.True(t, conf.Diff(back).IsEmpty())
+
+	other := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	other.Add(resolvconf.NewLookup("bind"))
+	d := conf.Diff(other)
+	assert.Equal(t, 1, len(d.Changed))
+}
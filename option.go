@@ -2,8 +2,102 @@ package resolvconf
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// optionMeta describes a known option
+type optionMeta struct {
+	name     string // The type, shared by all options of this type
+	hasValue bool   // Written as type:value
+	min      int    // Lowest accepted value
+	max      int    // Values above are capped, 0 if not capped
+}
+
+// knownOptions are the options accepted, see resolv.conf(5)
+var knownOptions = map[string]optionMeta{
+	"debug":                 {},
+	"rotate":                {},
+	"no-check-names":        {},
+	"inet6":                 {},
+	"ip6-bytestring":        {},
+	"ip6-dotint":            {},
+	"no-ip6-dotint":         {},
+	"edns0":                 {},
+	"single-request":        {},
+	"single-request-reopen": {},
+	"no-tld-query":          {},
+	"use-vc":                {},
+	"no-reload":             {}, // glibc 2.26
+	"trust-ad":              {}, // glibc 2.31
+	"no-aaaa":               {}, // glibc 2.36
+	"ndots":                 {hasValue: true, min: 0, max: optionNdotsMax},
+	"timeout":               {hasValue: true, min: 0, max: optionTimeoutMax},
+	"attempts":              {hasValue: true, min: 0, max: optionAttemptsMax},
+}
+
+func init() {
+	for t, meta := range knownOptions {
+		meta.name = t
+		knownOptions[t] = meta
+	}
+}
+
+// OptionKind is the kind of an option registered by RegisterOption
+type OptionKind int
+
+// Option kinds
+const (
+	OptionBool OptionKind = iota // Set or not, e.g. rotate
+	OptionInt                    // Written as type:value, e.g. ndots:2
+)
+
+// registered holds the options added by RegisterOption, the map is
+// replaced rather than changed so it can be read without the lock
+var registered struct {
+	sync.Mutex
+	options atomic.Value // map[string]optionMeta
+}
+
+// RegisterOption makes an option not in resolv.conf(5) known by its type,
+// e.g. one of a patched resolver or of another system, so it is parsed,
+// added and written like the others. Values of OptionInt options must not
+// be negative and are not capped. An error is returned if name is already
+// known or isn't a valid option type. It is meant to be called at start
+// up, options can't be unregistered
+func RegisterOption(name string, kind OptionKind) error {
+	if name == "" || strings.ContainsAny(name, ":#; \t\r\n") {
+		return fmt.Errorf("%w: option type %q", ErrInvalidValue, name)
+	}
+	if kind != OptionBool && kind != OptionInt {
+		return fmt.Errorf("%w: option kind %d", ErrInvalidValue, kind)
+	}
+	registered.Lock()
+	defer registered.Unlock()
+	if _, ok := lookupOption(name); ok {
+		return fmt.Errorf("%w: option %s is already known", ErrDuplicateItem, name)
+	}
+	old, _ := registered.options.Load().(map[string]optionMeta)
+	options := make(map[string]optionMeta, len(old)+1)
+	for t, meta := range old {
+		options[t] = meta
+	}
+	options[name] = optionMeta{name: name, hasValue: kind == OptionInt}
+	registered.options.Store(options)
+	return nil
+}
+
+// lookupOption returns the description of a known or registered option
+func lookupOption(name string) (optionMeta, bool) {
+	if meta, ok := knownOptions[name]; ok {
+		return meta, true
+	}
+	options, _ := registered.options.Load().(map[string]optionMeta)
+	meta, ok := options[name]
+	return meta, ok
+}
+
 // Option represents an option item which must have a Type
 // and some options must have a value
 type Option struct {
@@ -16,58 +110,113 @@ type Option struct {
 // debug , with a val the option will be interpreted as an
 // setvalue, e.g. ndots:5
 func NewOption(t string) *Option {
-	// Check va
-	opt := new(Option)
-	opt.Type = t
-	opt.Value = -1
-
-	if _, err := parseOption(opt.String()); err != nil {
+	if _, ok := lookupOption(t); !ok {
 		return nil
 	}
+	return &Option{t, -1}
+}
 
-	return opt
+// NewOptionWithValue creates a new option with a value, e.g. ndots:0. An
+// error is returned if t doesn't take a value or v is out of range, rather
+// than when it is added
+func NewOptionWithValue(t string, v int) (*Option, error) {
+	meta, ok := lookupOption(t)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w %q", ErrUnknownOption, t)
+	case !meta.hasValue:
+		return nil, fmt.Errorf("%w: option %s takes no value", ErrInvalidValue, t)
+	case v < 0 && meta.max == 0:
+		return nil, fmt.Errorf("%w %d for option %s, must be at least 0", ErrInvalidValue, v, t)
+	case v < 0 || meta.max > 0 && v > meta.max:
+		return nil, fmt.Errorf("%w %d for option %s, must be 0 to %d", ErrInvalidValue, v, t, meta.max)
+	}
+	return &Option{meta.name, v}, nil
 }
 
 func (opt *Option) applyLimits(conf *Conf) (bool, error) {
-	if opt.Type == "ndots" && opt.Value < 0 {
-		return false, fmt.Errorf("Bad value %d", opt.Value)
+	meta, ok := lookupOption(opt.Type)
+	if !ok {
+		return conf.applyUnknownOption(opt)
 	}
-	if _, e := parseOption(opt.String()); e != nil {
-		return false, fmt.Errorf("Unknown option %s", opt)
+	// Don't keep the string the type was cut from alive
+	opt.Type = meta.name
+	if meta.hasValue && opt.Value < meta.min {
+		return false, fmt.Errorf("%w %d for option %s, must be at least %d", ErrInvalidValue, opt.Value, opt.Type, meta.min)
 	}
 	// Check limits
-	newVal := -1
-	switch opt.Type {
-	case "ndots":
-		if opt.Value > optionNdotsMax {
-			newVal = optionNdotsMax
+	if meta.max > 0 && opt.Value > meta.max {
+		if conf.ranges == RangeReject {
+			return false, fmt.Errorf("%w %d for option %s, must be at most %d", ErrInvalidValue, opt.Value, opt.Type, meta.max)
 		}
-	case "timeout":
-		if opt.Value > optionTimeoutMax {
-			newVal = optionTimeoutMax
+		if conf.logger.enabled(LogWarn) {
+			conf.logWarn(fmt.Sprintf("Option %s is capped to %d, set value is %d", opt.Type, meta.max, opt.Value),
+				"op", "add", "item", opt.Type, "value", opt.Value, "max", meta.max)
 		}
-	case "attempts":
-		if opt.Value > optionAttemptsMax {
-			newVal = optionAttemptsMax
+		conf.warn(Warning{Code: WarnValueCapped, Item: opt,
+			Message: fmt.Sprintf("Option %s is capped to %d, set value is %d", opt.Type, meta.max, opt.Value)})
+		opt.Value = meta.max
+	}
+	if o := conf.lookup(opt); o != nil {
+		// Last one wins, like for the domain. Boolean options are already set
+		if o.(*Option).Value != opt.Value {
+			conf.warn(Warning{Code: WarnValueUpdated, Item: o,
+				Message: fmt.Sprintf("Option %s is already present, its value is changed from %d to %d", opt.Type, o.(*Option).Value, opt.Value)})
+			o.(*Option).Value = opt.Value
+			conf.place(o)
+			conf.changed(OpUpdate, o)
 		}
+		return false, nil // Dont add
 	}
-	if newVal > -1 {
-		conf.logger.Printf("[WARN] Option %s is capped to %d, set value is %d", opt.Type, newVal, opt.Value)
-		opt.Value = newVal
+	return true, nil
+}
+
+// applyUnknownOption accepts an option of unknown type as it is if the Conf
+// allows them
+func (conf *Conf) applyUnknownOption(opt *Option) (bool, error) {
+	if !conf.unknown || !validUnknownOption(opt.Type) {
+		return false, fmt.Errorf("%w %q", ErrUnknownOption, opt.Type)
 	}
-	if o := conf.Find(opt); o != nil {
-		// If option has a value then update otherwise error
-		if o.(*Option).Value != -1 {
-			i := conf.indexOf(o)
-			conf.items[i].(*Option).Value = opt.Value
-			return false, nil // Dont add
-		}
-		return false, fmt.Errorf("Option %s is already present", opt)
+	if conf.lookup(opt) != nil {
+		return false, nil // Already present
+	}
+	msg := fmt.Sprintf("Option %s is unknown, it is kept as is", opt.Type)
+	if conf.logger.enabled(LogWarn) {
+		conf.logWarn(msg, "op", "add", "item", opt.Type)
 	}
+	conf.warn(Warning{Code: WarnUnknownOption, Item: opt, Message: msg})
 	return true, nil
 }
 
-// Equal compares two Option, return true if equal
+// validUnknownOption returns true if an option of unknown type t can be
+// written as it is
+func validUnknownOption(t string) bool {
+	return t != "" && !hasSpace(t) && !strings.ContainsAny(t, "#;")
+}
+
+// AllowUnknownOptions makes the new Conf keep options of unknown type as
+// they are rather than rejecting them, with a WarnUnknownOption warning.
+// With ReadConf the options of a file not in resolv.conf(5) are read
+// verbatim, the Type holds the whole token, e.g. x-level:3, and the Value
+// is not written. See RegisterOption to have them checked
+func AllowUnknownOptions() ConfOption {
+	return func(conf *Conf) {
+		conf.unknown = true
+	}
+}
+
+// SetAllowUnknownOptions sets whether Add keeps options of unknown type,
+// see AllowUnknownOptions. Options already added are kept
+func (conf *Conf) SetAllowUnknownOptions(allow bool) {
+	conf.lock()
+	defer conf.unlock()
+	conf.unknown = allow
+	conf.cache.reset()
+}
+
+// Equal compares two Option, return true if equal. Only the types are
+// compared, an option is only set once, use ValueEqual to compare values
+// too
 func (opt Option) Equal(b ConfItem) bool {
 	if o, ok := b.(*Option); ok {
 		return opt.Type == o.Type
@@ -75,6 +224,15 @@ func (opt Option) Equal(b ConfItem) bool {
 	return false
 }
 
+// ValueEqual compares two Option, return true if both type and value are
+// equal
+func (opt Option) ValueEqual(b ConfItem) bool {
+	if o, ok := b.(*Option); ok {
+		return opt == *o
+	}
+	return false
+}
+
 // Set sets the value of an option
 func (opt *Option) Set(value int) *Option {
 	if value < 0 {
@@ -89,15 +247,119 @@ func (opt Option) Get() int {
 	return opt.Value
 }
 
+// String returns the option as written in a resolv.conf file, an option of
+// unknown type is written as its type
 func (opt Option) String() string {
-	switch opt.Type {
-	case "debug", "rotate", "no-check-names", "inet6",
-		"ip6-bytestring", "ip6-dotint", "no-ip6-dotint",
-		"edns0", "single-request", "single-request-reopen",
-		"no-tld-query", "use-vc":
-		return fmt.Sprintf("%s", opt.Type)
-	case "ndots", "timeout", "attempts":
+	meta, ok := lookupOption(opt.Type)
+	if !ok {
+		return opt.Type
+	}
+	if meta.hasValue {
 		return fmt.Sprintf("%s:%d", opt.Type, opt.Value)
 	}
-	return ""
+	return opt.Type
+}
+
+// MarshalText implements encoding.TextMarshaler, the text is as written in
+// a resolv.conf file, e.g. ndots:3 or rotate
+func (opt Option) MarshalText() ([]byte, error) {
+	if _, ok := lookupOption(opt.Type); !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownOption, opt.Type)
+	}
+	return []byte(opt.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, text is parsed like an
+// option of a resolv.conf file and the value checked like by
+// NewOptionWithValue
+func (opt *Option) UnmarshalText(text []byte) error {
+	o, err := parseOption(string(text))
+	if err != nil {
+		return err
+	}
+	if o.Value != -1 {
+		if o, err = NewOptionWithValue(o.Type, o.Value); err != nil {
+			return err
+		}
+	}
+	*opt = *o
+	return nil
+}
+
+// GetOption returns the option of type name and true, or false if it is
+// not set. An option is stored once, setting it again replaces the value
+// so the last one wins, also when read from a file listing it twice
+func (conf *Conf) GetOption(name string) (Option, bool) {
+	conf.rlock()
+	defer conf.runlock()
+	for i := len(conf.items) - 1; i >= 0; i-- {
+		if opt, ok := conf.items[i].(*Option); ok && opt.Type == name {
+			return *opt, true
+		}
+	}
+	return Option{}, false
+}
+
+// HasOption returns true if the option of type name is set, e.g. rotate
+func (conf *Conf) HasOption(name string) bool {
+	_, ok := conf.GetOption(name)
+	return ok
+}
+
+// valueOption returns the value of option name, false if it is not set or
+// has no value
+func (conf *Conf) valueOption(name string) (int, bool) {
+	opt, ok := conf.GetOption(name)
+	if !ok || opt.Value < 0 {
+		return 0, false
+	}
+	return opt.Value, true
+}
+
+// Ndots returns the value of the ndots option, false if it is not set
+func (conf *Conf) Ndots() (int, bool) {
+	return conf.valueOption("ndots")
+}
+
+// Timeout returns the value of the timeout option in seconds, false if it
+// is not set
+func (conf *Conf) Timeout() (int, bool) {
+	return conf.valueOption("timeout")
+}
+
+// Attempts returns the value of the attempts option, false if it is not
+// set
+func (conf *Conf) Attempts() (int, bool) {
+	return conf.valueOption("attempts")
+}
+
+// effectiveValue returns the value of option name capped at max like
+// glibc does, def if it is not set
+func (conf *Conf) effectiveValue(name string, def, max int) int {
+	v, ok := conf.valueOption(name)
+	switch {
+	case !ok:
+		return def
+	case v > max:
+		return max
+	}
+	return v
+}
+
+// EffectiveNdots returns the ndots value the resolver uses, the default
+// of 1 if the option is not set and at most 15
+func (conf *Conf) EffectiveNdots() int {
+	return conf.effectiveValue("ndots", defaultNdots, optionNdotsMax)
+}
+
+// EffectiveTimeout returns the timeout in seconds the resolver uses, the
+// default of 5 if the option is not set and at most 30
+func (conf *Conf) EffectiveTimeout() int {
+	return conf.effectiveValue("timeout", defaultTimeout, optionTimeoutMax)
+}
+
+// EffectiveAttempts returns the number of attempts the resolver makes, the
+// default of 2 if the option is not set and at most 5
+func (conf *Conf) EffectiveAttempts() int {
+	return conf.effectiveValue("attempts", defaultAttempts, optionAttemptsMax)
 }
@@ -0,0 +1,118 @@
+package resolvconf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// PromoteNameserver moves the first nameserver with address ip to the
+// front, glibc tries the nameservers in order. Addresses are compared like
+// by FindNameserver, ErrNotFound is returned if there is none
+func (conf *Conf) PromoteNameserver(ip net.IP) error {
+	return conf.promote(kindNameserver, nameserverKey, ip.String(), "nameserver")
+}
+
+// SetNameserverOrder reorders the nameservers to match ips, which must
+// list the address of every nameserver once. Addresses are compared like
+// by FindNameserver and nothing is changed if they don't match
+func (conf *Conf) SetNameserverOrder(ips []net.IP) error {
+	keys := make([]string, len(ips))
+	for i, ip := range ips {
+		keys[i] = ip.String()
+	}
+	return conf.setOrder(kindNameserver, nameserverKey, keys, "nameserver")
+}
+
+// PromoteSearchDomain moves the search domain name to the front, it is
+// then tried first. ErrNotFound is returned if there is none
+func (conf *Conf) PromoteSearchDomain(name string) error {
+	return conf.promote(kindSearchDomain, searchDomainKey, name, "search domain")
+}
+
+// SetSearchDomainOrder reorders the search list to match names, which
+// must list every search domain once. Nothing is changed if they don't
+// match
+func (conf *Conf) SetSearchDomainOrder(names []string) error {
+	return conf.setOrder(kindSearchDomain, searchDomainKey, names, "search domain")
+}
+
+func nameserverKey(item ConfItem) string {
+	return item.(*Nameserver).IP.String()
+}
+
+func searchDomainKey(item ConfItem) string {
+	return item.(*SearchDomain).Name
+}
+
+// reorder rearranges the items of kind, order returns them in their new
+// order. The items take the positions the old ones had, so other items
+// keep theirs
+func (conf *Conf) reorder(kind itemKind, order func([]ConfItem) ([]ConfItem, error)) error {
+	conf.lock()
+	defer conf.unlock()
+	var slots []int
+	var items []ConfItem
+	for i, item := range conf.items {
+		if keyOf(item).kind == kind {
+			slots = append(slots, i)
+			items = append(items, item)
+		}
+	}
+	ordered, err := order(items)
+	if err != nil {
+		return err
+	}
+	for i, slot := range slots {
+		if conf.items[slot] != ordered[i] {
+			conf.items[slot] = ordered[i]
+			conf.changed(OpUpdate, ordered[i])
+		}
+	}
+	conf.invalidate()
+	return nil
+}
+
+func (conf *Conf) promote(kind itemKind, key func(ConfItem) string, k, what string) error {
+	return conf.reorder(kind, func(items []ConfItem) ([]ConfItem, error) {
+		for i, item := range items {
+			if key(item) == k {
+				ordered := append([]ConfItem{item}, items[:i]...)
+				return append(ordered, items[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrNotFound, what, k)
+	})
+}
+
+func (conf *Conf) setOrder(kind itemKind, key func(ConfItem) string, keys []string, what string) error {
+	return conf.reorder(kind, func(items []ConfItem) ([]ConfItem, error) {
+		mismatch := func() error {
+			have := make([]string, len(items))
+			for i, item := range items {
+				have[i] = key(item)
+			}
+			return fmt.Errorf("%w: %s order %s doesn't match %s", ErrInvalidValue, what,
+				strings.Join(keys, " "), strings.Join(have, " "))
+		}
+		if len(keys) != len(items) {
+			return nil, mismatch()
+		}
+		ordered := make([]ConfItem, 0, len(items))
+		used := make([]bool, len(items))
+		for _, k := range keys {
+			found := false
+			for i, item := range items {
+				if !used[i] && key(item) == k {
+					used[i], found = true, true
+					ordered = append(ordered, item)
+					break
+				}
+			}
+			if !found {
+				return nil, mismatch()
+			}
+		}
+		return ordered, nil
+	})
+}
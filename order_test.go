@@ -0,0 +1,48 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPromoteNameserver(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\n# Backup\nnameserver 10.0.0.2\nnameserver [10.0.0.3]:5353\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, conf.PromoteNameserver(net.IP{10, 0, 0, 3}))
+	assert.Equal(t, "nameserver [10.0.0.3]:5353\n# Backup\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n", conf.String())
+	assert.True(t, errors.Is(conf.PromoteNameserver(net.ParseIP("10.0.0.9")), resolvconf.ErrNotFound))
+	assert.Nil(t, conf.Remove(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))))
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+}
+
+func TestSetNameserverOrder(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Nil(t, conf.AddNameservers("10.0.0.1", "10.0.0.2", "::1"))
+	before := conf.String()
+	for _, ips := range [][]net.IP{
+		{net.ParseIP("::1"), net.ParseIP("10.0.0.1")},
+		{net.ParseIP("::1"), net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.1")},
+		{net.ParseIP("::1"), net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.3")},
+	} {
+		assert.True(t, errors.Is(conf.SetNameserverOrder(ips), resolvconf.ErrInvalidValue), "%v", ips)
+		assert.Equal(t, before, conf.String())
+	}
+	assert.Nil(t, conf.SetNameserverOrder([]net.IP{net.ParseIP("::1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")}))
+	assert.Equal(t, "nameserver ::1\nnameserver 10.0.0.2\nnameserver 10.0.0.1\n\n", conf.String())
+}
+
+func TestSearchDomainOrder(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Nil(t, conf.AddSearchDomains("a.com", "b.com", "c.com"))
+	assert.Nil(t, conf.PromoteSearchDomain("c.com"))
+	assert.Equal(t, "search c.com a.com b.com\n\n", conf.String())
+	assert.True(t, errors.Is(conf.PromoteSearchDomain("d.com"), resolvconf.ErrNotFound))
+	assert.Nil(t, conf.SetSearchDomainOrder([]string{"b.com", "a.com", "c.com"}))
+	assert.Equal(t, "search b.com a.com c.com\n\n", conf.String())
+	err := conf.SetSearchDomainOrder([]string{"b.com", "a.com"})
+	assert.Equal(t, "Invalid value: search domain order b.com a.com doesn't match b.com a.com c.com", err.Error())
+}
@@ -0,0 +1,17 @@
+//go:build !windows && !plan9 && !wasip1
+// +build !windows,!plan9,!wasip1
+
+package resolvconf
+
+import (
+	"os"
+	"syscall"
+)
+
+func ownerOf(fi os.FileInfo) *fileOwner {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &fileOwner{int(st.Uid), int(st.Gid)}
+}
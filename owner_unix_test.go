@@ -0,0 +1,40 @@
+//go:build !windows && !plan9 && !wasip1
+// +build !windows,!plan9,!wasip1
+
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileKeepsOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing the owner requires root")
+	}
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	assert.Nil(t, os.Chown(path, 1234, 5678))
+
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.WriteFile(path, 0))
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	st := fi.Sys().(*syscall.Stat_t)
+	assert.Equal(t, uint32(1234), uint32(st.Uid))
+	assert.Equal(t, uint32(5678), uint32(st.Gid))
+
+	// An explicit mode starts over with the owner of the process
+	assert.Nil(t, conf.WriteFile(path, 0644))
+	fi, _ = os.Stat(path)
+	assert.Equal(t, uint32(os.Getuid()), uint32(fi.Sys().(*syscall.Stat_t).Uid))
+}
@@ -1,116 +1,559 @@
 package resolvconf
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"io"
 	"io/ioutil"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 func parseOption(o string) (*Option, error) {
-	keyval := strings.Split(o, ":")
+	opt, val := o, ""
+	hasVal := false
+	if i := strings.IndexByte(o, ':'); i >= 0 {
+		opt, val, hasVal = o[:i], o[i+1:], true
+		// Anything after a second colon is ignored
+		if j := strings.IndexByte(val, ':'); j >= 0 {
+			val = val[:j]
+		}
+	}
+	meta, ok := lookupOption(opt)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w %s", ErrUnknownOption, opt)
+	case !meta.hasValue:
+		// A value makes the type unknown, which Add reports
+		if hasVal {
+			return &Option{o, -1}, nil
+		}
+		return &Option{meta.name, -1}, nil
+	case !hasVal:
+		return nil, fmt.Errorf("%w: %s option requires a value", ErrInvalidValue, opt)
+	}
+	v, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s unable to parse option value %s", ErrInvalidValue, opt, val)
+	}
+	return &Option{meta.name, v}, nil
+}
 
-	switch opt := keyval[0]; opt {
-	case "debug", "rotate", "no-check-names", "inet6",
-		"ip6-bytestring", "ip6-dotint", "no-ip6-dotint",
-		"edns0", "single-request", "single-request-reopen",
-		"no-tld-query", "use-vc":
+// parseConfOption is parseOption keeping an option of unknown type as it
+// is if unknown is set, Add then checks it
+func parseConfOption(o string, unknown bool) (*Option, error) {
+	opt, err := parseOption(o)
+	if err != nil && unknown && errors.Is(err, ErrUnknownOption) {
 		return &Option{o, -1}, nil
-	case "ndots", "timeout", "attempts":
-		val, err := strconv.Atoi(keyval[1])
+	}
+	return opt, err
+}
+
+// parseNameserver parses an address, optionally with an IPv6 zone and a
+// port in the OpenBSD [addr]:port form
+func parseNameserver(s string) (*Nameserver, error) {
+	ns := new(Nameserver)
+	addr := s
+	if strings.HasPrefix(s, "[") {
+		host, port, err := net.SplitHostPort(s)
 		if err != nil {
-			return nil, fmt.Errorf("%s unable to parse option value %s", opt, keyval[1])
+			return nil, fmt.Errorf("%w: malformed nameserver address: %s", ErrInvalidValue, s)
+		}
+		if ns.Port, err = strconv.Atoi(port); err != nil || ns.Port <= 0 || ns.Port > 65535 {
+			return nil, fmt.Errorf("%w: malformed nameserver port: %s", ErrInvalidValue, s)
+		}
+		addr = host
+	}
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr, ns.Zone = addr[:i], addr[i+1:]
+		if ns.Zone == "" {
+			return nil, fmt.Errorf("%w: empty zone in nameserver address: %s", ErrInvalidValue, s)
 		}
-		return &Option{opt, val}, nil
-	default:
-		return nil, fmt.Errorf("Unknown option %s", opt)
 	}
+	if ns.IP = net.ParseIP(addr); ns.IP == nil {
+		return nil, fmt.Errorf("%w: malformed IP address: %s", ErrInvalidValue, s)
+	}
+	if ns.Zone != "" && ns.IP.To4() != nil {
+		return nil, fmt.Errorf("%w: zone in IPv4 nameserver address: %s", ErrInvalidValue, s)
+	}
+	return ns, nil
 }
 
-func parseLine(line string) ([]ConfItem, error) {
-	toks := strings.Fields(line)
-	var items []ConfItem
-	var err error
-	switch keyword := toks[0]; keyword {
+// parseSortItem parses a sortlist pair, an address optionally followed by
+// a slash and a netmask or a prefix length
+func parseSortItem(s string) (*SortItem, error) {
+	addrStr, nmStr := s, ""
+	if j := strings.IndexByte(s, '/'); j >= 0 {
+		addrStr, nmStr = s[:j], s[j+1:]
+		if k := strings.IndexByte(nmStr, '/'); k >= 0 {
+			nmStr = nmStr[:k]
+		}
+	}
+	addr := net.ParseIP(addrStr)
+	if addr == nil {
+		return nil, fmt.Errorf("%w: malformed IP address %s in sortlist", ErrInvalidValue, s)
+	}
+	var nm net.IP
+	if n, err := strconv.Atoi(nmStr); err == nil && addrStr != s {
+		bits := 8 * net.IPv6len
+		if addr.To4() != nil {
+			bits = 8 * net.IPv4len
+		}
+		if n < 0 || n > bits {
+			return nil, fmt.Errorf("%w: prefix length %s out of range in sortlist", ErrInvalidValue, s)
+		}
+		nm = net.IP(net.CIDRMask(n, bits)).To16()
+	} else if addrStr != s {
+		if nm = net.ParseIP(nmStr); nm == nil {
+			return nil, fmt.Errorf("%w: malformed netmask %s in sortlist", ErrInvalidValue, s)
+		}
+	}
+	return &SortItem{addr, nm}, nil
+}
+
+// byteOrderMark is the UTF-8 BOM some editors put at the start of a file
+const byteOrderMark = "\ufeff"
+
+// nextField returns the whitespace separated field of line starting at or
+// after i and the position following it, the field is empty at the end
+func nextField(line string, i int) (string, int) {
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	start := i
+	for i < len(line) && !isSpace(line[i]) {
+		i++
+	}
+	return line[start:i], i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// trailingComment returns the position of a comment at the end of line,
+// starting with # or ; at or after i, or -1 if there is none. Like glibc
+// does for nameservers it needs no whitespace before it, e.g.
+// 10.0.0.1;added by dhclient
+func trailingComment(line string, i int) int {
+	for ; i < len(line); i++ {
+		if line[i] == '#' || line[i] == ';' {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseLine appends the items of line to items. If the line has an error
+// none of its items are appended, the column of the offending field is
+// returned with it, 0 if the error is about the whole line. Comments are
+// appended as Comment, at the end of the line as a trailing one. A line
+// with an unknown keyword is appended as RawLine along with the error, so
+// it can be kept. Options of unknown type are appended as they are if
+// unknown is set
+func parseLine(items []ConfItem, line string, unknown bool) ([]ConfItem, int, error) {
+	line = strings.TrimSuffix(line, "\r")
+	// Comments may be indented like keywords
+	if c := strings.TrimLeft(line, " \t"); c != "" && (c[0] == '#' || c[0] == ';') {
+		return append(items, &Comment{Text: c}), 0, nil
+	}
+	n := len(items)
+	raw := line
+	keyword, i := nextField(line, 0)
+	var comment string
+	if j := trailingComment(line, i); j >= 0 {
+		line, comment = line[:j], line[j:]
+	}
+	field, i := nextField(line, i)
+	// col returns the column of the field ending before i
+	col := func(field string, i int) int {
+		return i - len(field) + 1
+	}
+	switch keyword {
 	case "nameserver":
-		ns := new(Nameserver)
-		if ns.IP = net.ParseIP(toks[1]); ns.IP == nil {
-			err = fmt.Errorf("Malformed IP address: %s", toks[1])
-			break
+		ns, err := parseNameserver(field)
+		if err != nil {
+			return items, col(field, i), err
 		}
 		items = append(items, ns)
 	case "domain":
-		items = append(items, NewDomain(toks[1]))
+		if field == "" {
+			return items, 0, fmt.Errorf("%w: missing value for %s", ErrInvalidValue, keyword)
+		}
+		items = append(items, NewDomain(field))
 	case "search":
-		for _, dom := range toks[1:] {
-			items = append(items, NewSearchDomain(dom))
+		for ; field != ""; field, i = nextField(line, i) {
+			items = append(items, NewSearchDomain(field))
 		}
 	case "sortlist":
-		for _, pair := range toks[1:] {
-			var addr, nm net.IP
-			addrNmStr := strings.Split(pair, "/")
-			if addr = net.ParseIP(addrNmStr[0]); addr == nil {
-				err = fmt.Errorf("Malformed IP address %s in searchlist", pair)
-				break
-			}
-			if len(addrNmStr) > 1 {
-				if nm = net.ParseIP(addrNmStr[1]); nm == nil {
-					err = fmt.Errorf("Malformed netmask %s in searchlist", pair)
-					break
-				}
+		for ; field != ""; field, i = nextField(line, i) {
+			si, err := parseSortItem(field)
+			if err != nil {
+				return items[:n], col(field, i), err
 			}
-			items = append(items, NewSortItem(addr).SetNetmask(nm))
+			items = append(items, si)
 		}
 	case "options":
-		for _, optStr := range toks[1:] {
-			opt, e := parseOption(optStr)
-			if e != nil {
-				err = e
-				break
+		for ; field != ""; field, i = nextField(line, i) {
+			opt, err := parseConfOption(field, unknown)
+			if err != nil {
+				return items[:n], col(field, i), err
 			}
 			items = append(items, opt)
 		}
+	case "lookup", "family":
+		// OpenBSD, the tokens are checked when added
+		var tokens []string
+		for ; field != ""; field, i = nextField(line, i) {
+			tokens = append(tokens, field)
+		}
+		if len(tokens) == 0 {
+			return items, 0, fmt.Errorf("%w: missing value for %s", ErrInvalidValue, keyword)
+		}
+		if keyword == "lookup" {
+			items = append(items, NewLookup(tokens...))
+		} else {
+			items = append(items, NewFamily(tokens...))
+		}
 	default:
-		err = fmt.Errorf("Unknown keyword %s", keyword)
+		_, end := nextField(raw, 0)
+		return append(items, &RawLine{raw}), col(keyword, end), fmt.Errorf("%w %s", ErrUnknownKeyword, keyword)
+	}
+	if comment != "" && len(items) > n {
+		items = append(items, &Comment{Text: comment, Trailing: true})
 	}
+	return items, 0, nil
+}
 
-	return items, err
+// lineScanner splits the lines to parse, blank lines are skipped. It
+// counts the lines so errors and logs can refer to them
+type lineScanner struct {
+	consumed int // Lines consumed so far
+	line     int // Number of the last line returned, from 1
+}
+
+// split is a bufio.SplitFunc
+func (s *lineScanner) split(data []byte, atEOF bool) (int, []byte, error) {
+	adv := 0
+	for {
+		rest := data[adv:]
+		if len(rest) == 0 {
+			return adv, nil, nil
+		}
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			if !atEOF {
+				return adv, nil, nil // Request more data
+			}
+			end = len(rest)
+		}
+		line := rest[:end]
+		next := adv + end
+		if end < len(rest) {
+			next++
+		}
+		s.consumed++
+		if len(bytes.TrimSpace(line)) == 0 {
+			adv = next
+			continue
+		}
+		s.line = s.consumed
+		return next, line, nil
+	}
+}
+
+// replaceSearch drops the search domains of an earlier line before those
+// of the search line being parsed are added, like glibc a later search
+// line replaces the list. Domain and options lines are handled by their
+// items, a later domain wins and repeated options combine
+func (conf *Conf) replaceSearch(line int) {
+	var old []string
+	for i := len(conf.items) - 1; i >= 0; i-- {
+		sd, ok := conf.items[i].(*SearchDomain)
+		if !ok {
+			continue
+		}
+		if i+1 < len(conf.items) {
+			if c, ok := conf.items[i+1].(*Comment); ok && c.Trailing {
+				conf.removeItem(i + 1)
+			}
+		}
+		conf.removeItem(i)
+		old = append([]string{sd.Name}, old...)
+	}
+	msg := fmt.Sprintf("Search list %s is replaced by the one on line %d", strings.Join(old, " "), line)
+	if conf.logger.enabled(LogDebug) {
+		conf.logger.l.Debug(msg, "op", "parse", "line", line)
+	}
+	conf.warn(Warning{Code: WarnSearchReplaced, Message: msg})
+}
+
+// ReadOption customizes ReadConf
+type ReadOption interface {
+	applyRead(o *readOptions)
+}
+
+type readOptions struct {
+	lenient bool
+	search  SearchPolicy // Of the new Conf
+	dups    DuplicatePolicy
+	ranges  RangePolicy
+	conf    []ConfOption // Of the new Conf
+}
+
+func (o readOptions) applyRead(dst *readOptions) {
+	*dst = o
+}
+
+// ParseOption is an option accepted both by ReadConf and by functions
+// reading files
+type ParseOption interface {
+	ReadOption
+	FileOption
+}
+
+type lenient struct{}
+
+func (lenient) applyRead(o *readOptions) {
+	o.lenient = true
+}
+
+func (lenient) applyFile(o *fileOptions) {
+	o.read.lenient = true
+}
+
+// Lenient makes reading succeed even if lines are invalid, like glibc
+// does. The lines are skipped and reported by ParseWarnings only, errors
+// reading the input are still returned
+func Lenient() ParseOption {
+	return lenient{}
+}
+
+type duplicates DuplicatePolicy
+
+func (d duplicates) applyRead(o *readOptions) {
+	o.dups = DuplicatePolicy(d)
+}
+
+func (d duplicates) applyFile(o *fileOptions) {
+	o.read.dups = DuplicatePolicy(d)
+}
+
+// WithDuplicates sets the duplicate policy ReadConf uses for the lines of
+// the file, the Conf read keeps it
+func WithDuplicates(p DuplicatePolicy) ParseOption {
+	return duplicates(p)
+}
+
+type ranges RangePolicy
+
+func (r ranges) applyRead(o *readOptions) {
+	o.ranges = RangePolicy(r)
+}
+
+func (r ranges) applyFile(o *fileOptions) {
+	o.read.ranges = RangePolicy(r)
+}
+
+// WithRangePolicy sets the range policy ReadConf uses for the option
+// values of the file, e.g. RangeReject to fail on ndots:64 rather than
+// read it as ndots:15. The Conf read keeps it
+func WithRangePolicy(p RangePolicy) ParseOption {
+	return ranges(p)
+}
+
+// ParseWarning is a line ReadConf skipped, or whose items it rejected
+type ParseWarning struct {
+	Line   int
+	Column int    // Column of the offending field, 0 if about the whole line
+	Text   string // The line as read
+	Err    error  // The reason, wrapping the sentinel or an ItemError
+}
+
+func (pw ParseWarning) String() string {
+	return fmt.Sprintf("line %d: %s: %q", pw.Line, pw.Err, pw.Text)
+}
+
+// ParseWarnings returns the lines ReadConf skipped or whose items it
+// rejected, in the order of the input. Unknown keywords are among them
+// although their lines are kept as RawLines
+func (conf *Conf) ParseWarnings() []ParseWarning {
+	conf.rlock()
+	defer conf.runlock()
+	return append([]ParseWarning(nil), conf.parsed...)
 }
 
 // ReadConf will read a configuration from given io.Reader
 //
 // Returns a new Conf object when successful otherwise
-// nil and an error
-func ReadConf(r io.Reader) (*Conf, error) {
+// nil and an error. Each line that fails is reported as a *ParseError,
+// the valid lines are read anyway and the Conf is returned with the
+// error. With Lenient only errors reading r are returned
+func ReadConf(r io.Reader, opts ...ReadOption) (*Conf, error) {
+	var o readOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyRead(&o)
+		}
+	}
+	start := time.Now()
+	conf, err := readConf(r, o)
+	instrumentation().ObserveParse(time.Since(start), err)
+	return conf, err
+}
+
+// Line lengths, the average is used to size the Conf up front
+const (
+	avgLineLen = 24
+	maxLineLen = 1 << 20
+)
+
+func readConf(r io.Reader, opts readOptions) (*Conf, error) {
+	conf := New(opts.conf...)
+	conf.search, conf.dups, conf.ranges = opts.search, opts.dups, opts.ranges
+	ok, err := conf.readInto(r, opts)
+	if !ok {
+		return nil, err
+	}
+	return conf, err
+}
+
+// readInto parses r and adds its items to conf, the caller must hold the
+// lock if conf is shared. ok is false if r could not be read to the end,
+// the items read are added anyway
+func (conf *Conf) readInto(r io.Reader, opts readOptions) (bool, error) {
 	var res *multierror.Error
-	conf := New()
-	b, err := ioutil.ReadAll(r)
-	if err != nil {
-		res = multierror.Append(res, err)
-		return nil, res
+	bufSize := 512
+	if l, ok := r.(interface{ Len() int }); ok {
+		conf.grow(l.Len()/avgLineLen + 1)
+		if l.Len() < bufSize {
+			bufSize = l.Len() + 1
+		}
 	}
-	confFile := strings.TrimSpace(string(b[:]))
-	lines := strings.Split(confFile, "\n")
-	for _, line := range lines {
-		// Check if this line is a comment or empty
-		if len(line) == 0 || line[0] == byte('#') || line[0] == byte(';') {
-			continue
+
+	var items []ConfItem
+	var lines lineScanner
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, bufSize), maxLineLen)
+	scanner.Split(lines.split)
+	first := true
+	search := false // A search line was read
+	for scanner.Scan() {
+		text := scanner.Text()
+		line := text
+		offset := 0 // Columns trimmed off line
+		if first {
+			// A byte order mark and leading whitespace of the file are not
+			// significant
+			trimmed := strings.TrimLeftFunc(strings.TrimPrefix(line, byteOrderMark), unicode.IsSpace)
+			offset = len(line) - len(trimmed)
+			line = trimmed
+			first = false
+			if line == "" {
+				continue
+			}
 		}
-		// Otherwise decode line
-		opt, err := parseLine(line)
-		if err != nil {
-			res = multierror.Append(res, err)
+		var err error
+		var col int
+		if items, col, err = parseLine(items[:0], line, conf.unknown); err != nil {
+			if conf.logger.enabled(LogWarn) {
+				conf.logger.l.Warn(fmt.Sprintf("Skipped line %d: %s", lines.line, err), "op", "parse", "line", lines.line, "text", line, "error", err)
+			}
+			if col > 0 {
+				col += offset
+			}
+			conf.parsed = append(conf.parsed, ParseWarning{lines.line, col, text, err})
+			res = multierror.Append(res, &ParseError{Line: lines.line, Column: col, Err: err})
+			for _, o := range items {
+				conf.add(o) // The RawLine of an unknown keyword
+			}
 			continue
 		}
-
-		for _, o := range opt {
-			if err := conf.Add(o); err != nil {
-				res = multierror.Append(res, err)
+		conf.parsing(lines.line, text)
+		if len(items) > 0 {
+			if _, ok := items[0].(*SearchDomain); ok {
+				if search && conf.count(kindSearchDomain) > 0 {
+					conf.replaceSearch(lines.line)
+				}
+				search = true
 			}
 		}
+		added := false
+		for _, o := range items {
+			if c, ok := o.(*Comment); ok && c.Trailing && !added {
+				continue // Its items were rejected
+			}
+			if _, err := conf.add(o); err != nil {
+				if conf.logger.enabled(LogWarn) {
+					conf.logger.l.Warn(fmt.Sprintf("Rejected %s from line %d: %s", o, lines.line, err), "op", "parse", "line", lines.line, "item", o.String(), "error", err)
+				}
+				ie := &ItemError{o, "add", err}
+				conf.parsed = append(conf.parsed, ParseWarning{lines.line, 0, text, ie})
+				res = multierror.Append(res, &ParseError{Line: lines.line, Err: ie})
+				if errors.Is(err, ErrUnsupportedKeyword) {
+					// Kept like a line with an unknown keyword
+					conf.add(&RawLine{strings.TrimSuffix(line, "\r")})
+				}
+				continue
+			}
+			added = true
+			if conf.logger.enabled(LogDebug) {
+				kind := typeName(o)
+				conf.logger.l.Debug(fmt.Sprintf("Parsed %s %s from line %d", kind, o, lines.line), "op", "parse", "line", lines.line, "kind", kind, "item", o.String())
+			}
+		}
+	}
+	conf.parsing(0, "")
+	if err := scanner.Err(); err != nil {
+		var perr *os.PathError
+		if !errors.As(err, &perr) {
+			// E.g. bufio.ErrTooLong, read errors of files name the file
+			err = &ParseError{Line: lines.consumed + 1, Err: err}
+		}
+		res = multierror.Append(res, err)
+		return false, res
+	}
+	conf.releaseIndex()
+	if opts.lenient {
+		return true, nil
 	}
-	return conf, res.ErrorOrNil()
+	return true, res.ErrorOrNil()
+}
+
+// ReadFrom parses r like ReadConf and adds its items to the configuration,
+// Add rules apply so limits and duplicates are checked against the items
+// already there. It implements io.ReaderFrom, the count is the number of
+// bytes read from r. Errors are those of ReadConf, if r fails the items
+// read so far are still added
+func (conf *Conf) ReadFrom(r io.Reader) (int64, error) {
+	b, rerr := ioutil.ReadAll(r)
+	in := io.Reader(bytes.NewReader(b))
+	if rerr != nil {
+		// Reported like ReadConf does, after the lines read
+		in = io.MultiReader(in, &failingReader{rerr})
+	}
+	if conf.idx == nil {
+		// Zero Conf
+		*conf = *New()
+	}
+	conf.lock()
+	defer conf.unlock()
+	_, err := conf.readInto(in, readOptions{})
+	return int64(len(b)), err
+}
+
+// failingReader returns err on every Read
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
 }
@@ -0,0 +1,161 @@
+package resolvconf
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The line based parser replaced one splitting the whole file, the old one
+// is kept here to check that both agree while the new one settles in
+
+func legacyParseLine(line string) ([]ConfItem, error) {
+	toks := strings.Fields(line)
+	var items []ConfItem
+	var err error
+	switch keyword := toks[0]; keyword {
+	case "nameserver":
+		ns, e := parseNameserver(toks[1])
+		if e != nil {
+			err = e
+			break
+		}
+		items = append(items, ns)
+	case "domain":
+		items = append(items, NewDomain(toks[1]))
+	case "search":
+		for _, dom := range toks[1:] {
+			items = append(items, NewSearchDomain(dom))
+		}
+	case "sortlist":
+		for _, pair := range toks[1:] {
+			var addr, nm net.IP
+			addrNmStr := strings.Split(pair, "/")
+			if addr = net.ParseIP(addrNmStr[0]); addr == nil {
+				err = fmt.Errorf("Malformed IP address %s in searchlist", pair)
+				break
+			}
+			if len(addrNmStr) > 1 {
+				if nm = net.ParseIP(addrNmStr[1]); nm == nil {
+					err = fmt.Errorf("Malformed netmask %s in searchlist", pair)
+					break
+				}
+			}
+			items = append(items, NewSortItem(addr).SetNetmask(nm))
+		}
+	case "options":
+		for _, optStr := range toks[1:] {
+			opt, e := parseOption(optStr)
+			if e != nil {
+				err = e
+				break
+			}
+			items = append(items, opt)
+		}
+	default:
+		err = fmt.Errorf("Unknown keyword %s", keyword)
+	}
+
+	return items, err
+}
+
+func legacyReadConf(r io.Reader) (*Conf, error) {
+	var res *multierror.Error
+	conf := New()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		res = multierror.Append(res, err)
+		return nil, res
+	}
+	confFile := strings.TrimSpace(string(b[:]))
+	lines := strings.Split(confFile, "\n")
+	for _, line := range lines {
+		// Check if this line is a comment or empty
+		if len(line) == 0 || line[0] == byte('#') || line[0] == byte(';') {
+			continue
+		}
+		// Otherwise decode line
+		opt, err := legacyParseLine(line)
+		if err != nil {
+			res = multierror.Append(res, err)
+			continue
+		}
+
+		// A later search line replaces the list
+		if len(opt) > 0 {
+			if _, ok := opt[0].(*SearchDomain); ok {
+				conf.removeIf(func(item ConfItem) bool {
+					_, ok := item.(*SearchDomain)
+					return ok
+				})
+			}
+		}
+		for _, o := range opt {
+			if err := conf.Add(o); err != nil {
+				res = multierror.Append(res, err)
+			}
+		}
+	}
+	return conf, res.ErrorOrNil()
+}
+
+var parityInputs = []string{
+	"",
+	"nameserver 8.8.8.8",
+	"  \n\n  # leading comment\nnameserver 8.8.8.8\r\nnameserver 8.8.4.4\r\n",
+	"nameserver 10.0.0.1\nnameserver 10.0.0.2\nnameserver 10.0.0.3\nnameserver 10.0.0.4\n",
+	"nameserver [2001:db8::1]:5353\nnameserver [10.0.0.1]:99999\nnameserver bogus\n",
+	"domain foo.com bar.com\ndomain baz.com\n",
+	"search a.com b.com\tc.com  \nsearch a.com d.com\n",
+	"search a b c d e f g\n",
+	"sortlist 130.155.160.0/255.255.240.0 130.155.0.0\nsortlist 10.0.0.0/bogus 10.1.0.0\n",
+	"sortlist 10.0.0.0/255.0.0.0/8 10.0.0.0/255.255.0.0\nsortlist 1.2.3.4/\n",
+	"options ndots:2 timeout:3 attempts:2 rotate edns0\noptions ndots:20 debug\n",
+	"options rotate rotate\noptions bogus ndots:1\noptions ndots\noptions ndots:x\n",
+	// Indented comments after the first line were an unknown keyword to the
+	// old parser, see testdata/messy/indented.conf
+	"  # indented comment later\nnameserver 1.1.1.1\n; semicolon\n#\n",
+	"unknown keyword\nnameserver 1.1.1.1 extra tokens\n",
+}
+
+// parityConf describes the result of a parse, errors are only counted as
+// the messages have changed since. The old parser dropped comments and
+// unknown lines, they are removed before comparing
+func parityConf(t *testing.T, conf *Conf, err error) string {
+	errs := 0
+	if merr, ok := err.(*multierror.Error); ok {
+		errs = len(merr.Errors)
+	} else if err != nil {
+		errs = 1
+	}
+	if conf == nil {
+		return fmt.Sprintf("<nil> %d errors", errs)
+	}
+	conf.RemoveComments()
+	conf.RemoveRawLines()
+	var buf bytes.Buffer
+	assert.Nil(t, conf.Write(&buf))
+	return fmt.Sprintf("%q %d errors", buf.String(), errs)
+}
+
+func TestParserParity(t *testing.T) {
+	inputs := append([]string(nil), parityInputs...)
+	files, _ := filepath.Glob("testdata/*.conf")
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		assert.Nil(t, err)
+		inputs = append(inputs, string(b))
+	}
+	for _, in := range inputs {
+		oldConf, oldErr := legacyReadConf(strings.NewReader(in))
+		newConf, newErr := readConf(strings.NewReader(in), readOptions{})
+		assert.Equal(t, parityConf(t, oldConf, oldErr), parityConf(t, newConf, newErr), in)
+	}
+}
@@ -1,8 +1,13 @@
 package resolvconf_test
 
 import (
+	"bytes"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
 	"net"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -33,6 +38,13 @@ func TestReadFaultyNewNameserver(t *testing.T) {
 	assert.Nil(t, conf.Find(resolvconf.NewNameserver(net.ParseIP("www.golang.org"))))
 }
 
+func TestReadScopedNameserver(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver fe80::1%eth0\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "eth0", conf.GetNameservers()[0].Zone)
+	assert.Equal(t, "nameserver fe80::1%eth0\n\n", conf.String())
+}
+
 func TestReadUnknownConfNewOption(t *testing.T) {
 	_, err := resolvconf.ReadConf(strings.NewReader("nameserv 8.8.8.9"))
 	assert.NotNil(t, err)
@@ -135,7 +147,8 @@ func TestReadSortlistWithNetmask(t *testing.T) {
 	conf, err := resolvconf.ReadConf(strings.NewReader("sortlist 130.155.160.0/255.255.240.0"))
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(conf.GetSortItems()))
-	assert.NotNil(t, conf.Find(*resolvconf.NewSortItem(net.ParseIP("130.155.160.0"))))
+	assert.NotNil(t, conf.Find(*resolvconf.NewSortItem(net.ParseIP("130.155.160.0")).SetNetmask(net.ParseIP("255.255.240.0"))))
+	assert.Nil(t, conf.Find(*resolvconf.NewSortItem(net.ParseIP("130.155.160.0"))))
 }
 
 func TestReadSortlistWithBadNetmask(t *testing.T) {
@@ -197,3 +210,202 @@ func TestAllOptions(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 15, len(conf.GetOptions()))
 }
+
+func TestReadNameserverWithPort(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver [2001:db8::1]:5353\nnameserver [10.0.0.1]:53"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5353, conf.GetNameservers()[0].Port)
+	assert.Equal(t, "[2001:db8::1]:5353", conf.GetNameservers()[0].String())
+	assert.Equal(t, 53, conf.GetNameservers()[1].Port)
+
+	_, err = resolvconf.ReadConf(strings.NewReader("nameserver [10.0.0.1]:foo"))
+	assert.NotNil(t, err)
+	_, err = resolvconf.ReadConf(strings.NewReader("nameserver [10.0.0.1"))
+	assert.NotNil(t, err)
+}
+
+func TestReadConfThenAddKeepsLimits(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8\nnameserver 8.8.4.4\noptions ndots:2"))
+	assert.Nil(t, err)
+	assert.NotNil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))))
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("1.1.1.1"))))
+	assert.NotNil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("1.0.0.1"))))
+	assert.Nil(t, conf.Add(resolvconf.NewOption("ndots").Set(3)))
+	assert.Equal(t, 1, len(conf.GetOptions()))
+	assert.Equal(t, 3, conf.GetOptions()[0].Value)
+}
+
+func TestReadFedoraConf(t *testing.T) {
+	in := "# Generated by NetworkManager\nsearch localdomain\nnameserver 192.168.122.1\noptions edns0 trust-ad\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Nil(t, err)
+	assert.NotNil(t, conf.Find(resolvconf.NewOption("trust-ad")))
+	assert.Equal(t, in, conf.String())
+
+	for _, opt := range []string{"trust-ad", "no-reload", "no-aaaa"} {
+		assert.NotNil(t, resolvconf.NewOption(opt), opt)
+		conf, err = resolvconf.ReadConf(strings.NewReader("options " + opt))
+		assert.Nil(t, err, opt)
+		assert.Equal(t, "options "+opt+"\n\n", conf.String())
+	}
+}
+
+func TestReadConfLenient(t *testing.T) {
+	in := "nameserver 8.8.8.8\n  options rotate foo\nnameserver 8.8.8.8\nfoo bar\nsearch example.com\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in), resolvconf.Lenient())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Equal(t, 1, len(conf.GetSearchDomains()))
+	assert.Empty(t, conf.GetOptions())
+
+	pws := conf.ParseWarnings()
+	assert.Equal(t, 3, len(pws))
+	assert.Equal(t, resolvconf.ParseWarning{Line: 2, Column: 18, Text: "  options rotate foo", Err: pws[0].Err}, pws[0])
+	assert.True(t, errors.Is(pws[0].Err, resolvconf.ErrUnknownOption))
+	assert.Equal(t, 3, pws[1].Line)
+	assert.True(t, errors.Is(pws[1].Err, resolvconf.ErrDuplicateItem))
+	assert.Equal(t, `line 4: Unknown keyword foo: "foo bar"`, pws[2].String())
+
+	// Strict reading reports the same lines
+	conf, err = resolvconf.ReadConf(strings.NewReader(in))
+	assert.Contains(t, err.Error(), "3 errors occurred")
+	assert.Equal(t, 3, len(conf.ParseWarnings()))
+
+	conf, err = resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8\n"), resolvconf.Lenient())
+	assert.Nil(t, err)
+	assert.Empty(t, conf.ParseWarnings())
+}
+
+func TestReadConfFileLenient(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte("nameserver 8.8.8\nnameserver 1.1.1.1\n"), 0644))
+	conf, err := resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.Lenient())
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.1.1", conf.GetNameservers()[0].String())
+	assert.Equal(t, 1, conf.ParseWarnings()[0].Line)
+
+	_, err = resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.WithFileSystem(fsys))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+}
+
+func TestReadMessyFiles(t *testing.T) {
+	for file, golden := range map[string]string{
+		"tabs.conf":       "clean.golden.conf",
+		"semicolons.conf": "semicolons.golden.conf",
+		"crlf.conf":       "clean.golden.conf",
+		"bom.conf":        "clean.golden.conf",
+		"indented.conf":   "indented.golden.conf",
+	} {
+		conf, err := resolvconf.ReadPath(filepath.Join("testdata", "messy", file))
+		assert.Nil(t, err, file)
+		nss := conf.GetNameservers()
+		assert.Equal(t, 2, len(nss), file)
+		assert.Equal(t, "10.0.0.2", nss[len(nss)-1].String(), file)
+		assert.Equal(t, []resolvconf.SearchDomain{{Name: "corp.example.com"}, {Name: "example.com"}}, conf.GetSearchDomains(), file)
+		assert.Equal(t, 2, conf.EffectiveNdots(), file)
+		assert.Equal(t, 3, conf.EffectiveTimeout(), file)
+		want, err := ioutil.ReadFile(filepath.Join("testdata", "messy", golden))
+		assert.Nil(t, err)
+		assert.Equal(t, string(want), conf.String(), file)
+		assert.NotContains(t, conf.String(), "\r", file)
+		assert.NotContains(t, conf.String(), "\t", file)
+		assert.Empty(t, conf.GetRawLines(), file)
+	}
+
+	// A byte order mark alone on the first line
+	conf, err := resolvconf.ReadConf(strings.NewReader("\ufeff\nnameserver 10.0.0.1\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Empty(t, conf.GetRawLines())
+}
+
+func TestReadRepeatedLinesLastWins(t *testing.T) {
+	for file, want := range map[string][3]string{
+		"dhclient-appended.conf": {"vpn.example.net", "vpn.example.net corp.example.com", "timeout:1 attempts:3"},
+		"debian-resolvconf.conf": {"office.lan", "office.lan home.lan example.org", "ndots:1 rotate"},
+		"docker-host.conf":       {"", "svc.cluster.local cluster.local", "edns0 trust-ad"},
+	} {
+		conf, err := resolvconf.ReadPath(filepath.Join("testdata", "repeated", file))
+		assert.Nil(t, err, file)
+		var search, opts []string
+		for _, sd := range conf.GetSearchDomains() {
+			search = append(search, sd.Name)
+		}
+		for _, opt := range conf.GetOptions() {
+			opts = append(opts, opt.String())
+		}
+		assert.Equal(t, want[0], conf.GetDomain().Name, file)
+		assert.Equal(t, want[1], strings.Join(search, " "), file)
+		assert.Equal(t, want[2], strings.Join(opts, " "), file)
+		assert.Equal(t, resolvconf.WarnSearchReplaced, conf.Warnings()[0].Code, file)
+	}
+
+	// The trailing comment of the replaced line goes with it
+	conf, err := resolvconf.ReadConf(strings.NewReader("search a.com b.com # old\nnameserver 10.0.0.1\nsearch b.com c.com\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "nameserver 10.0.0.1\n\nsearch b.com c.com\n\n", conf.String())
+	assert.Equal(t, resolvconf.Warning{Code: resolvconf.WarnSearchReplaced, Line: 3,
+		Message: "Search list a.com b.com is replaced by the one on line 3"}, conf.Warnings()[0])
+
+	// Repeats within a line are still duplicates
+	_, err = resolvconf.ReadConf(strings.NewReader("search a.com a.com\n"))
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+}
+
+type brokenReader struct{}
+
+func (brokenReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestReadFrom(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com")))
+	in := "nameserver 10.0.0.2\nsearch b.com\noptions rotate\n"
+	n, err := conf.ReadFrom(strings.NewReader(in))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(in)), n)
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+	assert.Equal(t, 2, len(conf.GetSearchDomains()))
+
+	// Limits and duplicates count the items already there
+	in = "nameserver 10.0.0.1\nnameserver 10.0.0.3\nnameserver 10.0.0.4\nfoo\n"
+	n, err = conf.ReadFrom(strings.NewReader(in))
+	assert.Equal(t, int64(len(in)), n)
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnknownKeyword))
+	var pe *resolvconf.ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 1, pe.Line)
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+
+	var zero resolvconf.Conf
+	n, err = zero.ReadFrom(bytes.NewBufferString("domain example.com\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(19), n)
+	assert.Equal(t, "example.com", zero.GetDomain().Name)
+
+	// Read errors are reported after the lines read
+	failing := io.MultiReader(strings.NewReader("nameserver 10.0.0.9\n"), brokenReader{})
+	conf = resolvconf.New()
+	n, err = conf.ReadFrom(failing)
+	assert.Equal(t, int64(20), n)
+	assert.Contains(t, err.Error(), "line 2: broken")
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+}
+
+func TestConfIsReaderFromAndWriterTo(t *testing.T) {
+	src, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.0.0.1\nsearch a.com\n"))
+	dst := resolvconf.New()
+	var _ io.WriterTo = src
+	var _ io.ReaderFrom = dst
+	var buf bytes.Buffer
+	n, err := src.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(src.String())), n)
+	m, err := dst.ReadFrom(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, n, m)
+	assert.True(t, src.Equal(dst))
+}
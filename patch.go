@@ -0,0 +1,351 @@
+package resolvconf
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// patchKinds are the kinds of lines Patch edits, in the order new sections
+// are placed in
+var patchKinds = []itemKind{kindNameserver, kindDomain, kindSearchDomain, kindSortItem, kindOption, kindLookup, kindFamily}
+
+// Patch updates the file at path to hold the configuration, changing as
+// few lines as possible. Lines whose items are unchanged are kept byte for
+// byte, as are comments, blank lines and lines that are not understood.
+// Lines whose items changed are rewritten in place keeping their trailing
+// comment, lines of removed items are deleted and new items are added
+// after the lines of their kind, or where Write would put them if there
+// are none. Nameserver lines are not rewritten but replaced, their comment
+// is about the server. Comments, raw lines and the header of the Conf are
+// not written. If the file doesn't exist the configuration is written
+// whole.
+//
+// The file is replaced like by WriteFile keeping its mode and owner, the
+// write options of WriteFile apply, e.g. DryRun to get the content without
+// writing it
+func (conf *Conf) Patch(path string, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+	o.patch = true
+	return conf.writeFileContext(context.Background(), path, 0, o)
+}
+
+// DryRun makes WriteFile and Patch store the content they would write in
+// content rather than writing it, no AfterWrite hooks are run. The checks
+// made before writing still apply, e.g. a *SymlinkError is returned
+func DryRun(content *[]byte) WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.dryRun = content
+	})
+}
+
+// patchLine is a line of the file being patched
+type patchLine struct {
+	text    string   // As read, with the line ending
+	lead    string   // Byte order mark and whitespace of the first line
+	kind    itemKind // kindOther if Patch keeps the line as is
+	canon   string   // The items as Write would write them
+	comment string   // Comment at the end of the line, if any
+	eol     string
+	deleted bool
+	before  []string // Lines inserted before it
+	after   []string // Lines inserted after it
+}
+
+// patch returns prev changed to hold the items of the configuration
+func (conf *Conf) patch(prev []byte, o *writeOptions) []byte {
+	conf.rlock()
+	rs := conf.renderSet(o)
+	ro := readOptions{lenient: true, search: conf.search, dups: conf.dups, ranges: conf.ranges}
+	if conf.unknown {
+		ro.conf = []ConfOption{AllowUnknownOptions()}
+	}
+	conf.runlock()
+	// Kinds the file already holds as wanted are left alone, e.g. options
+	// spread over several lines
+	var old *renderSet
+	if file, _ := readConf(bytes.NewReader(prev), ro); file != nil {
+		old = file.renderSet(o)
+	}
+
+	lines := splitPatchLines(string(prev), rs)
+	eol := "\n"
+	if len(lines) > 0 && lines[0].eol == "\r\n" {
+		eol = "\r\n"
+	}
+	var end []string // Lines appended to the file
+	for k, kind := range patchKinds {
+		want := wantedLines(rs, kind)
+		if old != nil && equalLines(wantedLines(old, kind), want) {
+			continue
+		}
+		var have []int
+		for i, l := range lines {
+			if l.kind == kind {
+				have = append(have, i)
+			}
+		}
+		if len(have) == 0 {
+			if len(want) > 0 {
+				placeSection(lines, &end, patchKinds[:k], patchKinds[k+1:], want)
+			}
+			continue
+		}
+		patchKind(lines, have, want, kind != kindNameserver)
+	}
+
+	var b strings.Builder
+	write := func(text string) {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString(eol)
+		}
+		b.WriteString(text)
+	}
+	for _, c := range o.comments {
+		write("# " + c + eol)
+	}
+	for _, l := range lines {
+		for _, text := range l.before {
+			write(text + eol)
+		}
+		if !l.deleted {
+			write(l.text)
+		}
+		for _, text := range l.after {
+			write(text + eol)
+		}
+	}
+	for _, text := range end {
+		write(text + eol)
+	}
+	return []byte(b.String())
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPatchLines splits the file into lines and parses those Patch edits
+func splitPatchLines(s string, rs *renderSet) []*patchLine {
+	var lines []*patchLine
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n') + 1
+		if n == 0 {
+			n = len(s)
+		}
+		l := &patchLine{text: s[:n]}
+		s = s[n:]
+		line := strings.TrimSuffix(l.text, "\n")
+		if len(line) < len(l.text) {
+			l.eol = "\n"
+			if strings.HasSuffix(line, "\r") {
+				line, l.eol = line[:len(line)-1], "\r\n"
+			}
+		}
+		if len(lines) == 0 {
+			trimmed := strings.TrimLeft(strings.TrimPrefix(line, byteOrderMark), " \t")
+			l.lead, line = line[:len(line)-len(trimmed)], trimmed
+		}
+		lines = append(lines, l)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		items, _, err := parseLine(nil, line, true)
+		if err != nil || len(items) == 0 {
+			continue
+		}
+		if c, ok := items[len(items)-1].(*Comment); ok && c.Trailing {
+			l.comment = c.Text
+			items = items[:len(items)-1]
+		}
+		if len(items) == 0 {
+			continue
+		}
+		kind := keyOf(items[0]).kind
+		for _, k := range patchKinds {
+			if k == kind {
+				l.kind, l.canon = kind, canonLine(rs, items)
+			}
+		}
+	}
+	return lines
+}
+
+// canonLine returns the line Write would write for the items of a line
+func canonLine(rs *renderSet, items []ConfItem) string {
+	fields := make([]string, len(items))
+	for i, item := range items {
+		if si, ok := item.(*SortItem); ok {
+			fields[i] = rs.sortPair(*si)
+		} else {
+			fields[i] = item.String()
+		}
+	}
+	keyword := strings.SplitN(itemLine(items[0]), " ", 2)[0]
+	return keyword + " " + strings.Join(fields, " ")
+}
+
+// wantedLines returns the lines of kind Write would write
+func wantedLines(rs *renderSet, kind itemKind) []string {
+	switch kind {
+	case kindNameserver:
+		lines := make([]string, len(rs.nameservers))
+		for i := range rs.nameservers {
+			lines[i] = itemLine(&rs.nameservers[i])
+		}
+		return lines
+	case kindDomain:
+		if rs.domain.Name != "" {
+			return []string{itemLine(&rs.domain)}
+		}
+	case kindSearchDomain:
+		if len(rs.searchDomains) > 0 {
+			return rs.SearchLines()
+		}
+	case kindSortItem:
+		if len(rs.sortItems) > 0 {
+			return rs.SortlistLines()
+		}
+	case kindOption:
+		if len(rs.options) > 0 {
+			return rs.OptionLines()
+		}
+	case kindLookup:
+		if len(rs.lookup.Sources) > 0 {
+			return []string{itemLine(&rs.lookup)}
+		}
+	case kindFamily:
+		if len(rs.family.Families) > 0 {
+			return []string{itemLine(&rs.family)}
+		}
+	}
+	return nil
+}
+
+// patchKind edits the lines at have to become want. The longest common
+// sequence of lines is kept, between its lines the others are rewritten
+// in order if rewrite, deleted if there are more of them or followed by new
+// lines if fewer. Nameservers are not rewritten, the comment of the line
+// would be about another server
+func patchKind(lines []*patchLine, have []int, want []string, rewrite bool) {
+	// lcs[i][j] is the length of the common sequence of have[i:] and want[j:]
+	lcs := make([][]int, len(have)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(want)+1)
+	}
+	for i := len(have) - 1; i >= 0; i-- {
+		for j := len(want) - 1; j >= 0; j-- {
+			switch {
+			case lines[have[i]].canon == want[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(have) || j < len(want) {
+		// Collect the gap up to the next common line
+		var gapHave []int
+		var gapWant []string
+		for i < len(have) || j < len(want) {
+			if i < len(have) && j < len(want) && lines[have[i]].canon == want[j] && lcs[i][j] == lcs[i+1][j+1]+1 {
+				break
+			}
+			if j == len(want) || i < len(have) && lcs[i+1][j] >= lcs[i][j+1] {
+				gapHave = append(gapHave, have[i])
+				i++
+			} else {
+				gapWant = append(gapWant, want[j])
+				j++
+			}
+		}
+		rewritten := 0
+		if rewrite {
+			rewritten = len(gapHave)
+			if len(gapWant) < rewritten {
+				rewritten = len(gapWant)
+			}
+		}
+		for n, pos := range gapHave {
+			l := lines[pos]
+			if n < rewritten {
+				text := gapWant[n]
+				if l.comment != "" {
+					text += " " + l.comment
+				}
+				l.text = l.lead + text + l.eol
+				if l.eol == "" {
+					l.text += "\n"
+				}
+			} else {
+				l.deleted = true
+			}
+		}
+		if len(gapWant) > rewritten {
+			rest := gapWant[rewritten:]
+			switch {
+			case len(gapHave) > 0:
+				last := lines[gapHave[len(gapHave)-1]]
+				last.after = append(last.after, rest...)
+			case i > 0:
+				prev := lines[have[i-1]]
+				prev.after = append(prev.after, rest...)
+			default:
+				next := lines[have[i]]
+				next.before = append(next.before, rest...)
+			}
+		}
+		if i < len(have) && j < len(want) {
+			// The common line
+			i++
+			j++
+		}
+	}
+}
+
+// placeSection adds the lines of a kind the file has none of after the
+// lines of the closest kind before it, else before those of the closest
+// kind after it, else at the end
+func placeSection(lines []*patchLine, end *[]string, before, after []itemKind, want []string) {
+	for k := len(before) - 1; k >= 0; k-- {
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i].kind == before[k] {
+				lines[i].after = append(lines[i].after, want...)
+				return
+			}
+		}
+	}
+	for _, kind := range after {
+		for _, l := range lines {
+			if l.kind == kind {
+				l.before = append(l.before, want...)
+				return
+			}
+		}
+	}
+	*end = append(*end, want...)
+}
+
+// fileContent returns the content writeFile writes, prev is the content of
+// the file if it exists
+func (conf *Conf) fileContent(prev []byte, exists bool, o *writeOptions) ([]byte, error) {
+	if o.patch && exists {
+		return conf.patch(prev, o), nil
+	}
+	var buf bytes.Buffer
+	if _, err := conf.writeTo(&buf, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+const adminConf = `# Managed by hand, see the runbook
+; primary DC first
+nameserver 10.0.0.1   # dc1
+nameserver 10.0.0.2	# dc2
+
+search corp.example.com example.com
+
+# tuned for the VPN
+options ndots:2 timeout:3 # was ndots:5
+`
+
+func patchFS(t *testing.T, content string) (*resolvconf.MemFS, *resolvconf.Conf) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte(content), 0644))
+	conf, err := resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.Lenient())
+	assert.Nil(t, err)
+	return fsys, conf
+}
+
+func TestPatch(t *testing.T) {
+	fsys, conf := patchFS(t, adminConf)
+	conf.Remove(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.3")), resolvconf.NewDomain("corp.example.com"),
+		resolvconf.NewOption("ndots").Set(1))
+	want := `# Managed by hand, see the runbook
+; primary DC first
+nameserver 10.0.0.1   # dc1
+nameserver 10.0.0.3
+domain corp.example.com
+
+search corp.example.com example.com
+
+# tuned for the VPN
+options ndots:1 timeout:3 # was ndots:5
+`
+	// Nothing is written by a dry run
+	var content []byte
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.DryRun(&content)))
+	assert.Equal(t, want, string(content))
+	b, _ := fsys.ReadFile("/etc/resolv.conf")
+	assert.Equal(t, adminConf, string(b))
+
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys)))
+	b, _ = fsys.ReadFile("/etc/resolv.conf")
+	assert.Equal(t, want, string(b))
+
+	// Removing all of a kind deletes its lines
+	conf.RemoveFunc(func(item resolvconf.ConfItem) bool {
+		_, ok := item.(*resolvconf.SearchDomain)
+		return ok
+	})
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.DryRun(&content)))
+	assert.NotContains(t, string(content), "search")
+	assert.Contains(t, string(content), "domain corp.example.com\n\n\n# tuned")
+}
+
+func TestPatchUnchangedKeepsBytes(t *testing.T) {
+	for _, in := range []string{
+		adminConf,
+		"nameserver\t10.0.0.1\r\nsearch  a.com\tb.com\r\noptions ndots:2\r\noptions rotate\r\n",
+		"\ufeffnameserver 10.0.0.1\nsearch a.com\nsearch b.com c.com",
+		"unknown line\nnameserver 10.0.0.1 # a\n#\n",
+	} {
+		fsys, conf := patchFS(t, in)
+		var content []byte
+		assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.DryRun(&content)))
+		assert.Equal(t, in, string(content))
+	}
+}
+
+func TestPatchSections(t *testing.T) {
+	// New lines go with their kind, or where Write puts them
+	fsys, conf := patchFS(t, "# header\r\nsearch a.com\r\n")
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("b.com"), resolvconf.NewOption("rotate"))
+	var content []byte
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.DryRun(&content)))
+	assert.Equal(t, "# header\r\nnameserver 10.0.0.1\r\nsearch a.com b.com\r\noptions rotate\r\n", string(content))
+
+	// Only the last search line counts, a changed search list replaces all
+	fsys, conf = patchFS(t, "search a.com\nsearch b.com\nnameserver 10.0.0.1")
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.DryRun(&content)))
+	assert.Equal(t, "search a.com\nsearch b.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n", string(content))
+	conf.Add(resolvconf.NewSearchDomain("c.com"))
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.DryRun(&content)))
+	assert.Equal(t, "search b.com c.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n", string(content))
+}
+
+func TestPatchMissingFileWritesAll(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/etc/hosts", nil, 0644))
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com"))
+	assert.Nil(t, conf.Patch("/etc/resolv.conf", resolvconf.WithFileSystem(fsys)))
+	b, err := fsys.ReadFile("/etc/resolv.conf")
+	assert.Nil(t, err)
+	assert.Equal(t, conf.String(), string(b))
+}
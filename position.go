@@ -0,0 +1,86 @@
+package resolvconf
+
+import "strings"
+
+// itemPos is the line an item was parsed from
+type itemPos struct {
+	line int
+	raw  string
+}
+
+// Position returns the number of the line item was parsed from, counted
+// from 1, and the line as read without its line ending. item is the one
+// held by the Conf, e.g. returned by Find, or an item equal to it such as
+// one named by a ParseWarning. ok is false for items added or given a new
+// value by Add, updated by Normalize or removed. Positions are kept by
+// Clone and by reordering. Changes made to items returned by Find are not
+// seen, the position stays the one of the line the item was read from
+func (conf *Conf) Position(item ConfItem) (line int, raw string, ok bool) {
+	if item == nil {
+		return 0, "", false
+	}
+	// The index may be rebuilt by the lookup
+	conf.lock()
+	defer conf.unlock()
+	if len(conf.pos) == 0 {
+		return 0, "", false
+	}
+	var p itemPos
+	if isPointer(item) {
+		p, ok = conf.pos[item]
+	}
+	if !ok {
+		if held := conf.lookup(item); isPointer(held) {
+			p, ok = conf.pos[held]
+		}
+	}
+	return p.line, p.raw, ok
+}
+
+// parsing sets the line being parsed, items added or updated until the
+// next call get its position. line 0 ends parsing
+func (conf *Conf) parsing(line int, text string) {
+	conf.warnings.line = line
+	conf.reading = itemPos{line, strings.TrimSuffix(text, "\r")}
+}
+
+// place records the position of an item added or updated, it is cleared
+// unless the item is being parsed
+func (conf *Conf) place(item ConfItem) {
+	if !isPointer(item) {
+		return
+	}
+	if conf.reading.line == 0 {
+		conf.unplace(item)
+		return
+	}
+	if conf.pos == nil {
+		conf.pos = make(map[ConfItem]itemPos)
+	}
+	conf.pos[item] = conf.reading
+}
+
+// unplace forgets the position of an item
+func (conf *Conf) unplace(item ConfItem) {
+	if len(conf.pos) > 0 && isPointer(item) {
+		delete(conf.pos, item)
+	}
+}
+
+// prunePositions forgets the positions of items no longer held
+func (conf *Conf) prunePositions() {
+	if len(conf.pos) == 0 {
+		return
+	}
+	held := make(map[ConfItem]bool, len(conf.items))
+	for _, item := range conf.items {
+		if isPointer(item) {
+			held[item] = true
+		}
+	}
+	for item := range conf.pos {
+		if !held[item] {
+			delete(conf.pos, item)
+		}
+	}
+}
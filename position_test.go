@@ -0,0 +1,85 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPosition(t *testing.T) {
+	in := "# resolv.conf\r\nnameserver 10.0.0.1\r\n\r\nsearch a.com b.com # corp\r\noptions ndots:2\r\noptions ndots:3\r\nnameserver 10.0.0.1\r\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in), resolvconf.Lenient())
+	assert.Nil(t, err)
+
+	ns := conf.Find(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	line, raw, ok := conf.Position(ns)
+	assert.True(t, ok)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, "nameserver 10.0.0.1", raw)
+
+	// An equal item finds the one held, e.g. to name the original of a duplicate
+	warnings := conf.ParseWarnings()
+	assert.Equal(t, 1, len(warnings))
+	assert.Equal(t, 7, warnings[0].Line)
+	line, _, ok = conf.Position(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	assert.True(t, ok)
+	assert.Equal(t, 2, line)
+
+	line, raw, ok = conf.Position(resolvconf.NewSearchDomain("b.com"))
+	assert.True(t, ok)
+	assert.Equal(t, 4, line)
+	assert.Equal(t, "search a.com b.com # corp", raw)
+
+	// The value of the later line wins and so does its position
+	line, _, ok = conf.Position(resolvconf.NewOption("ndots"))
+	assert.True(t, ok)
+	assert.Equal(t, 6, line)
+
+	c := conf.Clone()
+	line, _, ok = c.Position(c.Find(resolvconf.NewSearchDomain("a.com")))
+	assert.True(t, ok)
+	assert.Equal(t, 4, line)
+}
+
+func TestPositionAfterChanges(t *testing.T) {
+	in := "nameserver 10.0.0.1\nnameserver 10.0.0.2\noptions ndots:2 rotate\ndomain a.com\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Nil(t, err)
+
+	// Added items, and those given a new value, have no position
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.3")), resolvconf.NewOption("ndots").Set(4), resolvconf.NewDomain("b.com"))
+	for _, item := range []resolvconf.ConfItem{
+		resolvconf.NewNameserver(net.ParseIP("10.0.0.3")),
+		resolvconf.NewOption("ndots"),
+		resolvconf.NewDomain("b.com"),
+	} {
+		_, _, ok := conf.Position(item)
+		assert.False(t, ok, item.String())
+	}
+	line, _, ok := conf.Position(resolvconf.NewOption("rotate"))
+	assert.True(t, ok)
+	assert.Equal(t, 3, line)
+
+	// Removed items lose theirs, also when added again
+	ns := conf.Find(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	assert.Nil(t, conf.Remove(ns))
+	_, _, ok = conf.Position(ns)
+	assert.False(t, ok)
+	conf.Add(ns)
+	_, _, ok = conf.Position(ns)
+	assert.False(t, ok)
+
+	// Moved items keep theirs
+	assert.Nil(t, conf.PromoteNameserver(net.ParseIP("10.0.0.2")))
+	line, _, ok = conf.Position(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	assert.True(t, ok)
+	assert.Equal(t, 2, line)
+
+	conf.Clear()
+	_, _, ok = conf.Position(resolvconf.NewOption("rotate"))
+	assert.False(t, ok)
+	_, _, ok = resolvconf.New().Position(resolvconf.NewOption("rotate"))
+	assert.False(t, ok)
+}
@@ -1,90 +1,391 @@
 // Package resolvconf provides an interface to read, create and manipulate
 // resolv.conf files
+//
+// Functions that may block on the network or on IPC take a context.Context
+// as their first parameter. They return as soon as it is done, with an
+// error wrapping ctx.Err() that can be tested with errors.Is
 package resolvconf
 
 import (
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"io"
-	"log"
+	"net"
 	"reflect"
-	"strings"
 )
 
 // Add items to the configuration.
 //
 // Errors are accumulated and can be reinterpreted as
-// an multierror type. Logging will occur if logging has
-// been setup using the EnableLogging call
+// an multierror type, use errors.Is to test for ErrDuplicateItem,
+// ErrLimitExceeded and the other sentinels. Logging will occur if
+// logging has been setup using the EnableLogging call.
+//
+// Items are stored as given, to modify an item once added use the
+// pointer returned by Find. Valid items are added even if others are
+// rejected, use AddStrict to add all or none
 func (conf *Conf) Add(opts ...ConfItem) error {
+	conf.lock()
+	defer conf.unlock()
+	return conf.addItems(opts)
+}
+
+// addItems is Add without locking
+func (conf *Conf) addItems(opts []ConfItem) error {
 	var err *multierror.Error
 	for _, o := range opts {
 		if o == nil {
-			err = multierror.Append(err, fmt.Errorf("Trying to add nil element"))
+			err = multierror.Append(err, &ItemError{nil, "add", fmt.Errorf("%w: trying to add nil element", ErrInvalidValue)})
 			continue
 		}
-		if ok, e := o.applyLimits(conf); e != nil {
-			err = multierror.Append(err, e)
+		if ok, e := conf.add(o); e != nil {
+			if conf.logger.enabled(LogWarn) {
+				conf.logWarn(fmt.Sprintf("Rejected %s %s: %s", typeName(o), o, e), "op", "add", "kind", typeName(o), "item", o.String(), "error", e)
+			}
+			err = multierror.Append(err, &ItemError{o, "add", e})
 		} else if ok {
-			typeName := reflect.TypeOf(o).Elem().Name()
-			conf.logger.Printf("Added %s %s", strings.ToLower(typeName), o)
-			conf.items = append(conf.items, o)
+			if conf.logger.enabled(LogDebug) {
+				kind := typeName(o)
+				conf.logger.l.Debug(fmt.Sprintf("Added %s %s", kind, o), "op", "add", "kind", kind, "item", o.String())
+			}
+		} else if conf.logger.enabled(LogDebug) {
+			kind := typeName(o)
+			conf.logger.l.Debug(fmt.Sprintf("Updated existing %s with %s", kind, o), "op", "add", "kind", kind, "item", o.String())
 		}
 	}
 	return err.ErrorOrNil()
 }
 
+// addItemsTo is addItems sending the warnings of the items to sink rather
+// than to the Conf, the Conf must be locked
+func (conf *Conf) addItemsTo(opts []ConfItem, sink *[]Warning) error {
+	wl := conf.warnings
+	if wl == nil {
+		return conf.addItems(opts)
+	}
+	wl.mu.Lock()
+	wl.to = sink
+	wl.mu.Unlock()
+	defer func() {
+		wl.mu.Lock()
+		wl.to = nil
+		wl.mu.Unlock()
+	}()
+	return conf.addItems(opts)
+}
+
+// add adds a single item without logging, returns true if it was appended
+// rather than merged into an existing item
+func (conf *Conf) add(o ConfItem) (bool, error) {
+	// Existing items may be updated even if o is not appended
+	conf.cache.reset()
+	ok, err := o.applyLimits(conf)
+	if ok && err == nil {
+		conf.appendItem(o)
+	}
+	return ok, err
+}
+
+// AddStrict adds the items only if all of them can be added, otherwise
+// the configuration is left as is. The error is the one Add would return
+func (conf *Conf) AddStrict(opts ...ConfItem) error {
+	conf.lock()
+	defer conf.unlock()
+	if err := conf.trial(opts, (*Conf).addItems); err != nil {
+		return err
+	}
+	return conf.addItems(opts)
+}
+
 // Remove items from the configuration
 //
 // Errors are accumulated and can be reinterpreted as an multierror type.
 // Logging will occur if logging has been setup using the EnableLogging
-// call
+// call. Use RemoveStrict to remove all or none
 func (conf *Conf) Remove(opts ...ConfItem) error {
+	conf.lock()
+	defer conf.unlock()
+	return conf.removeItems(opts)
+}
+
+// removeItems is Remove without locking
+func (conf *Conf) removeItems(opts []ConfItem) error {
 	var err *multierror.Error
 	for _, o := range opts {
 		if o == nil {
-			err = multierror.Append(err, fmt.Errorf("Trying to remove nil element"))
+			err = multierror.Append(err, &ItemError{nil, "remove", fmt.Errorf("%w: trying to remove nil element", ErrInvalidValue)})
 			continue
 		}
 		i := conf.indexOf(o)
 		//_, isdom := o.(Domain)
 		if i == -1 {
-			err = multierror.Append(err, fmt.Errorf("Not found"))
+			err = multierror.Append(err, &ItemError{o, "remove", fmt.Errorf("%w: %s", ErrNotFound, o)})
 			continue
 		}
-		typeName := reflect.TypeOf(conf.items[i]).Elem().Name()
-		conf.logger.Printf("Removed %s %s", strings.ToLower(typeName), conf.items[i])
-		conf.items = append(conf.items[:i], conf.items[i+1:]...)
+		if conf.logger.enabled(LogDebug) {
+			item := conf.items[i]
+			kind := typeName(item)
+			conf.logger.l.Debug(fmt.Sprintf("Removed %s %s", kind, item), "op", "remove", "kind", kind, "item", item.String())
+		}
+		conf.removeItem(i)
+		// The comment at the end of its line goes with it
+		if i < len(conf.items) {
+			if c, ok := conf.items[i].(*Comment); ok && c.Trailing {
+				conf.removeItem(i)
+			}
+		}
 	}
 	return err.ErrorOrNil()
 }
 
+// RemoveStrict removes the items only if all of them are present, otherwise
+// the configuration is left as is. The error is the one Remove would return
+func (conf *Conf) RemoveStrict(opts ...ConfItem) error {
+	conf.lock()
+	defer conf.unlock()
+	if err := conf.trial(opts, (*Conf).removeItems); err != nil {
+		return err
+	}
+	return conf.removeItems(opts)
+}
+
+// removeAll removes all items of kind and returns how many, name is the
+// kind for logging
+func (conf *Conf) removeAll(kind itemKind, name string) int {
+	n := conf.removeIf(func(item ConfItem) bool {
+		return keyOf(item).kind == kind
+	})
+	if n > 0 && conf.logger.enabled(LogDebug) {
+		conf.logger.l.Debug(fmt.Sprintf("Removed %d %s items", n, name), "op", "remove", "kind", name, "count", n)
+	}
+	return n
+}
+
+// RemoveNameservers removes all nameservers and returns how many there
+// were, the comments at the end of their lines go with them
+func (conf *Conf) RemoveNameservers() int {
+	return conf.removeAll(kindNameserver, "nameserver")
+}
+
+// RemoveSearchDomains removes all search domains and returns how many
+// there were
+func (conf *Conf) RemoveSearchDomains() int {
+	return conf.removeAll(kindSearchDomain, "searchdomain")
+}
+
+// RemoveOptions removes all options and returns how many there were
+func (conf *Conf) RemoveOptions() int {
+	return conf.removeAll(kindOption, "option")
+}
+
+// RemoveSortItems removes all sortlist pairs and returns how many there
+// were
+func (conf *Conf) RemoveSortItems() int {
+	return conf.removeAll(kindSortItem, "sortitem")
+}
+
+// ReplaceNameservers replaces all nameservers with ns. The new set is
+// checked first, if any of them can't be added, e.g. because of the limit
+// or a duplicate, the error is the one Add would return and the
+// configuration is left as is. The new nameservers take the place of the
+// first old one among the other items
+func (conf *Conf) ReplaceNameservers(ns ...Nameserver) error {
+	items := make([]ConfItem, len(ns))
+	for i := range ns {
+		items[i] = &ns[i]
+	}
+	return conf.replaceAll(kindNameserver, items)
+}
+
+// ReplaceSearchDomains replaces the search list with sds, like
+// ReplaceNameservers nothing is changed if any of them can't be added
+func (conf *Conf) ReplaceSearchDomains(sds ...SearchDomain) error {
+	items := make([]ConfItem, len(sds))
+	for i := range sds {
+		items[i] = &sds[i]
+	}
+	return conf.replaceAll(kindSearchDomain, items)
+}
+
+// replaceAll replaces the items of kind with items if all of them can be
+// added
+func (conf *Conf) replaceAll(kind itemKind, items []ConfItem) error {
+	conf.lock()
+	defer conf.unlock()
+	ofKind := func(item ConfItem) bool {
+		return keyOf(item).kind == kind
+	}
+	err := conf.trial(items, func(c *Conf, items []ConfItem) error {
+		c.dropIf(ofKind)
+		return c.addItems(items)
+	})
+	if err != nil {
+		return err
+	}
+	pos := -1
+	for i, item := range conf.items {
+		if ofKind(item) {
+			pos = i
+			break
+		}
+	}
+	conf.dropIf(ofKind)
+	n := len(conf.items)
+	if err := conf.addItems(items); err != nil {
+		return err
+	}
+	if pos >= 0 && pos < n {
+		// Move the added items to where the old ones started
+		moved := make([]ConfItem, 0, len(conf.items))
+		moved = append(moved, conf.items[:pos]...)
+		moved = append(moved, conf.items[n:]...)
+		moved = append(moved, conf.items[pos:n]...)
+		conf.setItems(moved)
+	}
+	return nil
+}
+
+// RemoveFunc removes the items for which remove returns true and returns
+// how many, it is called once for every item in order. The comment at the
+// end of the line of a removed item goes with it. remove must not call
+// methods of conf, it is called with the configuration locked
+func (conf *Conf) RemoveFunc(remove func(ConfItem) bool) int {
+	n := conf.removeIf(remove)
+	if n > 0 && conf.logger.enabled(LogDebug) {
+		conf.logger.l.Debug(fmt.Sprintf("Removed %d items", n), "op", "remove", "count", n)
+	}
+	return n
+}
+
+// Clear removes all items, comments and raw lines included, and returns
+// how many there were. The settings of the Conf are kept
+func (conf *Conf) Clear() int {
+	conf.lock()
+	n := len(conf.items)
+	for _, item := range conf.items {
+		conf.changed(OpRemove, item)
+	}
+	conf.setItems(nil)
+	conf.unlock()
+	if n > 0 && conf.logger.enabled(LogDebug) {
+		conf.logger.l.Debug(fmt.Sprintf("Removed all %d items", n), "op", "remove", "count", n)
+	}
+	return n
+}
+
+// trial runs op on a silent copy of the configuration and of the items,
+// the items of the returned ItemErrors are mapped back to opts
+func (conf *Conf) trial(opts []ConfItem, op func(*Conf, []ConfItem) error) error {
+	c := conf.clone()
+	c.logger, c.warnings = nil, nil
+	items := make([]ConfItem, len(opts))
+	orig := make(map[ConfItem]ConfItem, len(opts))
+	for i, o := range opts {
+		items[i] = o
+		if isPointer(o) {
+			items[i] = cloneItem(o)
+			orig[items[i]] = o
+		}
+	}
+	err := op(c, items)
+	for _, ie := range ItemErrors(err) {
+		if isPointer(ie.Item) {
+			if o, ok := orig[ie.Item]; ok {
+				ie.Item = o
+			}
+		}
+	}
+	return err
+}
+
+// isPointer tells if item is held by pointer, items given by value are
+// copied anyway and may not be hashable
+func isPointer(item ConfItem) bool {
+	return item != nil && reflect.TypeOf(item).Kind() == reflect.Ptr
+}
+
 // EnableLogging enables internal logging with given writer as output, currently only one
 // writer is supported. conf will use LstdFlags for the logging
 func (conf *Conf) EnableLogging(writer io.Writer) error {
 	if conf.logger == nil {
 		return fmt.Errorf("Logging has not been setup properly")
 	}
-	conf.logger.SetFlags(log.LstdFlags)
-	conf.logger.SetOutput(writer)
+	conf.logger.setOutput(writer)
 	return nil
 }
 
+// SetLogger sends the diagnostics to l, or disables logging if l is nil.
+// Use it for a Conf not created with WithLogger, e.g. one from ReadConf
+func (conf *Conf) SetLogger(l Logger) {
+	if conf.logger == nil {
+		conf.logger = newConfLogger()
+	}
+	conf.logger.set(l)
+}
+
 // Find an configure item returns nil if item is not found. Returned will be
 // a pointer to the actual item that can be converted into expected type
-func (conf Conf) Find(o ConfItem) ConfItem {
-	i := conf.indexOf(o)
-	if i == -1 {
-		return nil
+func (conf *Conf) Find(o ConfItem) ConfItem {
+	conf.lock()
+	defer conf.unlock()
+	item := conf.lookup(o)
+	if item != nil {
+		// The caller may modify it
+		conf.lend(item)
 	}
-	return conf.items[i]
+	return item
 }
 
-func (conf Conf) indexOf(o ConfItem) int {
-	for i, item := range conf.items {
-		if o.Equal(item) {
-			return i
+// findFirst returns the first item for which match returns true, like
+// Find the caller may modify it
+func (conf *Conf) findFirst(match func(ConfItem) bool) ConfItem {
+	conf.lock()
+	defer conf.unlock()
+	for _, item := range conf.items {
+		if match(item) {
+			conf.lend(item)
+			return item
 		}
 	}
-	return -1
+	return nil
+}
+
+// FindNameserver returns the first nameserver with address ip, whatever
+// its port and zone. Addresses are compared with net.IP.Equal so the 4 and
+// 16 byte forms of an IPv4 address match. Like Find it returns a pointer
+// to the item in the configuration
+func (conf *Conf) FindNameserver(ip net.IP) (*Nameserver, bool) {
+	ns, ok := conf.findFirst(func(item ConfItem) bool {
+		ns, ok := item.(*Nameserver)
+		return ok && ns.IP.Equal(ip)
+	}).(*Nameserver)
+	return ns, ok
+}
+
+// FindSearchDomain returns the search domain name, like Find it returns a
+// pointer to the item in the configuration
+func (conf *Conf) FindSearchDomain(name string) (*SearchDomain, bool) {
+	sd, ok := conf.findFirst(func(item ConfItem) bool {
+		sd, ok := item.(*SearchDomain)
+		return ok && sd.Name == name
+	}).(*SearchDomain)
+	return sd, ok
+}
+
+// FindSortItem returns the sortlist pair with address addr, whatever its
+// netmask. Like Find it returns a pointer to the item in the configuration
+func (conf *Conf) FindSortItem(addr net.IP) (*SortItem, bool) {
+	si, ok := conf.findFirst(func(item ConfItem) bool {
+		si, ok := item.(*SortItem)
+		return ok && si.Address.Equal(addr)
+	}).(*SortItem)
+	return si, ok
+}
+
+func (conf *Conf) indexOf(o ConfItem) int {
+	item := conf.lookup(o)
+	if item == nil {
+		return -1
+	}
+	return conf.position(item)
 }
@@ -3,6 +3,7 @@ package resolvconf_test
 import (
 	"."
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -51,6 +53,61 @@ func TestIPv6Nameserver(t *testing.T) {
 	assert.Equal(t, net.ParseIP("2001:0db8:0000:0000:0000:0000:1428:07ab"), conf.Find(ns).(*resolvconf.Nameserver).IP)
 }
 
+func TestNewNameserverFromString(t *testing.T) {
+	ns, err := resolvconf.NewNameserverFromString(" 8.8.8.8\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "8.8.8.8", ns.String())
+
+	ns, err = resolvconf.NewNameserverFromString("fe80::1%eth0")
+	assert.Nil(t, err)
+	assert.Equal(t, "eth0", ns.Zone)
+	assert.Equal(t, "fe80::1%eth0", ns.String())
+	assert.Equal(t, "[fe80::1%eth0]:53", ns.Addr())
+
+	ns, err = resolvconf.NewNameserverFromString("[2001:db8::1]:5353")
+	assert.Nil(t, err)
+	assert.Equal(t, 5353, ns.Port)
+
+	for _, s := range []string{"", "8.8.8", "dns.google", "10.0.0.1%eth0", "fe80::1%", "0.0.0.0", "::"} {
+		ns, err := resolvconf.NewNameserverFromString(s)
+		assert.Nil(t, ns)
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), "%q %v", s, err)
+	}
+}
+
+func TestAddNameservers(t *testing.T) {
+	conf := resolvconf.New()
+	err := conf.AddNameservers("10.0.0.1", "bad", " 10.0.0.2 ", "10.0.0.1", "10.0.0.3", "10.0.0.4")
+	assert.Equal(t, 3, len(conf.GetNameservers()))
+	ies := resolvconf.ItemErrors(err)
+	assert.Equal(t, 3, len(ies))
+	assert.True(t, errors.Is(ies[0], resolvconf.ErrInvalidValue))
+	assert.Contains(t, ies[0].Error(), "bad")
+	assert.True(t, errors.Is(ies[1], resolvconf.ErrDuplicateItem))
+	assert.True(t, errors.Is(ies[2], resolvconf.ErrTooManyNameservers))
+	assert.Contains(t, ies[2].Error(), "10.0.0.4")
+	assert.Nil(t, conf.AddNameservers())
+}
+
+func TestAddSearchDomains(t *testing.T) {
+	conf := resolvconf.New()
+	err := conf.AddSearchDomains("example.com", " lab.example.com\t", "foo bar", "example.com")
+	assert.Equal(t, []resolvconf.SearchDomain{{Name: "example.com"}, {Name: "lab.example.com"}}, conf.GetSearchDomains())
+	ies := resolvconf.ItemErrors(err)
+	assert.Equal(t, 2, len(ies))
+	assert.True(t, errors.Is(ies[0], resolvconf.ErrInvalidValue))
+	assert.True(t, errors.Is(ies[1], resolvconf.ErrDuplicateItem))
+}
+
+func TestAddUnsetNameserverFails(t *testing.T) {
+	conf := resolvconf.New()
+	for _, ns := range []*resolvconf.Nameserver{{}, resolvconf.NewNameserver(net.ParseIP("foo")), resolvconf.NewNameserver(net.IPv4zero)} {
+		err := conf.Add(ns)
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), "%v", err)
+	}
+	assert.Equal(t, 0, len(conf.GetNameservers()))
+}
+
 func TestAddSecondDomainReplacesFirst(t *testing.T) {
 	conf := resolvconf.New()
 	conf.Add(resolvconf.NewDomain("foo.com"), resolvconf.NewDomain("bar.com"))
@@ -66,6 +123,36 @@ func TestRemoveDomain(t *testing.T) {
 	assert.Equal(t, "", conf.GetDomain().Name)
 }
 
+func TestDomainNameValidation(t *testing.T) {
+	label := strings.Repeat("a", 63)
+	for _, name := range []string{"example.com", "example.com.", "_ldap._tcp.example.com", "xn--bcher-kva.example", "a-b.c0m",
+		label + "." + label + "." + label + "." + strings.Repeat("b", 61)} {
+		dom, err := resolvconf.NewDomainStrict(name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, name, dom.Name)
+		_, err = resolvconf.NewSearchDomainStrict(name)
+		assert.Nil(t, err, name)
+	}
+	for _, name := range []string{"", ".", "foo bar.com", "-foo.com", "foo-.com", "foo..com", label + "a.com",
+		label + "." + label + "." + label + "." + strings.Repeat("b", 62)} {
+		dom, err := resolvconf.NewDomainStrict(name)
+		assert.Nil(t, dom)
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), "%q %v", name, err)
+		_, err = resolvconf.NewSearchDomainStrict(name)
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), "%q %v", name, err)
+	}
+
+	_, err := resolvconf.NewDomainStrict("bücher.example")
+	assert.Contains(t, err.Error(), "punycode")
+
+	// Hand built items are checked by Add
+	conf := resolvconf.New()
+	err = conf.Add(&resolvconf.Domain{Name: "foo bar.com"}, &resolvconf.SearchDomain{Name: "-foo.com"})
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	assert.Equal(t, "", conf.GetDomain().Name)
+	assert.Equal(t, 0, len(conf.GetSearchDomains()))
+}
+
 func TestBasicSearchDomain(t *testing.T) {
 	conf := resolvconf.New()
 	dom := resolvconf.NewSearchDomain("foo.com")
@@ -160,9 +247,9 @@ func TestBasicNewOption(t *testing.T) {
 	o := conf.Find(opt)
 	assert.NotNil(t, o)
 
-	// Test to set option again should yiled error
+	// Setting option again is a no-op
 	err = conf.Add(opt)
-	assert.NotNil(t, err)
+	assert.Nil(t, err)
 	assert.Equal(t, 1, len(conf.GetOptions()))
 
 	// Test to remove option
@@ -191,6 +278,30 @@ func TestOptionWithValue(t *testing.T) {
 	assert.Equal(t, 1, len(conf.GetOptions()))
 }
 
+func TestNewOptionWithValue(t *testing.T) {
+	opt, err := resolvconf.NewOptionWithValue("ndots", 0)
+	assert.Nil(t, err)
+	conf := resolvconf.New()
+	assert.Nil(t, conf.Add(opt))
+	assert.Equal(t, "options ndots:0\n\n", conf.String())
+	assert.Equal(t, 5, resolvconf.NewOption("ndots").Set(5).Get())
+
+	for _, tc := range []struct {
+		t    string
+		v    int
+		want error
+	}{
+		{"foo", 1, resolvconf.ErrUnknownOption},
+		{"debug", 1, resolvconf.ErrInvalidValue},
+		{"ndots", -1, resolvconf.ErrInvalidValue},
+		{"timeout", 31, resolvconf.ErrInvalidValue},
+	} {
+		opt, err := resolvconf.NewOptionWithValue(tc.t, tc.v)
+		assert.Nil(t, opt)
+		assert.True(t, errors.Is(err, tc.want), "%s:%d %v", tc.t, tc.v, err)
+	}
+}
+
 func TestAddMultipleItems(t *testing.T) {
 	conf := resolvconf.New()
 	opt := resolvconf.NewOption("ndots").Set(4)
@@ -223,6 +334,81 @@ func TestAddBadOptionInList(t *testing.T) {
 	assert.NotNil(t, conf.Find(resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))))
 }
 
+func TestAddUnknownOptionTypeFails(t *testing.T) {
+	conf := resolvconf.New()
+	for _, opt := range []*resolvconf.Option{{Type: "foo", Value: -1}, {Type: "foo", Value: 3}, {Type: "debug:3", Value: -1}, {Type: "", Value: -1}} {
+		err := conf.Add(opt)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Unknown option")
+	}
+	assert.Equal(t, 0, len(conf.GetOptions()))
+
+	// Negative values are rejected with the option named
+	err := conf.Add(&resolvconf.Option{Type: "ndots", Value: -2})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "ndots")
+	err = conf.Add(&resolvconf.Option{Type: "timeout", Value: -2})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(conf.GetOptions()))
+}
+
+func TestRegisterOption(t *testing.T) {
+	assert.Nil(t, resolvconf.RegisterOption("use-dnscrypt", resolvconf.OptionBool))
+	assert.Nil(t, resolvconf.RegisterOption("dnscrypt-port", resolvconf.OptionInt))
+	for _, name := range []string{"use-dnscrypt", "ndots", "rotate"} {
+		assert.True(t, errors.Is(resolvconf.RegisterOption(name, resolvconf.OptionBool), resolvconf.ErrDuplicateItem), name)
+	}
+	for _, name := range []string{"", "a:b", "a b", "#a"} {
+		assert.True(t, errors.Is(resolvconf.RegisterOption(name, resolvconf.OptionBool), resolvconf.ErrInvalidValue), name)
+	}
+
+	in := "options use-dnscrypt dnscrypt-port:5443 ndots:2\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Nil(t, err)
+	opt, ok := conf.GetOption("dnscrypt-port")
+	assert.True(t, ok)
+	assert.Equal(t, 5443, opt.Get())
+	assert.True(t, conf.HasOption("use-dnscrypt"))
+	assert.Equal(t, in+"\n", conf.String())
+
+	assert.NotNil(t, resolvconf.NewOption("use-dnscrypt"))
+	_, err = resolvconf.NewOptionWithValue("dnscrypt-port", 70000)
+	assert.Nil(t, err)
+	_, err = resolvconf.NewOptionWithValue("dnscrypt-port", -1)
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	_, err = resolvconf.ReadConf(strings.NewReader("options dnscrypt-port\n"))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+}
+
+func TestAllowUnknownOptions(t *testing.T) {
+	in := "options rotate x-private x-level:3 debug:1\n"
+	_, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.True(t, errors.Is(err, resolvconf.ErrUnknownOption))
+
+	conf, err := resolvconf.ReadConf(strings.NewReader(in), resolvconf.AllowUnknownOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, in+"\n", conf.String())
+	assert.True(t, conf.HasOption("x-level:3"))
+	ws := conf.Warnings()
+	assert.Equal(t, 3, len(ws))
+	for _, w := range ws {
+		assert.Equal(t, resolvconf.WarnUnknownOption, w.Code)
+		assert.Equal(t, 1, w.Line)
+	}
+
+	// Repeating one is no error, the first is kept
+	assert.Nil(t, conf.Add(&resolvconf.Option{Type: "x-private", Value: -1}))
+	assert.Equal(t, 4, len(conf.GetOptions()))
+	// Options that can't be written as they are still fail
+	assert.True(t, errors.Is(conf.Add(&resolvconf.Option{Type: "a b", Value: -1}), resolvconf.ErrUnknownOption))
+
+	conf = resolvconf.New()
+	assert.NotNil(t, conf.Add(&resolvconf.Option{Type: "x-private", Value: -1}))
+	conf.SetAllowUnknownOptions(true)
+	assert.Nil(t, conf.Add(&resolvconf.Option{Type: "x-private", Value: -1}))
+	assert.Equal(t, "options x-private\n\n", conf.String())
+}
+
 func TestRemoveMultipleItems(t *testing.T) {
 	conf := resolvconf.New()
 	err := conf.Add(resolvconf.NewOption("ndots").Set(4), resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
@@ -262,30 +448,53 @@ func TestThatOptionsWithValueUpdatesExistingItems(t *testing.T) {
 		assert.Equal(t, 5, conf.GetOptions()[i].Get())
 	}
 
-	// Now test with one that should not work
+	// Boolean options are already set
 	err := conf.Add(resolvconf.NewOption("debug"))
 	assert.Nil(t, err)
 	assert.NotNil(t, conf.Find(resolvconf.NewOption("debug")))
 	err = conf.Add(resolvconf.NewOption("debug"))
-	assert.NotNil(t, err)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(conf.GetOptions()))
+
+	// Removing doesn't need the value
+	assert.Nil(t, conf.Remove(resolvconf.NewOption("ndots").Set(1)))
+	assert.Nil(t, conf.Find(resolvconf.NewOption("ndots")))
+	assert.Nil(t, conf.Add(resolvconf.NewOption("ndots").Set(2)))
+	assert.Equal(t, "options timeout:5 attempts:5 debug ndots:2\n\n", conf.String())
 }
 
-func TestThatSortItemWithDifferentNetmaskToSortItemUpdatesItem(t *testing.T) {
-	conf := resolvconf.New()
-	conf.Add(resolvconf.NewSortItem(net.ParseIP("130.155.160.0")).SetNetmask(net.ParseIP("255.255.240.0")))
-	si := conf.Find(resolvconf.NewSortItem(net.ParseIP("130.155.160.0")))
-	assert.NotNil(t, si)
-	assert.Equal(t, net.ParseIP("255.255.240.0"), si.(*resolvconf.SortItem).GetNetmask())
+func TestOptionValueEqual(t *testing.T) {
+	ndots2 := resolvconf.NewOption("ndots").Set(2)
+	assert.True(t, ndots2.Equal(resolvconf.NewOption("ndots").Set(5)))
+	assert.False(t, ndots2.ValueEqual(resolvconf.NewOption("ndots").Set(5)))
+	assert.True(t, ndots2.ValueEqual(resolvconf.NewOption("ndots").Set(2)))
+	assert.False(t, ndots2.ValueEqual(resolvconf.NewOption("timeout").Set(2)))
+	assert.False(t, ndots2.ValueEqual(resolvconf.NewDomain("ndots")))
+}
 
-	err := conf.Add(resolvconf.NewSortItem(net.ParseIP("130.155.160.0")).SetNetmask(net.ParseIP("255.255.240.100")))
-	assert.Nil(t, err)
-	si = conf.Find(resolvconf.NewSortItem(net.ParseIP("130.155.160.0")))
-	assert.NotNil(t, si)
-	assert.Equal(t, net.ParseIP("255.255.240.100"), si.(*resolvconf.SortItem).GetNetmask())
+func TestThatSortItemsWithDifferentNetmasksAreDifferent(t *testing.T) {
+	conf := resolvconf.New()
+	wide := resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.0.0.0"))
+	narrow := resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.255.0.0"))
+	bare := resolvconf.NewSortItem(net.ParseIP("10.0.0.0"))
+	assert.Nil(t, conf.Add(wide, narrow, bare))
+	assert.Equal(t, 3, len(conf.GetSortItems()))
+	assert.False(t, wide.Equal(narrow))
+	assert.False(t, bare.Equal(wide))
+	assert.True(t, narrow.Equal(resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.IPv4(255, 255, 0, 0))))
+
+	// Only the exact pair is a duplicate and is removed
+	assert.NotNil(t, conf.Add(resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.255.0.0"))))
+	assert.Nil(t, conf.Remove(resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.255.0.0"))))
+	assert.Equal(t, []resolvconf.SortItem{*wide, *bare}, conf.GetSortItems())
+	assert.NotNil(t, conf.Remove(resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.255.255.0"))))
+	assert.Nil(t, conf.Remove(bare))
+	assert.Equal(t, []resolvconf.SortItem{*wide}, conf.GetSortItems())
 }
 
 func TestSearchDomainLimit(t *testing.T) {
 	conf := resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
 	for i := 0; i < 6; i++ {
 		err := conf.Add(resolvconf.NewSearchDomain("foo.bar" + strconv.Itoa(i)))
 		assert.Nil(t, err)
@@ -297,17 +506,62 @@ func TestSearchDomainLimit(t *testing.T) {
 
 func TestSearchDomainCharLimit(t *testing.T) {
 	conf := resolvconf.New()
-	var dom string
-	for i := 0; i < 256; i++ {
-		dom = dom + "1"
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
+	// 3 * 63 + 64 and 3 spaces is 256 characters
+	for _, c := range "abc" {
+		err := conf.Add(resolvconf.NewSearchDomain(strings.Repeat(string(c), 63)))
+		assert.Nil(t, err)
 	}
-	err := conf.Add(resolvconf.NewSearchDomain(dom))
+	err := conf.Add(resolvconf.NewSearchDomain(strings.Repeat("d", 60) + ".com"))
 	assert.Nil(t, err)
 	// Adding one more should break maximum number of chars limit
 	err = conf.Add(resolvconf.NewSearchDomain("2"))
 	assert.NotNil(t, err)
 }
 
+func TestSearchDomainCharLimitCountsSeparators(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
+	// 127 + 1 + 127 is 255 characters, one more fits
+	label := strings.Repeat("a", 63)
+	assert.Nil(t, conf.Add(resolvconf.NewSearchDomain(label+"."+label)))
+	assert.Nil(t, conf.Add(resolvconf.NewSearchDomain("b"+label[1:]+"."+label)))
+	err := conf.Add(resolvconf.NewSearchDomain("c"))
+	assert.True(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+}
+
+func TestSearchPolicies(t *testing.T) {
+	addSeven := func(conf *resolvconf.Conf) error {
+		var err error
+		for i := 0; i < 7; i++ {
+			err = conf.Add(resolvconf.NewSearchDomain("foo.bar" + strconv.Itoa(i)))
+		}
+		return err
+	}
+
+	conf := resolvconf.New()
+	assert.Equal(t, resolvconf.SearchModern, conf.GetSearchPolicy())
+	assert.Nil(t, addSeven(conf))
+	assert.Equal(t, 7, len(conf.GetSearchDomains()))
+	for _, c := range "abcde" {
+		assert.Nil(t, conf.Add(resolvconf.NewSearchDomain(strings.Repeat(string(c), 63))))
+	}
+
+	conf = resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
+	assert.True(t, errors.Is(addSeven(conf), resolvconf.ErrLimitExceeded))
+	assert.Equal(t, 6, len(conf.GetSearchDomains()))
+
+	conf = resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchWarnOnly)
+	assert.Nil(t, addSeven(conf))
+	assert.Equal(t, 7, len(conf.GetSearchDomains()))
+	ws := conf.Warnings()
+	assert.Equal(t, 1, len(ws))
+	assert.Equal(t, resolvconf.WarnLimitExceeded, ws[0].Code)
+	assert.Equal(t, "Search domain foo.bar6 is beyond the legacy limits, too many search domains, 6 is maximum", ws[0].Message)
+}
+
 func TestLogging(t *testing.T) {
 
 	// Nothing is logged if not enabeled
@@ -371,6 +625,72 @@ func TestIfFindReturnsPointer(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestDuplicatesAfterModifyingFoundItem(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewSearchDomain("foo.com"), resolvconf.NewSearchDomain("bar.com"))
+	sd := conf.Find(resolvconf.NewSearchDomain("foo.com")).(*resolvconf.SearchDomain)
+	sd.Name = "baz.com"
+	assert.NotNil(t, conf.Add(resolvconf.NewSearchDomain("baz.com")))
+	assert.Nil(t, conf.Add(resolvconf.NewSearchDomain("foo.com")))
+	assert.Nil(t, conf.Remove(resolvconf.NewSearchDomain("bar.com")))
+	assert.Equal(t, 2, len(conf.GetSearchDomains()))
+}
+
+func TestRemoveKeepsPositions(t *testing.T) {
+	// Enough removals for the positions to be recomputed on the way
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	var want []string
+	for i := 0; i < 100; i++ {
+		conf.Add(resolvconf.NewSearchDomain(fmt.Sprintf("d%d.com", i)))
+	}
+	for i := 0; i < 100; i++ {
+		if i%3 == 0 || i > 80 {
+			assert.Nil(t, conf.Remove(resolvconf.NewSearchDomain(fmt.Sprintf("d%d.com", i))))
+		} else {
+			want = append(want, fmt.Sprintf("d%d.com", i))
+		}
+	}
+	conf.Add(resolvconf.NewSearchDomain("last.com"))
+	assert.Nil(t, conf.Remove(resolvconf.NewSearchDomain("d80.com")))
+	want = append(want[:len(want)-1], "last.com")
+	var got []string
+	for _, sd := range conf.GetSearchDomains() {
+		got = append(got, sd.Name)
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestIndexAfterModifyingFoundItems(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewSearchDomain("a.com"), resolvconf.NewSearchDomain("b.com"), resolvconf.NewSearchDomain("c.com"))
+	a := conf.Find(resolvconf.NewSearchDomain("a.com")).(*resolvconf.SearchDomain)
+	for i := 0; i < 3; i++ {
+		// Find hands out the same item again
+		assert.True(t, a == conf.Find(resolvconf.NewSearchDomain("a.com")))
+	}
+	a.Name = "d.com"
+	assert.Nil(t, conf.Find(resolvconf.NewSearchDomain("a.com")))
+	assert.True(t, a == conf.Find(resolvconf.NewSearchDomain("d.com")))
+
+	// Renamed to an existing name, both are found and removed
+	a.Name = "c.com"
+	assert.Nil(t, conf.Remove(resolvconf.NewSearchDomain("c.com")))
+	assert.Nil(t, conf.Remove(resolvconf.NewSearchDomain("c.com")))
+	assert.Nil(t, conf.Find(resolvconf.NewSearchDomain("c.com")))
+	assert.Nil(t, conf.Add(resolvconf.NewSearchDomain("c.com")))
+	assert.Nil(t, conf.Remove(resolvconf.NewSearchDomain("b.com")))
+	assert.Equal(t, []resolvconf.SearchDomain{{Name: "c.com"}}, conf.GetSearchDomains())
+
+	// The length of the search list follows renames
+	conf = resolvconf.New()
+	conf.SetSearchPolicy(resolvconf.SearchLegacy)
+	conf.Add(resolvconf.NewSearchDomain(strings.Repeat("a", 60)+".com"), resolvconf.NewSearchDomain(strings.Repeat("b", 60)+".com"),
+		resolvconf.NewSearchDomain(strings.Repeat("c", 60)+".com"))
+	sd := conf.Find(resolvconf.NewSearchDomain(strings.Repeat("a", 60) + ".com")).(*resolvconf.SearchDomain)
+	sd.Name = "a.com"
+	assert.Nil(t, conf.Add(resolvconf.NewSearchDomain(strings.Repeat("d", 60)+".com")))
+}
+
 func TestAddNilElements(t *testing.T) {
 	conf := resolvconf.New()
 
@@ -424,6 +744,114 @@ func TestOptionsCanBeCapped(t *testing.T) {
 	assert.Contains(t, buf.String(), fmt.Sprintf("[WARN] Option attempts is capped to 5, set value is 6"))
 }
 
+func TestRangePolicies(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Equal(t, resolvconf.RangeClamp, conf.GetRangePolicy())
+	assert.Nil(t, conf.Add(resolvconf.NewOption("ndots").Set(200)))
+	opt, _ := conf.GetOption("ndots")
+	assert.Equal(t, 15, opt.Get())
+	assert.Equal(t, resolvconf.WarnValueCapped, conf.Warnings()[0].Code)
+
+	conf = resolvconf.New()
+	conf.SetRangePolicy(resolvconf.RangeReject)
+	for _, opt := range []*resolvconf.Option{
+		resolvconf.NewOption("ndots").Set(16),
+		resolvconf.NewOption("timeout").Set(31),
+		resolvconf.NewOption("attempts").Set(6),
+	} {
+		assert.True(t, errors.Is(conf.Add(opt), resolvconf.ErrInvalidValue), opt.String())
+	}
+	assert.Empty(t, conf.Items())
+
+	// The maximum itself and zero are fine
+	assert.Nil(t, conf.Add(resolvconf.NewOption("ndots").Set(15),
+		resolvconf.NewOption("timeout").Set(0),
+		resolvconf.NewOption("attempts").Set(0)))
+	assert.Nil(t, conf.Add(resolvconf.NewOption("ndots").Set(0)))
+	assert.Equal(t, 0, conf.EffectiveNdots())
+	assert.Equal(t, 0, conf.EffectiveTimeout())
+	assert.Equal(t, 0, conf.EffectiveAttempts())
+}
+
+func TestReadWithRangePolicy(t *testing.T) {
+	in := "options ndots:64\n"
+	conf, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.Nil(t, err)
+	assert.Equal(t, 15, conf.EffectiveNdots())
+	assert.Equal(t, 1, len(conf.Warnings()))
+
+	_, err = resolvconf.ReadConf(strings.NewReader(in), resolvconf.WithRangePolicy(resolvconf.RangeReject))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+
+	conf, err = resolvconf.ReadConf(strings.NewReader(in), resolvconf.WithRangePolicy(resolvconf.RangeReject), resolvconf.Lenient())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.ParseWarnings()))
+	assert.Equal(t, resolvconf.RangeReject, conf.GetRangePolicy())
+}
+
+func TestProfileNoneHasNoCountLimits(t *testing.T) {
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Equal(t, resolvconf.ProfileNone, conf.GetProfile())
+	for i := 1; i <= 5; i++ {
+		err := conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0." + strconv.Itoa(i))))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 8; i++ {
+		err := conf.Add(resolvconf.NewSearchDomain("foo.bar" + strconv.Itoa(i)))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 5, len(conf.GetNameservers()))
+	assert.Equal(t, 8, len(conf.GetSearchDomains()))
+
+	// Duplicates are still rejected
+	err := conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	assert.NotNil(t, err)
+}
+
+func TestNameserverLimitModes(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Equal(t, resolvconf.LimitStrict, conf.GetLimitMode())
+	assert.Equal(t, 3, conf.GetNameserverLimit())
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0."+strconv.Itoa(i)))))
+	}
+	err := conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.4")))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+
+	conf.SetLimitMode(resolvconf.LimitPermissive)
+	ws, err := conf.AddWithWarnings(resolvconf.NewNameserver(net.ParseIP("10.0.0.4")))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ws))
+	assert.Equal(t, resolvconf.WarnLimitExceeded, ws[0].Code)
+	assert.Equal(t, 4, len(conf.GetNameservers()))
+	assert.Contains(t, conf.String(), "nameserver 10.0.0.4")
+
+	var buf bytes.Buffer
+	ws = nil
+	assert.Nil(t, conf.Write(&buf, resolvconf.LimitNameservers(), resolvconf.CollectWarnings(&ws)))
+	assert.Equal(t, "nameserver 10.0.0.1\nnameserver 10.0.0.2\nnameserver 10.0.0.3\n\n", buf.String())
+	assert.Equal(t, 1, len(ws))
+	assert.Equal(t, resolvconf.WarnNameserverFiltered, ws[0].Code)
+	assert.Equal(t, 4, len(conf.GetNameservers()))
+}
+
+func TestSetNameserverLimit(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetNameserverLimit(1)
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))))
+	err := conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+	assert.Contains(t, err.Error(), "max is 1")
+
+	conf.SetNameserverLimit(0)
+	assert.Equal(t, 0, conf.GetNameserverLimit())
+	for i := 2; i <= 5; i++ {
+		assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0."+strconv.Itoa(i)))))
+	}
+	assert.Equal(t, 5, len(conf.GetNameservers()))
+	assert.Equal(t, 0, len(conf.Warnings()))
+}
+
 func ExampleConf_Add() {
 	conf := resolvconf.New()
 	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
@@ -467,3 +895,308 @@ func Example() {
 	//
 	// sortlist 130.155.160.0/255.255.240.0
 }
+
+func TestCloneIsDeep(t *testing.T) {
+	// The raw line is kept along with the error
+	conf, _ := resolvconf.ReadConf(strings.NewReader("# Comment\nnameserver 10.0.0.1\ndomain example.com\nsearch a.com\nsortlist 10.0.0.0/255.0.0.0\noptions ndots:2\nlookup file bind\n"))
+	want := conf.String()
+	c := conf.Clone()
+	assert.True(t, conf.Equal(c))
+	assert.Equal(t, want, c.String())
+
+	c.Find(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))).(*resolvconf.Nameserver).IP[15] = 9
+	c.Find(resolvconf.NewDomain("example.com")).(*resolvconf.Domain).Name = "other.com"
+	c.Find(resolvconf.NewSearchDomain("a.com")).(*resolvconf.SearchDomain).Name = "b.com"
+	si := c.Find(resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.0.0.0"))).(*resolvconf.SortItem)
+	si.Address[15], si.Netmask[15] = 7, 255
+	c.Find(resolvconf.NewOption("ndots")).(*resolvconf.Option).Set(5)
+	c.RemoveComments()
+	c.RemoveRawLines()
+	assert.Nil(t, c.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2"))))
+	c.SetLogLevel(resolvconf.LogOff)
+
+	assert.Equal(t, want, conf.String())
+	assert.Equal(t, "domain other.com\nnameserver 10.0.0.9\nnameserver 10.0.0.2\n\n"+
+		"sortlist 10.0.0.7/255.0.0.255\n\nsearch b.com\n\noptions ndots:5\n\n", c.String())
+}
+
+func TestAddStrict(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewOption("ndots").Set(2)))
+	before := conf.String()
+
+	dup := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	err := conf.AddStrict(resolvconf.NewNameserver(net.ParseIP("8.8.4.4")), resolvconf.NewOption("ndots").Set(5), dup, nil)
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	errs := resolvconf.ItemErrors(err)
+	assert.Equal(t, 2, len(errs))
+	assert.True(t, errs[0].Item == dup)
+	assert.Equal(t, before, conf.String())
+
+	ns := resolvconf.NewNameserver(net.ParseIP("8.8.4.4"))
+	assert.Nil(t, conf.AddStrict(ns, resolvconf.NewOption("ndots").Set(5)))
+	assert.True(t, conf.Find(ns) == ns)
+	assert.Equal(t, 5, conf.Find(resolvconf.NewOption("ndots")).(*resolvconf.Option).Value)
+}
+
+func TestRemoveStrict(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewSearchDomain("example.com")))
+	before := conf.String()
+
+	missing := resolvconf.NewDomain("example.com")
+	err := conf.RemoveStrict(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), missing)
+	assert.True(t, errors.Is(err, resolvconf.ErrNotFound))
+	assert.True(t, resolvconf.ItemErrors(err)[0].Item == missing)
+	assert.Equal(t, before, conf.String())
+
+	// An item given twice can only be removed once
+	ns := resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))
+	assert.NotNil(t, conf.RemoveStrict(ns, ns))
+	assert.Nil(t, conf.RemoveStrict(ns, resolvconf.NewSearchDomain("example.com")))
+	assert.Empty(t, conf.GetNameservers())
+	assert.Empty(t, conf.GetSearchDomains())
+}
+
+func TestDuplicatePolicies(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Equal(t, resolvconf.DuplicateError, conf.GetDuplicatePolicy())
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com")))
+	err := conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com"))
+	assert.Equal(t, 2, len(resolvconf.ItemErrors(err)))
+
+	conf.SetDuplicatePolicy(resolvconf.DuplicateIgnore)
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com")))
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Equal(t, 1, len(conf.GetSearchDomains()))
+
+	conf.SetDuplicatePolicy(resolvconf.DuplicateAllow)
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewSearchDomain("a.com")))
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+	assert.Equal(t, "nameserver 10.0.0.1\nnameserver 10.0.0.1\n\nsearch a.com a.com\n\n", conf.String())
+	// Duplicates count towards the limit
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))))
+	assert.True(t, errors.Is(conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2"))), resolvconf.ErrTooManyNameservers))
+
+	assert.Nil(t, conf.Remove(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))))
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+}
+
+func TestReadWithDuplicatePolicy(t *testing.T) {
+	in := "nameserver 10.0.0.1\nnameserver 10.0.0.1\nsearch a.com b.com a.com\n"
+	_, err := resolvconf.ReadConf(strings.NewReader(in))
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+
+	conf, err := resolvconf.ReadConf(strings.NewReader(in), resolvconf.WithDuplicates(resolvconf.DuplicateIgnore))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conf.GetNameservers()))
+	assert.Equal(t, 2, len(conf.GetSearchDomains()))
+	assert.Equal(t, resolvconf.DuplicateIgnore, conf.GetDuplicatePolicy())
+
+	conf, err = resolvconf.ReadConf(strings.NewReader(in), resolvconf.WithDuplicates(resolvconf.DuplicateAllow))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(conf.GetNameservers()))
+	assert.Equal(t, 3, len(conf.GetSearchDomains()))
+}
+
+func TestGetOption(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("options rotate ndots:2\noptions ndots:4 timeout:3\n"))
+	assert.Nil(t, err)
+	opt, ok := conf.GetOption("ndots")
+	assert.True(t, ok)
+	assert.Equal(t, resolvconf.Option{Type: "ndots", Value: 4}, opt)
+	assert.True(t, conf.HasOption("rotate"))
+	assert.False(t, conf.HasOption("edns0"))
+	assert.False(t, conf.HasOption("bogus"))
+	_, ok = conf.GetOption("attempts")
+	assert.False(t, ok)
+
+	n, ok := conf.Ndots()
+	assert.Equal(t, 4, n)
+	assert.True(t, ok)
+	n, ok = conf.Timeout()
+	assert.Equal(t, 3, n)
+	assert.True(t, ok)
+	_, ok = conf.Attempts()
+	assert.False(t, ok)
+
+	// A value option without a value is rejected
+	assert.True(t, errors.Is(conf.Add(resolvconf.NewOption("attempts")), resolvconf.ErrInvalidValue))
+	assert.False(t, conf.HasOption("attempts"))
+}
+
+func TestWriteValueOptionWithoutValue(t *testing.T) {
+	conf := resolvconf.New()
+	for _, name := range []string{"ndots", "timeout", "attempts"} {
+		err := conf.Add(resolvconf.NewOption(name))
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), name)
+	}
+	conf.Add(resolvconf.NewOption("timeout").Set(2))
+	var buf bytes.Buffer
+	assert.Nil(t, conf.Write(&buf))
+	assert.Equal(t, "options timeout:2\n\n", buf.String())
+}
+
+func TestEffectiveOptions(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Equal(t, 1, conf.EffectiveNdots())
+	assert.Equal(t, 5, conf.EffectiveTimeout())
+	assert.Equal(t, 2, conf.EffectiveAttempts())
+
+	conf.Add(resolvconf.NewOption("ndots").Set(0), resolvconf.NewOption("timeout").Set(1), resolvconf.NewOption("attempts").Set(4))
+	assert.Equal(t, 0, conf.EffectiveNdots())
+	assert.Equal(t, 1, conf.EffectiveTimeout())
+	assert.Equal(t, 4, conf.EffectiveAttempts())
+
+	// Values beyond the caps are clamped, also if changed after Add
+	conf.Find(resolvconf.NewOption("ndots")).(*resolvconf.Option).Value = 20
+	conf.Find(resolvconf.NewOption("timeout")).(*resolvconf.Option).Value = 60
+	conf.Find(resolvconf.NewOption("attempts")).(*resolvconf.Option).Value = 9
+	assert.Equal(t, 15, conf.EffectiveNdots())
+	assert.Equal(t, 30, conf.EffectiveTimeout())
+	assert.Equal(t, 5, conf.EffectiveAttempts())
+}
+
+func TestEffectiveSearchDomains(t *testing.T) {
+	names := func(sds []resolvconf.SearchDomain) []string {
+		var s []string
+		for _, sd := range sds {
+			s = append(s, sd.Name)
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		file, hostname string
+		search         []string
+	}{
+		{"search a.com b.com\n", "host.lab.example", []string{"a.com", "b.com"}},
+		{"domain corp.example\n", "host.lab.example", []string{"corp.example"}},
+		{"search a.com b.com\ndomain corp.example\n", "", []string{"corp.example"}},
+		{"domain corp.example\nsearch a.com b.com\n", "", []string{"a.com", "b.com"}},
+		{"nameserver 10.0.0.1\n", "host.lab.example", []string{"lab.example"}},
+		{"nameserver 10.0.0.1\n", "host.lab.example.", []string{"lab.example"}},
+		{"nameserver 10.0.0.1\n", "host", nil},
+		{"nameserver 10.0.0.1\n", "", nil},
+	} {
+		conf, err := resolvconf.ReadConf(strings.NewReader(tc.file))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.search, names(conf.EffectiveSearchDomains(tc.hostname)), tc.file)
+	}
+}
+
+func TestTypedFind(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver [10.0.0.1]:5353\nnameserver ::1\nsearch a.com b.com\nsortlist 10.0.0.0/255.0.0.0\n"))
+	assert.Nil(t, err)
+
+	ns, ok := conf.FindNameserver(net.IPv4(10, 0, 0, 1))
+	assert.True(t, ok)
+	assert.Equal(t, 5353, ns.Port)
+	ns, ok = conf.FindNameserver(net.IP{10, 0, 0, 1})
+	assert.True(t, ok)
+	_, ok = conf.FindNameserver(net.ParseIP("10.0.0.2"))
+	assert.False(t, ok)
+
+	sd, ok := conf.FindSearchDomain("b.com")
+	assert.True(t, ok)
+	assert.Equal(t, "b.com", sd.Name)
+	_, ok = conf.FindSearchDomain("c.com")
+	assert.False(t, ok)
+
+	si, ok := conf.FindSortItem(net.ParseIP("10.0.0.0"))
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.0/255.0.0.0", si.String())
+	_, ok = conf.FindSortItem(nil)
+	assert.False(t, ok)
+
+	// The items are those of the Conf
+	ns.Port = 0
+	sd.Name = "c.com"
+	assert.Equal(t, "nameserver 10.0.0.1\nnameserver ::1\n\nsortlist 10.0.0.0/255.0.0.0\n\nsearch a.com c.com\n\n", conf.String())
+	_, ok = conf.FindSearchDomain("c.com")
+	assert.True(t, ok)
+}
+
+func TestBulkRemove(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("# Top\nnameserver 10.0.0.1 # vpn\nnameserver 10.0.0.2\n" +
+		"search a.com b.com\nsortlist 10.0.0.0\noptions ndots:2 rotate\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, conf.RemoveNameservers())
+	assert.Empty(t, conf.GetNameservers())
+	assert.Equal(t, 1, len(conf.GetComments()))
+	assert.Equal(t, 0, conf.RemoveNameservers())
+	assert.Equal(t, 2, conf.RemoveSearchDomains())
+	assert.Equal(t, 1, conf.RemoveSortItems())
+	assert.Equal(t, 2, conf.RemoveOptions())
+	assert.Equal(t, "# Top\n", conf.String())
+
+	// Items can be added again
+	assert.Nil(t, conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))))
+	assert.Equal(t, 2, conf.Clear())
+	assert.Equal(t, "", conf.String())
+	assert.Equal(t, 0, conf.Clear())
+	assert.Equal(t, 0, resolvconf.New().RemoveOptions())
+}
+
+func TestReplaceNameservers(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("# Top\nnameserver 10.0.0.1 # old\nnameserver 10.0.0.2\n# Search\nsearch a.com\n"))
+	assert.Nil(t, err)
+	before := conf.String()
+
+	ns := func(s string) resolvconf.Nameserver { return *resolvconf.NewNameserver(net.ParseIP(s)) }
+	err = conf.ReplaceNameservers(ns("1.1.1.1"), ns("1.1.1.1"))
+	assert.True(t, errors.Is(err, resolvconf.ErrDuplicateItem))
+	err = conf.ReplaceNameservers(ns("1.1.1.1"), ns("1.1.1.2"), ns("1.1.1.3"), ns("1.1.1.4"))
+	assert.True(t, errors.Is(err, resolvconf.ErrTooManyNameservers))
+	err = conf.ReplaceNameservers(ns("1.1.1.1"), resolvconf.Nameserver{})
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	assert.Equal(t, before, conf.String())
+
+	// The old servers are in the way of the limit only if kept
+	assert.Nil(t, conf.ReplaceNameservers(ns("10.0.0.2"), ns("1.1.1.1"), ns("1.1.1.2")))
+	assert.Equal(t, "# Top\nnameserver 10.0.0.2\nnameserver 1.1.1.1\nnameserver 1.1.1.2\n# Search\nsearch a.com\n", conf.String())
+
+	assert.Nil(t, conf.ReplaceNameservers())
+	assert.Empty(t, conf.GetNameservers())
+	assert.Nil(t, conf.ReplaceNameservers(ns("8.8.8.8")))
+	assert.Equal(t, "# Top\n# Search\nsearch a.com\nnameserver 8.8.8.8\n", conf.String())
+}
+
+func TestReplaceSearchDomains(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Nil(t, conf.AddSearchDomains("a.com", "b.com"))
+	err := conf.ReplaceSearchDomains(resolvconf.SearchDomain{Name: "c.com"}, resolvconf.SearchDomain{Name: "-bad"})
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	assert.Equal(t, 2, len(conf.GetSearchDomains()))
+	assert.Nil(t, conf.ReplaceSearchDomains(resolvconf.SearchDomain{Name: "b.com"}, resolvconf.SearchDomain{Name: "c.com"}))
+	assert.Equal(t, []resolvconf.SearchDomain{{Name: "b.com"}, {Name: "c.com"}}, conf.GetSearchDomains())
+}
+
+func TestItems(t *testing.T) {
+	in := "# Top\nnameserver 10.0.0.1\nsearch a.com b.com # lab\noptions rotate\nnameserver 10.0.0.2\nlookup file bind\n"
+	conf, _ := resolvconf.ReadConf(strings.NewReader(in))
+	items := conf.Items()
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("%T %s", item, item))
+	}
+	assert.Equal(t, []string{
+		"*resolvconf.Comment # Top",
+		"*resolvconf.Nameserver 10.0.0.1",
+		"*resolvconf.SearchDomain a.com",
+		"*resolvconf.SearchDomain b.com",
+		"*resolvconf.Comment # lab",
+		"*resolvconf.Option rotate",
+		"*resolvconf.Nameserver 10.0.0.2",
+		"*resolvconf.RawLine lookup file bind",
+	}, lines)
+
+	// Neither the slice nor the items are those of the Conf
+	items[0] = nil
+	items[1].(*resolvconf.Nameserver).IP = net.ParseIP("10.9.9.9")
+	assert.Equal(t, in, conf.String())
+
+	dst := make([]resolvconf.ConfItem, 0, 16)
+	assert.Equal(t, 8, len(conf.ItemsInto(dst)))
+	assert.Empty(t, resolvconf.New().Items())
+}
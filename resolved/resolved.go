@@ -0,0 +1,184 @@
+// Package resolved builds resolvconf configurations from the state of
+// systemd-resolved, queried over D-Bus.
+//
+// It lives in its own package so that the D-Bus dependency is only pulled
+// in by users that need it
+package resolved
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Fa1k3n/resolvconf"
+	"github.com/godbus/dbus/v5"
+	"net"
+	"strings"
+)
+
+const (
+	busName    = "org.freedesktop.resolve1"
+	objectPath = "/org/freedesktop/resolve1"
+	managerIf  = "org.freedesktop.resolve1.Manager"
+)
+
+// Address families as used by resolved
+const (
+	afInet  = 2
+	afInet6 = 10
+)
+
+// ErrUnavailable is matched, using errors.Is, by errors returned when
+// systemd-resolved is not running or the system bus can't be reached
+var ErrUnavailable = errors.New("systemd-resolved is not available")
+
+// UnavailableError is returned when systemd-resolved can't be queried
+type UnavailableError struct {
+	Err error // Underlying D-Bus error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnavailable, e.Err)
+}
+
+// Unwrap returns the underlying D-Bus error
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrUnavailable) true
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// dnsServer is an entry of the DNS property, signature (iiay)
+type dnsServer struct {
+	Ifindex int32
+	Family  int32
+	Address []byte
+}
+
+// dnsDomain is an entry of the Domains property, signature (isb)
+type dnsDomain struct {
+	Ifindex   int32
+	Domain    string
+	RouteOnly bool
+}
+
+// state is the subset of the resolved manager properties that can be
+// mapped onto a resolv.conf
+type state struct {
+	DNS     []dnsServer
+	Domains []dnsDomain
+	DNSSEC  string
+}
+
+// FromSystemdResolved connects to the system bus and assembles a Conf from
+// the global and per-link DNS servers and search domains currently known to
+// systemd-resolved. Routing-only domains (~example.com) are not search
+// domains and are left out. The returned Conf uses ProfileNone since
+// resolved has no limit on the number of servers.
+//
+// Errors matching ErrUnavailable are returned if resolved isn't running or
+// the bus can't be reached
+func FromSystemdResolved(ctx context.Context) (*resolvconf.Conf, error) {
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return nil, &UnavailableError{err}
+	}
+	defer conn.Close()
+
+	st, err := fetch(ctx, conn.Object(busName, objectPath))
+	if err != nil {
+		return nil, err
+	}
+	return build(st)
+}
+
+func fetch(ctx context.Context, obj dbus.BusObject) (state, error) {
+	var st state
+	props := []struct {
+		name string
+		dst  interface{}
+	}{
+		{"DNS", &st.DNS},
+		{"Domains", &st.Domains},
+		{"DNSSEC", &st.DNSSEC},
+	}
+	for _, p := range props {
+		var v dbus.Variant
+		call := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, managerIf, p.name)
+		if err := call.Store(&v); err != nil {
+			if ctx.Err() != nil {
+				return st, fmt.Errorf("resolved: reading %s: %w", p.name, ctx.Err())
+			}
+			if isUnavailable(err) {
+				return st, &UnavailableError{err}
+			}
+			return st, fmt.Errorf("resolved: reading %s: %w", p.name, err)
+		}
+		if err := v.Store(p.dst); err != nil {
+			return st, fmt.Errorf("resolved: decoding %s: %w", p.name, err)
+		}
+	}
+	return st, nil
+}
+
+func isUnavailable(err error) bool {
+	var derr dbus.Error
+	if errors.As(err, &derr) {
+		switch derr.Name {
+		case "org.freedesktop.DBus.Error.ServiceUnknown",
+			"org.freedesktop.DBus.Error.NameHasNoOwner",
+			"org.freedesktop.DBus.Error.NoReply":
+			return true
+		}
+	}
+	return false
+}
+
+func build(st state) (*resolvconf.Conf, error) {
+	conf := resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone))
+
+	for _, srv := range st.DNS {
+		var ip net.IP
+		switch {
+		case srv.Family == afInet && len(srv.Address) == net.IPv4len:
+			ip = net.IP(srv.Address)
+		case srv.Family == afInet6 && len(srv.Address) == net.IPv6len:
+			ip = net.IP(srv.Address)
+		default:
+			return nil, fmt.Errorf("resolved: malformed address for link %d", srv.Ifindex)
+		}
+		// Same server may be configured both globally and on links
+		ns := resolvconf.NewNameserver(ip)
+		if conf.Find(ns) == nil {
+			if err := conf.Add(ns); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, dom := range st.Domains {
+		name := strings.TrimSuffix(dom.Domain, ".")
+		if dom.RouteOnly || name == "" {
+			continue
+		}
+		sd := resolvconf.NewSearchDomain(name)
+		if conf.Find(sd) == nil {
+			if err := conf.Add(sd); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// DNSSEC validation needs EDNS0, mirror what resolved puts in its own
+	// generated files
+	switch st.DNSSEC {
+	case "yes", "allow-downgrade":
+		if err := conf.Add(resolvconf.NewOption("edns0")); err != nil {
+			return nil, err
+		}
+	}
+
+	return conf, nil
+}
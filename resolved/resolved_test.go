@@ -0,0 +1,59 @@
+package resolved
+
+import (
+	"context"
+	"errors"
+	"github.com/Fa1k3n/resolvconf"
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestBuildFromState(t *testing.T) {
+	st := state{
+		DNS: []dnsServer{
+			{0, afInet, net.ParseIP("10.0.0.1").To4()},
+			{2, afInet, net.ParseIP("10.0.0.2").To4()},
+			{2, afInet6, net.ParseIP("2001:db8::1")},
+			{3, afInet, net.ParseIP("10.0.0.3").To4()},
+			{3, afInet, net.ParseIP("10.0.0.1").To4()},
+		},
+		Domains: []dnsDomain{
+			{0, "corp.example", false},
+			{2, "vpn.example.", false},
+			{2, "internal.example", true},
+		},
+		DNSSEC: "allow-downgrade",
+	}
+	conf, err := build(st)
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ProfileNone, conf.GetProfile())
+	nss := conf.GetNameservers()
+	assert.Equal(t, 4, len(nss))
+	assert.Equal(t, "10.0.0.1", nss[0].String())
+	assert.Equal(t, "2001:db8::1", nss[2].String())
+	doms := conf.GetSearchDomains()
+	assert.Equal(t, 2, len(doms))
+	assert.Equal(t, "vpn.example", doms[1].Name)
+	assert.NotNil(t, conf.Find(resolvconf.NewOption("edns0")))
+}
+
+func TestBuildMalformedAddress(t *testing.T) {
+	_, err := build(state{DNS: []dnsServer{{1, afInet, []byte{1, 2, 3}}}})
+	assert.NotNil(t, err)
+}
+
+func TestUnavailableError(t *testing.T) {
+	err := error(&UnavailableError{errors.New("no bus")})
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.True(t, isUnavailable(dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown"}))
+	assert.False(t, isUnavailable(errors.New("other")))
+}
+
+func TestFromSystemdResolvedCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := FromSystemdResolved(ctx)
+	assert.NotNil(t, err)
+}
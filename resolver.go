@@ -0,0 +1,149 @@
+package resolvconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver returns a net.Resolver sending all queries to the nameservers of
+// the configuration instead of the ones in /etc/resolv.conf. The nameservers
+// and options are copied, later changes to the Conf don't affect the
+// returned resolver.
+//
+// Each connection is bounded by the timeout option, when a server fails
+// the next one is used for following queries. With the rotate option
+// servers are used round robin. Dialing is retried over all servers as many
+// times as the attempts option says, bounded by the context of the lookup
+func (conf *Conf) Resolver() *net.Resolver {
+	d := &resolverDialer{
+		nameservers: conf.GetNameservers(),
+		timeout:     time.Duration(conf.EffectiveTimeout()) * time.Second,
+		attempts:    conf.EffectiveAttempts(),
+		rotate:      conf.HasOption("rotate"),
+	}
+	if d.attempts < 1 {
+		d.attempts = 1
+	}
+	return &net.Resolver{PreferGo: true, Dial: d.dial}
+}
+
+type resolverDialer struct {
+	nameservers []Nameserver
+	timeout     time.Duration
+	attempts    int
+	rotate      bool
+	next        uint32 // Index of the server to use next
+}
+
+func (d *resolverDialer) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	if len(d.nameservers) == 0 {
+		return nil, errors.New("No nameservers configured")
+	}
+	n := uint32(len(d.nameservers))
+	start := atomic.LoadUint32(&d.next)
+	if d.rotate {
+		start = atomic.AddUint32(&d.next, 1) - 1
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for a := 0; a < d.attempts; a++ {
+		for i := uint32(0); i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("Dialing nameserver: %w", err)
+			}
+			idx := (start + i) % n
+			dctx, cancel := context.WithTimeout(ctx, d.timeout)
+			c, err := dialer.DialContext(dctx, network, d.nameservers[idx].Addr())
+			cancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("Dialing nameserver: %w", ctx.Err())
+				}
+				lastErr = err
+				continue
+			}
+			rc := &resolverConn{Conn: c, d: d, idx: idx, deadline: time.Now().Add(d.timeout)}
+			// The resolver tells UDP from TCP framing by PacketConn
+			if pc, ok := c.(net.PacketConn); ok {
+				return &resolverPacketConn{rc, pc}, nil
+			}
+			return rc, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// failed moves on to the next server, unless another connection already did
+func (d *resolverDialer) failed(idx uint32) {
+	atomic.CompareAndSwapUint32(&d.next, idx, (idx+1)%uint32(len(d.nameservers)))
+}
+
+// resolverConn caps deadlines to the configured timeout and reports failed
+// servers back to the dialer
+type resolverConn struct {
+	net.Conn
+	d        *resolverDialer
+	idx      uint32
+	deadline time.Time
+}
+
+func (c *resolverConn) capped(t time.Time) time.Time {
+	if t.IsZero() || t.After(c.deadline) {
+		return c.deadline
+	}
+	return t
+}
+
+func (c *resolverConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.d.failed(c.idx)
+	}
+	return n, err
+}
+
+func (c *resolverConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		c.d.failed(c.idx)
+	}
+	return n, err
+}
+
+func (c *resolverConn) SetDeadline(t time.Time) error {
+	return c.Conn.SetDeadline(c.capped(t))
+}
+
+func (c *resolverConn) SetReadDeadline(t time.Time) error {
+	return c.Conn.SetReadDeadline(c.capped(t))
+}
+
+func (c *resolverConn) SetWriteDeadline(t time.Time) error {
+	return c.Conn.SetWriteDeadline(c.capped(t))
+}
+
+type resolverPacketConn struct {
+	*resolverConn
+	pc net.PacketConn
+}
+
+func (c *resolverPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.pc.ReadFrom(b)
+	if err != nil {
+		c.d.failed(c.idx)
+	}
+	return n, addr, err
+}
+
+func (c *resolverPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.pc.WriteTo(b, addr)
+	if err != nil {
+		c.d.failed(c.idx)
+	}
+	return n, err
+}
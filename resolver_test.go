@@ -0,0 +1,97 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeA answers A queries with addr, or does not answer if addr is nil
+func fakeA(t *testing.T, addr net.IP) (*resolvconf.Nameserver, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if addr == nil || n < 12 {
+				continue
+			}
+			// Skip the question name, drop the EDNS0 record that follows
+			end := 12
+			for end < n && buf[end] != 0 {
+				end += int(buf[end]) + 1
+			}
+			end += 5
+			resp := append([]byte(nil), buf[:end]...)
+			resp[2] |= 0x80
+			resp[3] = 0x80 // RA
+			binary.BigEndian.PutUint16(resp[10:], 0)
+			if qtype := binary.BigEndian.Uint16(resp[end-4:]); qtype == 1 {
+				binary.BigEndian.PutUint16(resp[6:], 1)
+				resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				resp = append(resp, addr.To4()...)
+			}
+			pc.WriteTo(resp, from)
+		}
+	}()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	return resolvconf.NewNameserver(net.ParseIP("127.0.0.1")).SetPort(port), func() { pc.Close() }
+}
+
+func TestResolverUsesConfNameservers(t *testing.T) {
+	ns, stop := fakeA(t, net.ParseIP("192.0.2.10"))
+	defer stop()
+	conf := resolvconf.New()
+	conf.Add(ns)
+
+	ips, err := conf.Resolver().LookupIP(context.Background(), "ip4", "test.example.")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ips))
+	assert.True(t, ips[0].Equal(net.ParseIP("192.0.2.10")))
+}
+
+func TestResolverFallsThroughToNextServer(t *testing.T) {
+	silent, stop1 := fakeA(t, nil)
+	defer stop1()
+	ns, stop2 := fakeA(t, net.ParseIP("192.0.2.20"))
+	defer stop2()
+	conf := resolvconf.New()
+	conf.Add(silent, ns, resolvconf.NewOption("timeout").Set(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ips, err := conf.Resolver().LookupIP(ctx, "ip4", "test.example.")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ips))
+	assert.True(t, ips[0].Equal(net.ParseIP("192.0.2.20")))
+}
+
+func TestResolverHonorsContext(t *testing.T) {
+	silent, stop := fakeA(t, nil)
+	defer stop()
+	conf := resolvconf.New()
+	conf.Add(silent)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := conf.Resolver().LookupIP(ctx, "ip4", "test.example.")
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
+
+func TestResolverWithoutNameservers(t *testing.T) {
+	_, err := resolvconf.New().Resolver().LookupIP(context.Background(), "ip4", "test.example.")
+	assert.NotNil(t, err)
+	var dnsErr *net.DNSError
+	assert.True(t, errors.As(err, &dnsErr))
+}
@@ -0,0 +1,132 @@
+package resolvconf
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// scutilResolver is a "resolver #N" block of scutil --dns
+type scutilResolver struct {
+	nameservers []string
+	search      []string
+	domain      string
+	options     []string
+	timeout     string
+	port        string
+}
+
+// FromScutilDNS parses the output of the macOS scutil --dns command and
+// returns one Conf per resolver block, both the default and the scoped
+// ones, in the order they are listed. The domain a resolver is scoped to
+// becomes the domain of its Conf. Options without a resolv.conf equivalent,
+// like mdns, are skipped and IPv6 zones are dropped from addresses.
+//
+// The Confs have ProfileNone as macOS has no glibc limits. Like ReadConf
+// the Confs are returned along with the error if some of the items could
+// not be added
+func FromScutilDNS(r io.Reader) ([]*Conf, error) {
+	var blocks []*scutilResolver
+	var cur *scutilResolver
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "resolver #") {
+			blocks = append(blocks, &scutilResolver{})
+			cur = blocks[len(blocks)-1]
+			continue
+		}
+		i := strings.Index(line, ":")
+		if cur == nil || i < 0 {
+			continue // Section headers and blank lines
+		}
+		key := strings.TrimSpace(line[:i])
+		val := strings.TrimSpace(line[i+1:])
+		if j := strings.Index(key, "["); j >= 0 {
+			key = key[:j]
+		}
+		switch key {
+		case "nameserver":
+			cur.nameservers = append(cur.nameservers, val)
+		case "search domain":
+			cur.search = append(cur.search, val)
+		case "domain":
+			cur.domain = val
+		case "options":
+			cur.options = append(cur.options, strings.Fields(val)...)
+		case "timeout":
+			cur.timeout = val
+		case "port":
+			cur.port = val
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var res *multierror.Error
+	confs := make([]*Conf, len(blocks))
+	for i, b := range blocks {
+		conf, err := b.conf()
+		if err != nil {
+			res = multierror.Append(res, fmt.Errorf("resolver #%d: %w", i+1, err))
+		}
+		confs[i] = conf
+	}
+	return confs, res.ErrorOrNil()
+}
+
+func (b *scutilResolver) conf() (*Conf, error) {
+	var res *multierror.Error
+	conf := New(WithProfile(ProfileNone))
+	port := 0
+	if b.port != "" {
+		p, err := strconv.Atoi(b.port)
+		if err != nil {
+			res = multierror.Append(res, fmt.Errorf("%w: malformed port %s", ErrInvalidValue, b.port))
+		}
+		port = p
+	}
+	for _, s := range b.nameservers {
+		if i := strings.Index(s, "%"); i >= 0 {
+			s = s[:i]
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			res = multierror.Append(res, fmt.Errorf("%w: malformed IP address: %s", ErrInvalidValue, s))
+			continue
+		}
+		ns := NewNameserver(ip)
+		if port != 0 && port != DefaultPort {
+			ns.SetPort(port)
+		}
+		if err := conf.Add(ns); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	if b.domain != "" {
+		conf.Add(NewDomain(strings.TrimSuffix(b.domain, ".")))
+	}
+	for _, s := range b.search {
+		if err := conf.Add(NewSearchDomain(strings.TrimSuffix(s, "."))); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	if b.timeout != "" {
+		b.options = append(b.options, "timeout:"+b.timeout)
+	}
+	for _, s := range b.options {
+		opt, err := parseOption(s)
+		if err != nil {
+			continue
+		}
+		if err := conf.Add(opt); err != nil {
+			res = multierror.Append(res, err)
+		}
+	}
+	return conf, res.ErrorOrNil()
+}
@@ -0,0 +1,54 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readScutil(t *testing.T, name string) []*resolvconf.Conf {
+	f, err := os.Open("testdata/" + name)
+	assert.Nil(t, err)
+	defer f.Close()
+	confs, err := resolvconf.FromScutilDNS(f)
+	assert.Nil(t, err)
+	return confs
+}
+
+func TestFromScutilDNSHighSierra(t *testing.T) {
+	confs := readScutil(t, "scutil-dns.10.13.txt")
+	assert.Equal(t, 4, len(confs))
+
+	str, _ := GetConf(confs[0])
+	assert.Equal(t, "nameserver 192.168.1.1\nnameserver 8.8.8.8\n\nsearch corp.example example.com\n\n", str)
+
+	assert.Equal(t, "local", confs[1].GetDomain().Name)
+	assert.Empty(t, confs[1].GetNameservers())
+	assert.Equal(t, "timeout:5", confs[1].GetOptions()[0].String())
+	assert.Equal(t, "254.169.in-addr.arpa", confs[2].GetDomain().Name)
+
+	// Scoped resolver
+	assert.Equal(t, "192.168.1.1", confs[3].GetNameservers()[0].String())
+}
+
+func TestFromScutilDNSSonoma(t *testing.T) {
+	confs := readScutil(t, "scutil-dns.14.txt")
+	assert.Equal(t, 4, len(confs))
+
+	nss := confs[0].GetNameservers()
+	assert.Equal(t, 2, len(nss))
+	assert.Equal(t, "fe80::1", nss[1].String())
+
+	assert.Equal(t, "vpn.example", confs[1].GetDomain().Name)
+	assert.Equal(t, "[10.8.0.53]:5353", confs[1].GetNameservers()[0].String())
+	assert.Equal(t, "timeout:3", confs[1].GetOptions()[0].String())
+}
+
+func TestFromScutilDNSMalformed(t *testing.T) {
+	confs, err := resolvconf.FromScutilDNS(strings.NewReader("resolver #1\n  nameserver[0] : bogus\n  nameserver[1] : 10.0.0.1\n"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(confs))
+	assert.Equal(t, 1, len(confs[0].GetNameservers()))
+}
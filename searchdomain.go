@@ -2,39 +2,102 @@ package resolvconf
 
 import (
 	"fmt"
-	"unicode/utf8"
+	"strings"
 )
 
+// SearchPolicy selects the limits of the search list enforced by Add
+type SearchPolicy int
+
+// Search policies
+const (
+	// SearchModern enforces no limits, like glibc 2.26 and later, this is
+	// the default
+	SearchModern SearchPolicy = iota
+	// SearchLegacy enforces the limit of 6 domains and 256 characters of
+	// older glibc versions
+	SearchLegacy
+	// SearchWarnOnly accepts search domains beyond the legacy limits with
+	// a warning
+	SearchWarnOnly
+)
+
+// SetSearchPolicy sets the limits of the search list, search domains
+// already added are kept. With ProfileNone no limits are enforced
+func (conf *Conf) SetSearchPolicy(p SearchPolicy) {
+	conf.search = p
+}
+
+// GetSearchPolicy returns the current search policy
+func (conf *Conf) GetSearchPolicy() SearchPolicy {
+	return conf.search
+}
+
 // SearchDomain is one of the items in the search list
 type SearchDomain struct {
 	Name string
 }
 
 // NewSearchDomain creates a new search domain that will be added
-// to the 'search' list in the generated file
+// to the 'search' list in the generated file. The name is checked when
+// added, NewSearchDomainStrict checks it right away
 func NewSearchDomain(dom string) *SearchDomain {
 	return &SearchDomain{dom}
 }
 
+// NewSearchDomainStrict is NewSearchDomain returning an error wrapping
+// ErrInvalidValue if dom isn't a valid domain name
+func NewSearchDomainStrict(dom string) (*SearchDomain, error) {
+	if err := checkName(dom); err != nil {
+		return nil, fmt.Errorf("%w: search domain %s", err, dom)
+	}
+	return NewSearchDomain(dom), nil
+}
+
+// AddSearchDomains adds the domains to the search list, surrounding
+// whitespace is ignored. Every domain is tried, the errors are accumulated
+// like by Add
+func (conf *Conf) AddSearchDomains(domains ...string) error {
+	items := make([]ConfItem, len(domains))
+	for i, dom := range domains {
+		items[i] = NewSearchDomain(strings.TrimSpace(dom))
+	}
+	return conf.Add(items...)
+}
+
 func (sd SearchDomain) applyLimits(conf *Conf) (bool, error) {
+	if err := checkName(sd.Name); err != nil {
+		return false, fmt.Errorf("%w: search domain %s", err, sd.Name)
+	}
 	// Search if conf search domain is already added
-	if conf.Find(sd) != nil {
-		return false, fmt.Errorf("Search domain %s already exists in conf", sd.Name)
+	if conf.duplicate(sd) {
+		if conf.dups == DuplicateIgnore {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: search domain %s", ErrDuplicateItem, sd.Name)
 	}
-	// Check max limit
-	doms := conf.GetSearchDomains()
-	if len(doms) == searchDomainMaxCount {
-		return false, fmt.Errorf("Too many search domains, %d is maximum", searchDomainMaxCount)
+	if !conf.limited() || conf.search == SearchModern {
+		return true, nil
 	}
-	// Check max char count limit
-	var charcount int
-	for _, str := range doms {
-		charcount += utf8.RuneCountInString(str.Name)
+	var reason string
+	count := conf.count(kindSearchDomain)
+	// Like glibc the bytes are counted with the spaces in between
+	charcount := conf.freshIndex().searchChars + count + len(sd.Name)
+	switch {
+	case count >= searchDomainMaxCount:
+		reason = fmt.Sprintf("too many search domains, %d is maximum", searchDomainMaxCount)
+	case charcount > searchDomainMaxCharCount:
+		reason = fmt.Sprintf("too many characters in search domain list, %d is maximum", searchDomainMaxCharCount)
+	default:
+		return true, nil
 	}
-	if charcount+utf8.RuneCountInString(sd.Name) > searchDomainMaxCharCount {
-		return false, fmt.Errorf("Too many charactes is search domain list, %d is maximum", searchDomainMaxCharCount)
+	if conf.search != SearchWarnOnly {
+		return false, fmt.Errorf("%w: %s", ErrLimitExceeded, reason)
 	}
-
+	msg := fmt.Sprintf("Search domain %s is beyond the legacy limits, %s", sd.Name, reason)
+	if conf.logger.enabled(LogWarn) {
+		conf.logWarn(msg, "op", "add", "item", sd.Name)
+	}
+	conf.warn(Warning{Code: WarnLimitExceeded, Item: &sd, Message: msg})
 	return true, nil
 }
 
@@ -42,6 +105,22 @@ func (sd SearchDomain) String() string {
 	return sd.Name
 }
 
+// MarshalText implements encoding.TextMarshaler
+func (sd SearchDomain) MarshalText() ([]byte, error) {
+	return []byte(sd.Name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, text is checked like
+// by NewSearchDomainStrict
+func (sd *SearchDomain) UnmarshalText(text []byte) error {
+	d, err := NewSearchDomainStrict(string(text))
+	if err != nil {
+		return err
+	}
+	*sd = *d
+	return nil
+}
+
 // Equal compares two search domains with each other, returns true if equal
 func (sd SearchDomain) Equal(b ConfItem) bool {
 	if item, ok := b.(*SearchDomain); ok {
@@ -49,3 +128,36 @@ func (sd SearchDomain) Equal(b ConfItem) bool {
 	}
 	return false
 }
+
+// EffectiveSearchDomains returns the search list the resolver uses. Like
+// glibc a domain line replaces the search list, so of the search domains
+// and the domain the ones added last win. Without either the domain of
+// hostname is used, e.g. lab.example for host.lab.example, there is none
+// if hostname has no dot
+func (conf *Conf) EffectiveSearchDomains(hostname string) []SearchDomain {
+	conf.rlock()
+	defer conf.runlock()
+	var sds []SearchDomain
+	fromDomain := false
+	for _, item := range conf.items {
+		switch it := item.(type) {
+		case *Domain:
+			if it.Name != "" {
+				sds, fromDomain = []SearchDomain{{Name: it.Name}}, true
+			}
+		case *SearchDomain:
+			if fromDomain {
+				sds, fromDomain = nil, false
+			}
+			sds = append(sds, *it)
+		}
+	}
+	if len(sds) == 0 {
+		if i := strings.IndexByte(hostname, '.'); i >= 0 {
+			if dom := strings.TrimSuffix(hostname[i+1:], "."); dom != "" {
+				sds = []SearchDomain{{Name: dom}}
+			}
+		}
+	}
+	return sds
+}
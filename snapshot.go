@@ -0,0 +1,80 @@
+package resolvconf
+
+// Snapshot is an immutable view of a Conf at one point in time, e.g. for a
+// resolver reading the nameservers on every query. It is safe to share
+// between goroutines, e.g. stored in an atomic.Value, and is not affected
+// by later changes to the Conf. The slices and the map returned are shared
+// by all readers and must not be modified
+type Snapshot struct {
+	nameservers   []Nameserver
+	searchDomains []SearchDomain
+	domain        Domain
+	options       map[string]Option
+	text          string
+}
+
+// Snapshot returns a Snapshot of the current items. It is cached until the
+// items change, so taking one per read is cheap while the Conf is not
+// changed. A Snapshot never sees part of an Add or Remove call
+func (conf *Conf) Snapshot() *Snapshot {
+	conf.rlock()
+	defer conf.runlock()
+	if s := conf.cache.loadSnapshot(); s != nil {
+		return s
+	}
+	s := &Snapshot{options: make(map[string]Option)}
+	for _, item := range conf.items {
+		switch it := item.(type) {
+		case *Nameserver:
+			ns := *it
+			ns.IP = cloneIP(it.IP)
+			s.nameservers = append(s.nameservers, ns)
+		case *SearchDomain:
+			s.searchDomains = append(s.searchDomains, *it)
+		case *Domain:
+			if s.domain.Name == "" {
+				s.domain = *it
+			}
+		case *Option:
+			// The last one wins, like for GetOption
+			s.options[it.Type] = *it
+		}
+	}
+	if b, err := conf.rendered(); err == nil {
+		s.text = string(b)
+	}
+	conf.cache.storeSnapshot(s)
+	return s
+}
+
+// Nameservers returns the nameservers in order, like GetNameservers
+func (s *Snapshot) Nameservers() []Nameserver {
+	return s.nameservers
+}
+
+// SearchDomains returns the search domains in order, like GetSearchDomains
+func (s *Snapshot) SearchDomains() []SearchDomain {
+	return s.searchDomains
+}
+
+// Domain returns the domain, the zero Domain if there is none
+func (s *Snapshot) Domain() Domain {
+	return s.domain
+}
+
+// Option returns the option of type name, e.g. ndots, and false if it is
+// not set
+func (s *Snapshot) Option(name string) (Option, bool) {
+	opt, ok := s.options[name]
+	return opt, ok
+}
+
+// Options returns the options by type
+func (s *Snapshot) Options() map[string]Option {
+	return s.options
+}
+
+// String returns the configuration as String of the Conf did
+func (s *Snapshot) String() string {
+	return s.text
+}
@@ -0,0 +1,75 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")), resolvconf.NewDomain("a.com"),
+		resolvconf.NewSearchDomain("b.com"), resolvconf.NewOption("ndots").Set(2), resolvconf.NewOption("rotate"))
+	s := conf.Snapshot()
+	assert.Equal(t, conf.GetNameservers(), s.Nameservers())
+	assert.Equal(t, conf.GetSearchDomains(), s.SearchDomains())
+	assert.Equal(t, "a.com", s.Domain().Name)
+	opt, ok := s.Option("ndots")
+	assert.True(t, ok)
+	assert.Equal(t, 2, opt.Get())
+	_, ok = s.Option("edns0")
+	assert.False(t, ok)
+	assert.Equal(t, 2, len(s.Options()))
+	assert.Equal(t, conf.String(), s.String())
+
+	// Cached until the items change
+	assert.True(t, s == conf.Snapshot())
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")), resolvconf.NewOption("ndots").Set(3))
+	s2 := conf.Snapshot()
+	assert.False(t, s == s2)
+	assert.Equal(t, 1, len(s.Nameservers()))
+	opt, _ = s.Option("ndots")
+	assert.Equal(t, 2, opt.Get())
+	assert.Equal(t, 2, len(s2.Nameservers()))
+	assert.Equal(t, conf.String(), s2.String())
+
+	// Not affected by items changed through Find
+	ns := conf.Find(resolvconf.NewNameserver(net.ParseIP("10.0.0.1"))).(*resolvconf.Nameserver)
+	ns.IP[len(ns.IP)-1] = 9
+	assert.Equal(t, "10.0.0.1", s2.Nameservers()[0].IP.String())
+	assert.Equal(t, "10.0.0.9", conf.Snapshot().Nameservers()[0].IP.String())
+}
+
+func TestSnapshotNeverSeesPartialChanges(t *testing.T) {
+	conf := resolvconf.New()
+	pair := func(i int) []resolvconf.SearchDomain {
+		return []resolvconf.SearchDomain{{Name: fmt.Sprintf("a%d.com", i)}, {Name: fmt.Sprintf("b%d.com", i)}}
+	}
+	conf.ReplaceSearchDomains(pair(0)...)
+	var current atomic.Value
+	current.Store(conf.Snapshot())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i < 200; i++ {
+			conf.ReplaceSearchDomains(pair(i)...)
+			current.Store(conf.Snapshot())
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				assert.Equal(t, 2, len(conf.Snapshot().SearchDomains()))
+				assert.Equal(t, 2, len(current.Load().(*resolvconf.Snapshot).SearchDomains()))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -3,6 +3,7 @@ package resolvconf
 import (
 	"fmt"
 	"net"
+	"strings"
 )
 
 // SortItem is one of the items in the sort list, it must have an address and
@@ -23,25 +24,113 @@ func NewSortItem(addr net.IP) *SortItem {
 	return slp
 }
 
+// NewSortItemFromCIDR creates a SortItem from CIDR notation, e.g.
+// 10.1.0.0/16, the prefix length is stored as netmask so it is written
+// as 10.1.0.0/255.255.0.0. An error wrapping ErrInvalidValue is returned
+// if cidr is malformed or the address has host bits set, see
+// NewSortItemFromCIDRMasked
+func NewSortItemFromCIDR(cidr string) (*SortItem, error) {
+	si, err := sortItemFromCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if si.Normalize() {
+		return nil, fmt.Errorf("%w: address %s has host bits set, network is %s", ErrInvalidValue, cidr, si.Address)
+	}
+	return si, nil
+}
+
+// NewSortItemFromCIDRMasked is NewSortItemFromCIDR masking off host bits,
+// e.g. 10.1.2.3/16 gives 10.1.0.0/255.255.0.0
+func NewSortItemFromCIDRMasked(cidr string) (*SortItem, error) {
+	si, err := sortItemFromCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	si.Normalize()
+	return si, nil
+}
+
+func sortItemFromCIDR(cidr string) (*SortItem, error) {
+	ip, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed CIDR %s in sortlist", ErrInvalidValue, cidr)
+	}
+	return &SortItem{Address: ip, Netmask: net.IP(ipnet.Mask).To16()}, nil
+}
+
+// CIDR returns the item in CIDR notation, e.g. 10.1.0.0/16. ok is false if
+// there is no netmask, the resolver then uses the classful one, or if the
+// netmask isn't a prefix of the address family
+func (si SortItem) CIDR() (cidr string, ok bool) {
+	if len(si.Netmask) == 0 || si.Address == nil {
+		return "", false
+	}
+	v4 := si.Address.To4() != nil
+	if v4 != (si.Netmask.To4() != nil) {
+		return "", false
+	}
+	mask := si.Netmask.To16()
+	if v4 {
+		mask = si.Netmask.To4()
+	}
+	ones, bits := net.IPMask(mask).Size()
+	if bits == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%d", si.Address, ones), true
+}
+
+// check returns an error wrapping ErrInvalidValue if the pair has no
+// address, or a netmask that isn't a prefix of the family of the address
+func (si SortItem) check() error {
+	if si.Address == nil {
+		return fmt.Errorf("%w: sortlist pair %s has no address", ErrInvalidValue, si)
+	}
+	if len(si.Netmask) > 0 {
+		if (si.Address.To4() != nil) != (si.Netmask.To4() != nil) {
+			return fmt.Errorf("%w: sortlist pair %s mixes address families", ErrInvalidValue, si)
+		}
+		if _, ok := si.CIDR(); !ok {
+			return fmt.Errorf("%w: sortlist pair %s has a non contiguous netmask", ErrInvalidValue, si)
+		}
+	}
+	return nil
+}
+
 func (si SortItem) applyLimits(conf *Conf) (bool, error) {
-	if i := conf.Find(si); i != nil {
-		// Check if netmask is different otherwise error
-		if si.Netmask.Equal(i.(*SortItem).Netmask) {
-			return false, fmt.Errorf("Sortlist pair %s already exists in conf", si)
+	if err := si.check(); err != nil {
+		return false, err
+	}
+	if si.Address.To4() == nil && conf.limited() {
+		// glibc only sorts IPv4 addresses
+		if conf.mode != LimitPermissive {
+			return false, fmt.Errorf("%w: sortlist pair %s is IPv6, glibc only sorts IPv4", ErrInvalidValue, si)
 		}
-		index := conf.indexOf(i)
-		conf.items[index].(*SortItem).Netmask = si.Netmask
+		msg := fmt.Sprintf("Sortlist pair %s is IPv6, glibc ignores it", si)
+		if conf.logger.enabled(LogWarn) {
+			conf.logWarn(msg, "op", "add", "item", si.String())
+		}
+		conf.warn(Warning{Code: WarnSortlistIPv6, Item: &si, Message: msg})
+	}
+	if conf.lookup(si) != nil {
+		return false, fmt.Errorf("%w: sortlist pair %s", ErrDuplicateItem, si)
 	}
-	if len(conf.GetSortItems()) == sortListMaxCount {
-		return false, fmt.Errorf("Too long sortlist, %d is maximum", sortListMaxCount)
+	if conf.limited() && conf.count(kindSortItem) == sortListMaxCount {
+		return false, fmt.Errorf("%w: too long sortlist, %d is maximum", ErrLimitExceeded, sortListMaxCount)
 	}
 	return true, nil
 }
 
-// Equal compares two SortItems, return true if equal
+// Equal compares two SortItems, return true if equal. Pairs with the same
+// address and different netmasks are different pairs, as is a pair without
+// netmask from one with
 func (si SortItem) Equal(b ConfItem) bool {
 	if item, ok := b.(*SortItem); ok {
-		return si.Address.String() == item.Address.String()
+		if !si.Address.Equal(item.Address) || (len(si.Netmask) == 0) != (len(item.Netmask) == 0) {
+			return false
+		}
+		return len(si.Netmask) == 0 || si.Netmask.Equal(item.Netmask)
 	}
 
 	return false
@@ -58,9 +147,58 @@ func (si SortItem) GetNetmask() net.IP {
 	return si.Netmask
 }
 
+// network returns the address masked with the netmask, e.g. the network the
+// resolver actually matches against. ok is false if there is no netmask or
+// if address and netmask are of different families
+func (si SortItem) network() (nw net.IP, ok bool) {
+	if len(si.Netmask) == 0 {
+		return nil, false
+	}
+	addr, mask := si.Address.To4(), si.Netmask.To4()
+	if (addr == nil) != (mask == nil) {
+		return nil, false
+	}
+	if addr == nil {
+		addr, mask = si.Address.To16(), si.Netmask.To16()
+	}
+	if addr == nil || mask == nil {
+		return nil, false
+	}
+	return addr.Mask(net.IPMask(mask)), true
+}
+
+// Normalize rewrites the address to the network covered by the netmask,
+// e.g. 10.1.2.3/255.255.255.0 becomes 10.1.2.0/255.255.255.0. Returns true
+// if the item was changed
+func (si *SortItem) Normalize() bool {
+	nw, ok := si.network()
+	if !ok || nw.Equal(si.Address) {
+		return false
+	}
+	si.Address = nw
+	return true
+}
+
 func (si SortItem) String() string {
 	if len(si.Netmask) > 0 {
 		return fmt.Sprintf("%s/%s", si.Address, si.Netmask)
 	}
 	return fmt.Sprintf("%s", si.Address)
 }
+
+// MarshalText implements encoding.TextMarshaler, the text is as written in
+// a resolv.conf file
+func (si SortItem) MarshalText() ([]byte, error) {
+	return []byte(si.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, text is an address
+// optionally followed by a slash and a netmask, e.g. 10.0.0.0/255.0.0.0
+func (si *SortItem) UnmarshalText(text []byte) error {
+	s, err := parseSortItem(string(text))
+	if err != nil {
+		return err
+	}
+	*si = *s
+	return nil
+}
@@ -0,0 +1,131 @@
+package resolvconf
+
+import (
+	"bytes"
+	"net"
+	"sort"
+)
+
+// Interface is the subset of a network interface needed to derive
+// sortlist pairs
+type Interface struct {
+	Name  string
+	Flags net.Flags
+	Addrs []net.Addr
+}
+
+// InterfaceLister returns the interfaces of the host
+type InterfaceLister func() ([]Interface, error)
+
+// SystemInterfaces lists the interfaces of the host using net.Interfaces
+func SystemInterfaces() ([]Interface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Interface, 0, len(ifs))
+	for _, i := range ifs {
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, Interface{i.Name, i.Flags, addrs})
+	}
+	return ret, nil
+}
+
+// SortlistOption customizes GenerateSortlist
+type SortlistOption func(o *sortlistOptions)
+
+type sortlistOptions struct {
+	lister InterfaceLister
+	filter func(name string) bool
+}
+
+// WithInterfaceLister replaces the function listing the interfaces
+func WithInterfaceLister(l InterfaceLister) SortlistOption {
+	return func(o *sortlistOptions) {
+		o.lister = l
+	}
+}
+
+// WithInterfaceFilter only uses interfaces for which keep returns true
+func WithInterfaceFilter(keep func(name string) bool) SortlistOption {
+	return func(o *sortlistOptions) {
+		o.filter = keep
+	}
+}
+
+// GenerateSortlist derives sortlist pairs from the IPv4 subnets of the
+// non loopback interfaces that are up. Networks covered by a larger one
+// are dropped, the rest are ordered with the longest prefixes, e.g. the
+// most specific networks, first and capped to the 10 pairs glibc reads
+func GenerateSortlist(opts ...SortlistOption) ([]SortItem, error) {
+	o := &sortlistOptions{lister: SystemInterfaces}
+	for _, opt := range opts {
+		opt(o)
+	}
+	ifs, err := o.lister()
+	if err != nil {
+		return nil, err
+	}
+
+	var nets []*net.IPNet
+	for _, i := range ifs {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if o.filter != nil && !o.filter(i.Name) {
+			continue
+		}
+		for _, addr := range i.Addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			mask := ipnet.Mask
+			if len(mask) == net.IPv6len {
+				mask = mask[12:]
+			}
+			nets = append(nets, &net.IPNet{IP: ipnet.IP.To4().Mask(mask), Mask: mask})
+		}
+	}
+
+	// Broadest networks first so that covered ones can be dropped
+	prefix := func(n *net.IPNet) int {
+		ones, _ := n.Mask.Size()
+		return ones
+	}
+	sort.SliceStable(nets, func(i, j int) bool {
+		return prefix(nets[i]) < prefix(nets[j])
+	})
+	var kept []*net.IPNet
+	for _, n := range nets {
+		covered := false
+		for _, k := range kept {
+			if k.Contains(n.IP) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, n)
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		if pi, pj := prefix(kept[i]), prefix(kept[j]); pi != pj {
+			return pi > pj
+		}
+		return bytes.Compare(kept[i].IP, kept[j].IP) < 0
+	})
+	if len(kept) > sortListMaxCount {
+		kept = kept[:sortListMaxCount]
+	}
+
+	ret := make([]SortItem, len(kept))
+	for i, n := range kept {
+		ret[i] = SortItem{Address: n.IP, Netmask: net.IP(n.Mask)}
+	}
+	return ret, nil
+}
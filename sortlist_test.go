@@ -0,0 +1,163 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func ipnet(t *testing.T, cidr string) net.Addr {
+	ip, n, err := net.ParseCIDR(cidr)
+	assert.Nil(t, err)
+	n.IP = ip
+	return n
+}
+
+func TestGenerateSortlist(t *testing.T) {
+	up := net.FlagUp | net.FlagBroadcast
+	lister := func() ([]resolvconf.Interface, error) {
+		return []resolvconf.Interface{
+			{"lo", up | net.FlagLoopback, []net.Addr{ipnet(t, "127.0.0.1/8")}},
+			{"eth0", up, []net.Addr{ipnet(t, "10.1.2.3/24"), ipnet(t, "2001:db8::1/64")}},
+			{"eth1", up, []net.Addr{ipnet(t, "10.1.0.5/16")}},
+			{"eth2", up, []net.Addr{ipnet(t, "192.168.1.7/24")}},
+			{"eth3", net.FlagBroadcast, []net.Addr{ipnet(t, "172.16.0.1/12")}},
+			{"docker0", up, []net.Addr{ipnet(t, "172.17.0.1/16")}},
+		}, nil
+	}
+	items, err := resolvconf.GenerateSortlist(resolvconf.WithInterfaceLister(lister),
+		resolvconf.WithInterfaceFilter(func(name string) bool { return name != "docker0" }))
+	assert.Nil(t, err)
+	var strs []string
+	for _, si := range items {
+		strs = append(strs, si.String())
+	}
+	assert.Equal(t, []string{"192.168.1.0/255.255.255.0", "10.1.0.0/255.255.0.0"}, strs)
+
+	conf := resolvconf.New()
+	for i := range items {
+		assert.Nil(t, conf.Add(&items[i]))
+	}
+}
+
+func TestGenerateSortlistCap(t *testing.T) {
+	lister := func() ([]resolvconf.Interface, error) {
+		var ifs []resolvconf.Interface
+		for i := 0; i < 12; i++ {
+			ifs = append(ifs, resolvconf.Interface{"eth" + strconv.Itoa(i), net.FlagUp,
+				[]net.Addr{ipnet(t, "10."+strconv.Itoa(i)+".0.1/16")}})
+		}
+		return ifs, nil
+	}
+	items, err := resolvconf.GenerateSortlist(resolvconf.WithInterfaceLister(lister))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(items))
+}
+
+func TestGenerateSortlistListerError(t *testing.T) {
+	_, err := resolvconf.GenerateSortlist(resolvconf.WithInterfaceLister(func() ([]resolvconf.Interface, error) {
+		return nil, errors.New("boom")
+	}))
+	assert.NotNil(t, err)
+}
+
+func TestNewSortItemFromCIDR(t *testing.T) {
+	si, err := resolvconf.NewSortItemFromCIDR("10.1.0.0/16")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.1.0.0/255.255.0.0", si.String())
+	cidr, ok := si.CIDR()
+	assert.True(t, ok)
+	assert.Equal(t, "10.1.0.0/16", cidr)
+	// Same item as read from a file
+	parsed := resolvconf.NewSortItem(net.ParseIP("10.1.0.0")).SetNetmask(net.ParseIP("255.255.0.0"))
+	assert.True(t, si.Equal(parsed))
+
+	si, err = resolvconf.NewSortItemFromCIDR("2001:db8::/32")
+	assert.Nil(t, err)
+	cidr, ok = si.CIDR()
+	assert.True(t, ok)
+	assert.Equal(t, "2001:db8::/32", cidr)
+
+	for _, s := range []string{"10.1.2.3/16", "10.1.0.0", "10.1.0.0/33", "foo/8"} {
+		_, err := resolvconf.NewSortItemFromCIDR(s)
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), s)
+	}
+
+	si, err = resolvconf.NewSortItemFromCIDRMasked("10.1.2.3/16")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.1.0.0/255.255.0.0", si.String())
+	_, err = resolvconf.NewSortItemFromCIDRMasked("10.1.2.3")
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+}
+
+func TestSortItemCIDR(t *testing.T) {
+	for _, tc := range []struct {
+		addr, mask string
+		cidr       string
+	}{
+		{"10.0.0.0", "255.0.0.0", "10.0.0.0/8"},
+		{"10.0.0.0", "0.0.0.0", "10.0.0.0/0"},
+		{"130.155.160.0", "255.255.240.0", "130.155.160.0/20"},
+		{"10.0.0.0", "255.0.255.0", ""},
+		{"10.0.0.0", "ffff::", ""},
+		{"2001:db8::", "255.255.0.0", ""},
+		{"10.0.0.0", "", ""},
+	} {
+		si := resolvconf.NewSortItem(net.ParseIP(tc.addr)).SetNetmask(net.ParseIP(tc.mask))
+		cidr, ok := si.CIDR()
+		assert.Equal(t, tc.cidr != "", ok, tc.addr+"/"+tc.mask)
+		assert.Equal(t, tc.cidr, cidr)
+	}
+}
+
+func TestSortItemValidation(t *testing.T) {
+	conf := resolvconf.New()
+	for _, si := range []*resolvconf.SortItem{
+		resolvconf.NewSortItem(nil),
+		resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("ffff::")),
+		resolvconf.NewSortItem(net.ParseIP("2001:db8::")).SetNetmask(net.ParseIP("255.255.0.0")),
+		resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.0.255.0")),
+		resolvconf.NewSortItem(net.ParseIP("2001:db8::")).SetNetmask(net.ParseIP("ffff:ffff::")),
+		resolvconf.NewSortItem(net.ParseIP("2001:db8::1")),
+	} {
+		err := conf.Add(si)
+		assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue), si.String())
+	}
+	assert.Empty(t, conf.GetSortItems())
+
+	// IPv6 pairs are accepted without glibc limits, and with a warning by
+	// LimitPermissive
+	v6 := resolvconf.NewSortItem(net.ParseIP("2001:db8::")).SetNetmask(net.ParseIP("ffff:ffff::"))
+	assert.Nil(t, resolvconf.New(resolvconf.WithProfile(resolvconf.ProfileNone)).Add(v6))
+	conf.SetLimitMode(resolvconf.LimitPermissive)
+	assert.Nil(t, conf.Add(v6))
+	ws := conf.Warnings()
+	assert.Equal(t, 1, len(ws))
+	assert.Equal(t, resolvconf.WarnSortlistIPv6, ws[0].Code)
+	// The family must still match
+	err := conf.Add(resolvconf.NewSortItem(net.ParseIP("2001:db8::")).SetNetmask(net.ParseIP("255.255.0.0")))
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+}
+
+func TestSortItemValidationAtLimit(t *testing.T) {
+	conf := resolvconf.New()
+	bad := resolvconf.NewSortItem(net.ParseIP("10.0.0.0")).SetNetmask(net.ParseIP("255.0.255.0"))
+	for i := 0; i < 9; i++ {
+		assert.Nil(t, conf.Add(resolvconf.NewSortItem(net.IPv4(10, byte(i), 0, 0)).SetNetmask(net.ParseIP("255.255.0.0"))))
+		// Rejected pairs don't take a place in the sortlist
+		assert.True(t, errors.Is(conf.Add(bad), resolvconf.ErrInvalidValue))
+	}
+	assert.Nil(t, conf.Add(resolvconf.NewSortItem(net.IPv4(10, 9, 0, 0))))
+	assert.Equal(t, 10, len(conf.GetSortItems()))
+
+	err := conf.Add(resolvconf.NewSortItem(net.IPv4(10, 10, 0, 0)))
+	assert.True(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+	// An invalid pair is reported as such, not as beyond the limit
+	err = conf.Add(bad)
+	assert.True(t, errors.Is(err, resolvconf.ErrInvalidValue))
+	assert.False(t, errors.Is(err, resolvconf.ErrLimitExceeded))
+	assert.Equal(t, 10, len(conf.GetSortItems()))
+}
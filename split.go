@@ -0,0 +1,207 @@
+package resolvconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// SplitRoute sends queries for Suffix and its subdomains to the
+// nameservers of Source. The default route has an empty Suffix
+type SplitRoute struct {
+	Suffix      string
+	Source      string
+	Nameservers []Nameserver
+}
+
+// SplitPlan is a split DNS routing table, see BuildSplitDNS
+type SplitPlan struct {
+	Routes  []SplitRoute // Sorted by suffix
+	Default SplitRoute
+}
+
+// SplitConflictError is returned by BuildSplitDNS when several sources
+// claim the same suffix with different nameservers
+type SplitConflictError struct {
+	Suffix  string
+	Sources []string
+}
+
+func (e *SplitConflictError) Error() string {
+	return fmt.Sprintf("Suffix %s is claimed by %s with different nameservers",
+		e.Suffix, strings.Join(e.Sources, " and "))
+}
+
+// BuildSplitDNS builds a split DNS plan from named configurations, e.g.
+// one per interface. The domain and search domains of each source are the
+// suffixes routed to its nameservers, sources without nameservers are
+// ignored.
+//
+// The default route goes to the source claiming the fewest suffixes, with
+// ties broken by source name, so a LAN without search domains wins over a
+// VPN pushing its corporate domains. A suffix claimed by several sources
+// with the same nameservers is routed to the first by name, with
+// different nameservers a *SplitConflictError is returned
+func BuildSplitDNS(sources map[string]*Conf) (SplitPlan, error) {
+	var plan SplitPlan
+	names := make([]string, 0, len(sources))
+	for name, conf := range sources {
+		if conf != nil && len(conf.GetNameservers()) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return plan, fmt.Errorf("No source has nameservers")
+	}
+	sort.Strings(names)
+
+	claims := make(map[string][]string)
+	routes := make(map[string]SplitRoute)
+	for _, name := range names {
+		for _, suffix := range splitSuffixes(sources[name]) {
+			claims[name] = append(claims[name], suffix)
+			route := SplitRoute{suffix, name, sources[name].GetNameservers()}
+			prev, ok := routes[suffix]
+			if !ok {
+				routes[suffix] = route
+				continue
+			}
+			if !sameNameservers(prev.Nameservers, route.Nameservers) {
+				return plan, &SplitConflictError{suffix, []string{prev.Source, name}}
+			}
+		}
+	}
+
+	def := names[0]
+	for _, name := range names[1:] {
+		if len(claims[name]) < len(claims[def]) {
+			def = name
+		}
+	}
+	plan.Default = SplitRoute{Source: def, Nameservers: sources[def].GetNameservers()}
+	if l := packageLogger(); l.enabled(LogDebug) {
+		l.l.Debug(fmt.Sprintf("Default route from %s, it claims %d of the suffixes", def, len(claims[def])),
+			"op", "split", "source", def, "suffixes", len(claims[def]))
+	}
+	for _, route := range routes {
+		plan.Routes = append(plan.Routes, route)
+	}
+	sort.Slice(plan.Routes, func(i, j int) bool {
+		return plan.Routes[i].Suffix < plan.Routes[j].Suffix
+	})
+	return plan, nil
+}
+
+// splitSuffixes returns the normalized, unique domain and search suffixes
+// of conf
+func splitSuffixes(conf *Conf) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		s = strings.ToLower(strings.Trim(s, "."))
+		if s != "" && !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+	add(conf.GetDomain().Name)
+	for _, dom := range conf.GetSearchDomains() {
+		add(dom.Name)
+	}
+	return ret
+}
+
+func sameNameservers(a, b []Nameserver) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(&b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvedAddr renders a nameserver the way resolvectl takes it
+func resolvedAddr(ns Nameserver) string {
+	if ns.Port != 0 && ns.Port != DefaultPort {
+		return ns.Addr()
+	}
+	return ns.IP.String()
+}
+
+func joinNameservers(nss []Nameserver, sep string) string {
+	strs := make([]string, len(nss))
+	for i, ns := range nss {
+		strs[i] = resolvedAddr(ns)
+	}
+	return strings.Join(strs, sep)
+}
+
+// Table renders the plan as a human readable table, the default route
+// last
+func (p SplitPlan) Table() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SUFFIX\tSOURCE\tNAMESERVERS")
+	for _, r := range p.Routes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Suffix, r.Source, joinNameservers(r.Nameservers, ", "))
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "(default)", p.Default.Source, joinNameservers(p.Default.Nameservers, ", "))
+	w.Flush()
+	return b.String()
+}
+
+// ResolvedLink is the systemd-resolved configuration of one link
+type ResolvedLink struct {
+	Link         string
+	DNS          []string
+	Domains      []string // Routing only domains, prefixed by ~
+	DefaultRoute bool
+}
+
+// ResolvedLinks returns the per link systemd-resolved configuration of the
+// plan, taking source names as link names. Links are sorted by name
+func (p SplitPlan) ResolvedLinks() []ResolvedLink {
+	links := make(map[string]*ResolvedLink)
+	link := func(r SplitRoute) *ResolvedLink {
+		l, ok := links[r.Source]
+		if !ok {
+			l = &ResolvedLink{Link: r.Source}
+			for _, ns := range r.Nameservers {
+				l.DNS = append(l.DNS, resolvedAddr(ns))
+			}
+			links[r.Source] = l
+		}
+		return l
+	}
+	for _, r := range p.Routes {
+		l := link(r)
+		l.Domains = append(l.Domains, "~"+r.Suffix)
+	}
+	if p.Default.Source != "" {
+		l := link(p.Default)
+		l.DefaultRoute = true
+		l.Domains = append(l.Domains, "~.")
+	}
+
+	ret := make([]ResolvedLink, 0, len(links))
+	for _, l := range links {
+		ret = append(ret, *l)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Link < ret[j].Link })
+	return ret
+}
+
+// Resolvectl renders the plan as resolvectl commands applying it
+func (p SplitPlan) Resolvectl() string {
+	var b strings.Builder
+	for _, l := range p.ResolvedLinks() {
+		fmt.Fprintf(&b, "resolvectl dns %s %s\n", l.Link, strings.Join(l.DNS, " "))
+		fmt.Fprintf(&b, "resolvectl domain %s %s\n", l.Link, strings.Join(l.Domains, " "))
+		fmt.Fprintf(&b, "resolvectl default-route %s %t\n", l.Link, l.DefaultRoute)
+	}
+	return b.String()
+}
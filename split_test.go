@@ -0,0 +1,63 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func splitSources(t *testing.T) map[string]*resolvconf.Conf {
+	lan, err := resolvconf.ReadConf(strings.NewReader("nameserver 192.168.1.1\n"))
+	assert.Nil(t, err)
+	vpn, err := resolvconf.ReadConf(strings.NewReader("domain corp.example\nnameserver 10.8.0.1\nnameserver [10.8.0.2]:5353\nsearch corp.example lab.corp.example\n"))
+	assert.Nil(t, err)
+	down, err := resolvconf.ReadConf(strings.NewReader("search down.example\n"))
+	assert.Nil(t, err)
+	return map[string]*resolvconf.Conf{"wlan0": lan, "tun0": vpn, "eth1": down}
+}
+
+func TestBuildSplitDNS(t *testing.T) {
+	plan, err := resolvconf.BuildSplitDNS(splitSources(t))
+	assert.Nil(t, err)
+	assert.Equal(t, "wlan0", plan.Default.Source)
+	assert.Equal(t, 2, len(plan.Routes))
+	assert.Equal(t, "corp.example", plan.Routes[0].Suffix)
+	assert.Equal(t, "lab.corp.example", plan.Routes[1].Suffix)
+	assert.Equal(t, "tun0", plan.Routes[1].Source)
+
+	assert.Equal(t, "SUFFIX            SOURCE  NAMESERVERS\n"+
+		"corp.example      tun0    10.8.0.1, 10.8.0.2:5353\n"+
+		"lab.corp.example  tun0    10.8.0.1, 10.8.0.2:5353\n"+
+		"(default)         wlan0   192.168.1.1\n", plan.Table())
+
+	assert.Equal(t, "resolvectl dns tun0 10.8.0.1 10.8.0.2:5353\n"+
+		"resolvectl domain tun0 ~corp.example ~lab.corp.example\n"+
+		"resolvectl default-route tun0 false\n"+
+		"resolvectl dns wlan0 192.168.1.1\n"+
+		"resolvectl domain wlan0 ~.\n"+
+		"resolvectl default-route wlan0 true\n", plan.Resolvectl())
+}
+
+func TestBuildSplitDNSConflict(t *testing.T) {
+	sources := splitSources(t)
+	other, _ := resolvconf.ReadConf(strings.NewReader("nameserver 172.16.0.1\nsearch corp.example.\n"))
+	sources["tun1"] = other
+	_, err := resolvconf.BuildSplitDNS(sources)
+	var conflict *resolvconf.SplitConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "corp.example", conflict.Suffix)
+	assert.Equal(t, []string{"tun0", "tun1"}, conflict.Sources)
+
+	// Same servers is not a conflict
+	same, _ := resolvconf.ReadConf(strings.NewReader("nameserver 10.8.0.1\nnameserver [10.8.0.2]:5353\nsearch corp.example\n"))
+	sources["tun1"] = same
+	_, err = resolvconf.BuildSplitDNS(sources)
+	assert.Nil(t, err)
+}
+
+func TestBuildSplitDNSNoNameservers(t *testing.T) {
+	_, err := resolvconf.BuildSplitDNS(map[string]*resolvconf.Conf{"eth0": resolvconf.New()})
+	assert.NotNil(t, err)
+}
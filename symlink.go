@@ -0,0 +1,83 @@
+package resolvconf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrSymlink is matched, using errors.Is, by errors returned when the file
+// to write is a symlink and neither ReplaceSymlink nor FollowSymlink is
+// given
+var ErrSymlink = errors.New("File is a symlink")
+
+// SymlinkError is returned by WriteFile when path is a symlink, e.g. to the
+// stub file of systemd-resolved
+type SymlinkError struct {
+	Path   string
+	Target string // As read from the link
+}
+
+func (e *SymlinkError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s", ErrSymlink, e.Path, e.Target)
+}
+
+// Is makes errors.Is(err, ErrSymlink) true
+func (e *SymlinkError) Is(target error) bool {
+	return target == ErrSymlink
+}
+
+type symlinkPolicy int
+
+const (
+	symlinkRefuse symlinkPolicy = iota
+	symlinkReplace
+	symlinkFollow
+)
+
+// ReplaceSymlink makes WriteFile replace a symlink at path with a regular
+// file, the file it points to is left alone
+func ReplaceSymlink() WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.symlinks = symlinkReplace
+	})
+}
+
+// FollowSymlink makes WriteFile write the file a symlink at path points
+// to, the symlink is kept. The file is created if it doesn't exist
+func FollowSymlink() WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.symlinks = symlinkFollow
+	})
+}
+
+// followLinks returns the path the symlink at p finally points to, the
+// file there may not exist. Paths are relative to the root
+func (o *fileOptions) followLinks(p string) (string, error) {
+	for links := 0; ; links++ {
+		fi, err := o.fs.Lstat(o.path(p))
+		if os.IsNotExist(err) || (err == nil && fi.Mode()&os.ModeSymlink == 0) {
+			return p, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if links == maxSymlinks {
+			return "", fmt.Errorf("Too many levels of symbolic links resolving %s", p)
+		}
+		target, err := o.fs.Readlink(o.path(p))
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(p), target)
+		}
+		// The directories on the way may be symlinks too
+		dir, err := o.resolve(filepath.Dir(target))
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(dir, filepath.Base(target))
+	}
+}
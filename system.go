@@ -0,0 +1,141 @@
+package resolvconf
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Mode classifies how a resolv.conf file is managed
+type Mode int
+
+// Modes
+const (
+	ModeStatic  Mode = iota // Regular file, not a symlink
+	ModeStub                // Symlink to the systemd-resolved stub listener file
+	ModeUplink              // Symlink to the systemd-resolved upstream servers file
+	ModeForeign             // Symlink to a file managed by some other tool
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeStatic:
+		return "static"
+	case ModeStub:
+		return "stub"
+	case ModeUplink:
+		return "uplink"
+	case ModeForeign:
+		return "foreign"
+	}
+	return fmt.Sprintf("mode(%d)", int(m))
+}
+
+// Well known systemd-resolved paths
+const (
+	SystemdStubPath   = "/run/systemd/resolve/stub-resolv.conf"
+	SystemdUplinkPath = "/run/systemd/resolve/resolv.conf"
+)
+
+// Files belonging to systemd-resolved, /var/run is a symlink to /run on
+// most systems but older ones have it the other way around
+var systemdModes = map[string]Mode{
+	SystemdStubPath: ModeStub,
+	"/var/run/systemd/resolve/stub-resolv.conf": ModeStub,
+	"/usr/lib/systemd/resolv.conf":              ModeStub,
+	"/lib/systemd/resolv.conf":                  ModeStub,
+	SystemdUplinkPath:                           ModeUplink,
+	"/var/run/systemd/resolve/resolv.conf":      ModeUplink,
+}
+
+// stubAddrs are the addresses of the systemd-resolved stub listeners, the
+// second one doesn't forward to the local DNSSEC and LLMNR logic
+var stubAddrs = []net.IP{net.IPv4(127, 0, 0, 53), net.IPv4(127, 0, 0, 54)}
+
+// IsStubResolver returns true if the Conf has nameservers and all of them
+// are stub listeners of systemd-resolved, so they tell nothing about the
+// upstream servers
+func (conf *Conf) IsStubResolver() bool {
+	nss := conf.GetNameservers()
+	for _, ns := range nss {
+		if ns.Port != 0 && ns.Port != DefaultPort {
+			return false
+		}
+		stub := false
+		for _, ip := range stubAddrs {
+			stub = stub || ns.IP.Equal(ip)
+		}
+		if !stub {
+			return false
+		}
+	}
+	return len(nss) > 0
+}
+
+// maxSymlinks is the maximum number of symlinks followed, same as the
+// Linux kernel
+const maxSymlinks = 40
+
+// SystemInfo describes the setup behind a resolv.conf path
+type SystemInfo struct {
+	Path         string   // Path that was resolved
+	Chain        []string // Every path visited, starting with Path and ending with LibcPath
+	LibcPath     string   // File actually read by libc
+	UpstreamPath string   // File containing the real upstream nameservers
+	Mode         Mode
+}
+
+// ResolveSystemPath follows the symlink chain starting at path, typically
+// /etc/resolv.conf, and classifies the setup. For a systemd-resolved stub
+// setup UpstreamPath points to the file listing the servers resolved
+// forwards to, in all other cases it is the same as LibcPath.
+//
+// With RootDir symlinks are resolved relative to the root and all returned
+// paths are relative to it
+func ResolveSystemPath(path string, opts ...FileOption) (SystemInfo, error) {
+	o := newFileOptions(opts)
+	info := SystemInfo{Path: path, Mode: ModeStatic}
+	cur := filepath.Clean(path)
+	for {
+		// Directories may be symlinks too, only the last component is
+		// part of the chain
+		dir, err := o.resolve(filepath.Dir(cur))
+		if err != nil {
+			return info, err
+		}
+		cur = filepath.Join(dir, filepath.Base(cur))
+		info.Chain = append(info.Chain, cur)
+		fi, err := o.fs.Lstat(o.path(cur))
+		if err != nil {
+			return info, err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		if len(info.Chain) > maxSymlinks {
+			return info, fmt.Errorf("Too many levels of symbolic links resolving %s", path)
+		}
+		target, err := o.fs.Readlink(o.path(cur))
+		if err != nil {
+			return info, err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(cur), target)
+		}
+		cur = filepath.Clean(target)
+		info.Mode = ModeForeign
+	}
+
+	info.LibcPath = cur
+	info.UpstreamPath = cur
+	if len(info.Chain) > 1 {
+		if m, ok := systemdModes[cur]; ok {
+			info.Mode = m
+		}
+	}
+	if info.Mode == ModeStub {
+		info.UpstreamPath = SystemdUplinkPath
+	}
+	return info, nil
+}
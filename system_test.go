@@ -0,0 +1,134 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveStaticFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	ioutil.WriteFile(path, []byte("nameserver 8.8.8.8\n"), 0644)
+
+	info, err := resolvconf.ResolveSystemPath(path)
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ModeStatic, info.Mode)
+	assert.Equal(t, path, info.LibcPath)
+	assert.Equal(t, path, info.UpstreamPath)
+	assert.Equal(t, []string{path}, info.Chain)
+}
+
+func TestResolveForeignSymlinkChain(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	real := filepath.Join(dir, "run", "resolv.conf")
+	os.MkdirAll(filepath.Dir(real), 0755)
+	ioutil.WriteFile(real, []byte("nameserver 8.8.8.8\n"), 0644)
+	os.Symlink("run/resolv.conf", filepath.Join(dir, "middle"))
+	os.Symlink(filepath.Join(dir, "middle"), filepath.Join(dir, "resolv.conf"))
+
+	info, err := resolvconf.ResolveSystemPath(filepath.Join(dir, "resolv.conf"))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ModeForeign, info.Mode)
+	assert.Equal(t, real, info.LibcPath)
+	assert.Equal(t, real, info.UpstreamPath)
+	assert.Equal(t, 3, len(info.Chain))
+}
+
+func TestResolveSymlinkLoop(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	os.Symlink("b", filepath.Join(dir, "a"))
+	os.Symlink("a", filepath.Join(dir, "b"))
+
+	_, err := resolvconf.ResolveSystemPath(filepath.Join(dir, "a"))
+	assert.NotNil(t, err)
+}
+
+func TestResolveMissingFile(t *testing.T) {
+	_, err := resolvconf.ResolveSystemPath("/nonexistent/resolv.conf")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestResolveInRootDir(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"/run/systemd/resolve/stub-resolv.conf": "nameserver 127.0.0.53\n",
+	})
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	// Absolute targets must be resolved inside the root, /var/run -> /run
+	// checks that directories are too
+	os.MkdirAll(filepath.Join(root, "var"), 0755)
+	os.Symlink("/run", filepath.Join(root, "var/run"))
+	os.Symlink("/var/run/systemd/resolve/stub-resolv.conf", filepath.Join(root, "etc/resolv.conf"))
+
+	info, err := resolvconf.ResolveSystemPath("/etc/resolv.conf", resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ModeStub, info.Mode)
+	assert.Equal(t, []string{"/etc/resolv.conf", resolvconf.SystemdStubPath}, info.Chain)
+	assert.Equal(t, resolvconf.SystemdUplinkPath, info.UpstreamPath)
+
+	conf, err := resolvconf.ReadConfFile("/etc/resolv.conf", resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.53", conf.GetNameservers()[0].String())
+
+	m, err := resolvconf.DetectManager("/etc/resolv.conf", resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, resolvconf.ManagerSystemdResolved, m)
+}
+
+func TestResolveInRootDirCannotEscape(t *testing.T) {
+	root := fakeRoot(t, map[string]string{"/resolv.conf": "nameserver 10.0.0.1\n"})
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.Symlink("../../../../../resolv.conf", filepath.Join(root, "etc/resolv.conf"))
+
+	info, err := resolvconf.ResolveSystemPath("/etc/resolv.conf", resolvconf.RootDir(root))
+	assert.Nil(t, err)
+	assert.Equal(t, "/resolv.conf", info.LibcPath)
+}
+
+func TestIsStubResolver(t *testing.T) {
+	conf := resolvconf.New()
+	assert.False(t, conf.IsStubResolver())
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("127.0.0.53")), resolvconf.NewNameserver(net.ParseIP("127.0.0.54")))
+	assert.True(t, conf.IsStubResolver())
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.1")))
+	assert.False(t, conf.IsStubResolver())
+
+	conf = resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("127.0.0.53")).SetPort(5353))
+	assert.False(t, conf.IsStubResolver())
+}
+
+func TestReadSystemConfFollowStub(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile(resolvconf.SystemdStubPath, []byte("nameserver 127.0.0.53\noptions edns0\n"), 0644))
+	assert.Nil(t, fsys.WriteFile(resolvconf.SystemdUplinkPath, []byte("nameserver 10.0.0.1\n"), 0644))
+	assert.Nil(t, fsys.Symlink("../run/systemd/resolve/stub-resolv.conf", "/etc/resolv.conf"))
+
+	conf, err := resolvconf.ReadSystemConf(resolvconf.WithFileSystem(fsys))
+	assert.Nil(t, err)
+	assert.True(t, conf.IsStubResolver())
+	assert.Equal(t, resolvconf.SystemdStubPath, conf.ParsedPath())
+
+	conf, err = resolvconf.ReadSystemConf(resolvconf.WithFileSystem(fsys), resolvconf.FollowStub())
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", conf.GetNameservers()[0].String())
+	assert.Equal(t, resolvconf.SystemdUplinkPath, conf.ParsedPath())
+	assert.Equal(t, resolvconf.SystemdUplinkPath, conf.Source())
+
+	// Without the upstream file the stub is all there is
+	assert.Nil(t, fsys.Remove(resolvconf.SystemdUplinkPath))
+	conf, err = resolvconf.ReadSystemConf(resolvconf.WithFileSystem(fsys), resolvconf.FollowStub())
+	assert.Nil(t, err)
+	assert.True(t, conf.IsStubResolver())
+	assert.Equal(t, resolvconf.SystemdStubPath, conf.ParsedPath())
+	assert.Equal(t, "/etc/resolv.conf", conf.Source())
+}
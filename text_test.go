@@ -0,0 +1,75 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"encoding"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestItemTextRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		item interface {
+			encoding.TextMarshaler
+			encoding.TextUnmarshaler
+		}
+	}{
+		{"8.8.8.8", new(resolvconf.Nameserver)},
+		{"[2001:db8::1]:5353", new(resolvconf.Nameserver)},
+		{"fe80::1%eth0", new(resolvconf.Nameserver)},
+		{"example.com", new(resolvconf.Domain)},
+		{"lab.example.com", new(resolvconf.SearchDomain)},
+		{"10.0.0.0/255.0.0.0", new(resolvconf.SortItem)},
+		{"10.0.0.0", new(resolvconf.SortItem)},
+		{"ndots:3", new(resolvconf.Option)},
+		{"rotate", new(resolvconf.Option)},
+	} {
+		assert.Nil(t, tc.item.UnmarshalText([]byte(tc.text)), tc.text)
+		b, err := tc.item.MarshalText()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.text, string(b))
+	}
+}
+
+func TestItemUnmarshalTextFails(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		item encoding.TextUnmarshaler
+		want error
+	}{
+		{"8.8.8", new(resolvconf.Nameserver), resolvconf.ErrInvalidValue},
+		{"0.0.0.0", new(resolvconf.Nameserver), resolvconf.ErrInvalidValue},
+		{"foo bar", new(resolvconf.Domain), resolvconf.ErrInvalidValue},
+		{"-foo.com", new(resolvconf.SearchDomain), resolvconf.ErrInvalidValue},
+		{"10.0.0.0/255.0", new(resolvconf.SortItem), resolvconf.ErrInvalidValue},
+		{"foo", new(resolvconf.Option), resolvconf.ErrUnknownOption},
+		{"ndots:x", new(resolvconf.Option), resolvconf.ErrInvalidValue},
+		{"ndots", new(resolvconf.Option), resolvconf.ErrInvalidValue},
+	} {
+		err := tc.item.UnmarshalText([]byte(tc.text))
+		assert.True(t, errors.Is(err, tc.want), "%s: %v", tc.text, err)
+	}
+	opt := resolvconf.NewOption("ndots").Set(2)
+	assert.NotNil(t, opt.UnmarshalText([]byte("bogus")))
+	assert.Equal(t, 2, opt.Value)
+}
+
+func TestItemsInStructs(t *testing.T) {
+	var cfg struct {
+		Servers []resolvconf.Nameserver `json:"servers"`
+		Domain  resolvconf.Domain       `json:"domain"`
+		Options []resolvconf.Option     `json:"options"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(`{"servers":["10.0.0.1","::1"],"domain":"example.com","options":["ndots:2"]}`), &cfg))
+	assert.Equal(t, "::1", cfg.Servers[1].String())
+	assert.Equal(t, "example.com", cfg.Domain.Name)
+	assert.Equal(t, 2, cfg.Options[0].Value)
+
+	b, err := json.Marshal(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"servers":["10.0.0.1","::1"],"domain":"example.com","options":["ndots:2"]}`, string(b))
+	assert.NotNil(t, json.Unmarshal([]byte(`{"servers":["nope"]}`), &cfg))
+}
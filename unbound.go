@@ -0,0 +1,36 @@
+package resolvconf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToUnboundForward renders an Unbound forward-zone clause for zone, the
+// root zone if empty, forwarding to the nameservers of the configuration.
+// Ports other than 53 are rendered as addr@port. An error is returned if
+// there are no nameservers as unbound rejects an empty forward-zone
+func (conf *Conf) ToUnboundForward(zone string) (string, error) {
+	nss := conf.GetNameservers()
+	if len(nss) == 0 {
+		return "", fmt.Errorf("No nameservers to forward to")
+	}
+	if zone == "" {
+		zone = "."
+	}
+	if strings.ContainsAny(zone, "\" \t\n") {
+		return "", fmt.Errorf("Malformed zone %q", zone)
+	}
+
+	var b strings.Builder
+	b.WriteString("forward-zone:\n")
+	fmt.Fprintf(&b, "\tname: \"%s\"\n", zone)
+	for _, ns := range nss {
+		addr := ns.IP.String()
+		if ns.Port != 0 && ns.Port != DefaultPort {
+			addr += "@" + strconv.Itoa(ns.Port)
+		}
+		fmt.Fprintf(&b, "\tforward-addr: %s\n", addr)
+	}
+	return b.String(), nil
+}
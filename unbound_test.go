@@ -0,0 +1,26 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestToUnboundForward(t *testing.T) {
+	out, err := exportConf(t).ToUnboundForward("")
+	assert.Nil(t, err)
+	assert.Equal(t, "forward-zone:\n\tname: \".\"\n"+
+		"\tforward-addr: 10.0.0.1\n\tforward-addr: 2001:db8::1@5353\n", out)
+
+	out, err = exportConf(t).ToUnboundForward("corp.example.")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "\tname: \"corp.example.\"\n")
+
+	_, err = exportConf(t).ToUnboundForward("bad zone")
+	assert.NotNil(t, err)
+}
+
+func TestToUnboundForwardNoNameservers(t *testing.T) {
+	_, err := resolvconf.New().ToUnboundForward("")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,240 @@
+package resolvconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Policy is a set of optional rules that Validate enforces on top of the
+// limits checked when items are added
+type Policy int
+
+// Policies, may be combined
+const (
+	// RequireNameserver makes Validate report an error when no nameserver
+	// would be written
+	RequireNameserver Policy = 1 << iota
+	// RequireSearchOrDomain makes Validate report an error when neither
+	// a domain nor any search domains would be written
+	RequireSearchOrDomain
+)
+
+// Severity tells how serious an Issue is
+type Severity int
+
+// Severities
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// MarshalText makes Severity serialize as its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Issue codes
+const (
+	IssueEmptyConf           = "empty-conf"
+	IssueNoNameserver        = "no-nameserver"
+	IssueNameserversFiltered = "nameservers-filtered"
+	IssueNoSearchOrDomain    = "no-search-or-domain"
+	IssueSortlistHostBits    = "sortlist-host-bits"
+)
+
+// Issue is a single finding from Validate or Lint
+type Issue struct {
+	Code     string
+	Severity Severity
+	Message  string
+	Line     int      // Line in the source file, 0 if unknown
+	Column   int      // Column in the line, 0 if unknown or about the whole line
+	Item     ConfItem // Offending item, nil if the issue is about the whole Conf
+}
+
+func (is Issue) String() string {
+	if is.Line > 0 && is.Column > 0 {
+		return fmt.Sprintf("%s: line %d, column %d: %s", is.Severity, is.Line, is.Column, is.Message)
+	}
+	if is.Line > 0 {
+		return fmt.Sprintf("%s: line %d: %s", is.Severity, is.Line, is.Message)
+	}
+	return fmt.Sprintf("%s: %s", is.Severity, is.Message)
+}
+
+// Issues is a list of findings, it implements error so it can be
+// returned as is
+type Issues []Issue
+
+// HasErrors returns true if any of the issues has error severity
+func (iss Issues) HasErrors() bool {
+	for _, is := range iss {
+		if is.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the issues with error severity as an error, or nil if
+// there are none
+func (iss Issues) Err() error {
+	var errs Issues
+	for _, is := range iss {
+		if is.Severity == SeverityError {
+			errs = append(errs, is)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (iss Issues) Error() string {
+	strs := make([]string, len(iss))
+	for i, is := range iss {
+		strs[i] = is.String()
+	}
+	return strings.Join(strs, "; ")
+}
+
+// Format selects the output format used by Issues.Render
+type Format int
+
+// Output formats
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+type jsonIssue struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column,omitempty"`
+	Item     string   `json:"item,omitempty"`
+}
+
+// MarshalJSON encodes the issues as a JSON array where each issue is an
+// object with the following fields:
+//
+//	code      string, one of the Issue* codes
+//	severity  string, "warning" or "error"
+//	message   string, human readable description
+//	line      number, line in the source file or 0 if unknown
+//	column    number, column in the line, omitted if unknown
+//	item      string, offending item as written in a resolv.conf file,
+//	          omitted when the issue concerns the whole configuration
+//
+// An empty list is encoded as [].
+func (iss Issues) MarshalJSON() ([]byte, error) {
+	out := make([]jsonIssue, len(iss))
+	for i, is := range iss {
+		out[i] = jsonIssue{is.Code, is.Severity, is.Message, is.Line, is.Column, ""}
+		if is.Item != nil {
+			out[i].Item = itemLine(is.Item)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// Render writes the issues to w in the given format, text format is one
+// issue per line
+func (iss Issues) Render(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		for _, is := range iss {
+			if _, err := fmt.Fprintf(w, "%s [%s]\n", is, is.Code); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatJSON:
+		b, err := json.MarshalIndent(iss, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	}
+	return fmt.Errorf("Unknown format %d", format)
+}
+
+// SetPolicy sets the validation policy used by Validate and WriteFileChecked,
+// the default is no policy
+func (conf *Conf) SetPolicy(p Policy) {
+	conf.policy = p
+}
+
+// GetPolicy returns current validation policy
+func (conf *Conf) GetPolicy() Policy {
+	return conf.policy
+}
+
+// Validate checks the configuration against the current policy. The checks
+// are done against what would actually be written with the given write
+// options, not against the raw Conf. The items of the issues are copies,
+// the Conf is not modified
+func (conf *Conf) Validate(opts ...WriteOption) Issues {
+	conf.rlock()
+	var iss Issues
+	rs := conf.renderSet(newWriteOptions(opts))
+
+	if conf.policy&RequireNameserver != 0 && len(rs.nameservers) == 0 {
+		switch {
+		case len(conf.items) == 0:
+			iss = append(iss, Issue{Code: IssueEmptyConf, Severity: SeverityError,
+				Message: "Conf is empty, at least one nameserver is required"})
+		case len(conf.nameserversInto(nil)) == 0:
+			iss = append(iss, Issue{Code: IssueNoNameserver, Severity: SeverityError,
+				Message: "Conf contains no nameserver, at least one is required"})
+		default:
+			iss = append(iss, Issue{Code: IssueNameserversFiltered, Severity: SeverityError,
+				Message: fmt.Sprintf("All %d nameservers are filtered out by write options, at least one is required",
+					len(conf.nameserversInto(nil)))})
+		}
+	}
+
+	if conf.policy&RequireSearchOrDomain != 0 && rs.domain.Name == "" && len(rs.searchDomains) == 0 {
+		iss = append(iss, Issue{Code: IssueNoSearchOrDomain, Severity: SeverityError,
+			Message: "Conf contains neither domain nor search domains, one is required"})
+	}
+
+	// Sortlist pairs with bits set outside the netmask; Item is a copy, the
+	// pair is fixed with Find and SortItem.Normalize
+	for _, item := range conf.items {
+		si, ok := item.(*SortItem)
+		if !ok {
+			continue
+		}
+		if nw, ok := si.network(); ok && !nw.Equal(si.Address) {
+			iss = append(iss, Issue{Code: IssueSortlistHostBits, Severity: SeverityWarning, Item: cloneItem(si),
+				Message: fmt.Sprintf("Sortlist pair %s has bits set outside the netmask, resolver matches %s/%s",
+					si, nw, si.Netmask)})
+		}
+	}
+
+	conf.runlock()
+
+	if conf.logger.enabled(LogDebug) {
+		for _, is := range iss {
+			conf.logger.l.Debug("Validation: "+is.Message, "op", "validate", "code", is.Code, "severity", is.Severity)
+		}
+	}
+	instrumentation().ObserveValidation(len(iss))
+	return iss
+}
@@ -0,0 +1,134 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestValidateWithoutPolicy(t *testing.T) {
+	conf := resolvconf.New()
+	assert.Empty(t, conf.Validate())
+}
+
+func TestRequireNameserverOnEmptyConf(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetPolicy(resolvconf.RequireNameserver)
+	iss := conf.Validate()
+	assert.Equal(t, 1, len(iss))
+	assert.Equal(t, resolvconf.IssueEmptyConf, iss[0].Code)
+	assert.Equal(t, resolvconf.SeverityError, iss[0].Severity)
+	assert.NotNil(t, iss.Err())
+}
+
+func TestRequireNameserverWithoutNameservers(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetPolicy(resolvconf.RequireNameserver)
+	conf.Add(resolvconf.NewDomain("foo.com"))
+	iss := conf.Validate()
+	assert.Equal(t, 1, len(iss))
+	assert.Equal(t, resolvconf.IssueNoNameserver, iss[0].Code)
+
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, conf.Validate().Err())
+}
+
+func TestRequireNameserverAllFiltered(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetPolicy(resolvconf.RequireNameserver)
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("127.0.0.1")))
+	noLoopback := resolvconf.FilterNameservers(func(ns resolvconf.Nameserver) bool {
+		return !ns.IP.IsLoopback()
+	})
+	assert.Nil(t, conf.Validate().Err())
+	iss := conf.Validate(noLoopback)
+	assert.Equal(t, 1, len(iss))
+	assert.Equal(t, resolvconf.IssueNameserversFiltered, iss[0].Code)
+	assert.Contains(t, iss.Error(), "filtered out")
+}
+
+func TestRequireSearchOrDomain(t *testing.T) {
+	conf := resolvconf.New()
+	conf.SetPolicy(resolvconf.RequireNameserver | resolvconf.RequireSearchOrDomain)
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	iss := conf.Validate()
+	assert.Equal(t, 1, len(iss))
+	assert.Equal(t, resolvconf.IssueNoSearchOrDomain, iss[0].Code)
+
+	conf.Add(resolvconf.NewSearchDomain("foo.com"))
+	assert.Empty(t, conf.Validate())
+}
+
+var goldenIssues = resolvconf.Issues{
+	{Code: resolvconf.IssueNoNameserver, Severity: resolvconf.SeverityError,
+		Message: "Conf contains no nameserver, at least one is required"},
+	{Code: "example", Severity: resolvconf.SeverityWarning, Message: "Example warning", Line: 3,
+		Item: resolvconf.NewNameserver(net.ParseIP("8.8.8.8"))},
+}
+
+func TestIssuesJSONGolden(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/issues.golden.json")
+	assert.Nil(t, err)
+	buf := new(bytes.Buffer)
+	err = goldenIssues.Render(buf, resolvconf.FormatJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, string(golden), buf.String())
+}
+
+func TestIssuesJSONEmpty(t *testing.T) {
+	b, err := json.Marshal(resolvconf.Issues{})
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", string(b))
+	b, err = json.Marshal(resolvconf.Issues(nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", string(b))
+}
+
+func TestIssuesRenderText(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := goldenIssues.Render(buf, resolvconf.FormatText)
+	assert.Nil(t, err)
+	assert.Equal(t, "error: Conf contains no nameserver, at least one is required [no-nameserver]\n"+
+		"warning: line 3: Example warning [example]\n", buf.String())
+}
+
+func TestSortlistHostBitsWarning(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewSortItem(net.ParseIP("10.1.2.3")).SetNetmask(net.ParseIP("255.255.255.0")))
+	iss := conf.Validate()
+	assert.Equal(t, 1, len(iss))
+	assert.Equal(t, resolvconf.IssueSortlistHostBits, iss[0].Code)
+	assert.Equal(t, resolvconf.SeverityWarning, iss[0].Severity)
+	assert.Contains(t, iss[0].Message, "10.1.2.0/255.255.255.0")
+	assert.Nil(t, iss.Err())
+
+	// The item is a copy, the one in the Conf is normalized through Find
+	held := conf.Find(iss[0].Item).(*resolvconf.SortItem)
+	assert.True(t, iss[0].Item.(*resolvconf.SortItem).Normalize())
+	assert.Equal(t, "10.1.2.3/255.255.255.0", conf.GetSortItems()[0].String())
+	assert.True(t, held.Normalize())
+	assert.Equal(t, "10.1.2.0/255.255.255.0", conf.GetSortItems()[0].String())
+	assert.Empty(t, conf.Validate())
+}
+
+func TestValidateKeepsCache(t *testing.T) {
+	// Validate only reads, the rendered output stays cached
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewSortItem(net.ParseIP("10.1.2.3")).SetNetmask(net.ParseIP("255.255.255.0")))
+	assert.Equal(t, 1, len(conf.Validate()))
+	s := conf.Snapshot()
+	assert.True(t, s == conf.Snapshot())
+}
+
+func TestSortlistHostBitsNoFalsePositives(t *testing.T) {
+	conf := resolvconf.New()
+	// 16 byte address with 4 byte netmask and vice versa
+	conf.Add(resolvconf.NewSortItem(net.ParseIP("10.1.2.0")).SetNetmask(net.IPv4(255, 255, 255, 0).To4()),
+		resolvconf.NewSortItem(net.IPv4(10, 2, 0, 0).To4()).SetNetmask(net.ParseIP("255.255.0.0")),
+		resolvconf.NewSortItem(net.ParseIP("10.3.0.1")))
+	assert.Empty(t, conf.Validate())
+}
@@ -0,0 +1,104 @@
+package resolvconf
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Warning codes
+const (
+	WarnValueCapped        = "value-capped"        // Option value lowered to the maximum
+	WarnValueUpdated       = "value-updated"       // Existing option given a new value instead of being added
+	WarnNameserverFiltered = "nameserver-filtered" // Nameserver not written because of FilterNameservers or LimitNameservers
+	WarnMergeDuplicate     = "merge-duplicate"     // Item skipped by a merge, an earlier source had it
+	WarnMergeConflict      = "merge-conflict"      // Item skipped by a merge, it conflicts with one kept by the policy
+	WarnLimitExceeded      = "limit-exceeded"      // Item beyond a limit accepted by LimitPermissive or SearchWarnOnly
+	WarnSearchReplaced     = "search-replaced"     // Search list of a file replaced by a later search line
+	WarnSortlistIPv6       = "sortlist-ipv6"       // IPv6 sortlist pair accepted by LimitPermissive
+	WarnOpenBSDOnly        = "openbsd-only"        // OpenBSD lookup or family line accepted by LimitPermissive
+	WarnOptionSkipped      = "option-skipped"      // Malformed or unknown RES_OPTIONS token skipped by ApplyEnvironment
+	WarnUnknownOption      = "unknown-option"      // Option of unknown type kept as is by AllowUnknownOptions
+)
+
+// Warning is a decision made on behalf of the caller that didn't fail the
+// operation, e.g. an option value that was capped
+type Warning struct {
+	Code    string
+	Message string
+	Line    int      // Line in the source file, 0 if unknown
+	Item    ConfItem // Item the warning is about, nil if none
+}
+
+func (w Warning) String() string {
+	if w.Line > 0 {
+		return fmt.Sprintf("warning: line %d: %s", w.Line, w.Message)
+	}
+	return fmt.Sprintf("warning: %s", w.Message)
+}
+
+// warningList holds the warnings of a Conf, it is shared by copies of the
+// Conf made by value receivers and may be appended to by concurrent Writes
+type warningList struct {
+	mu   sync.Mutex
+	list []Warning
+	to   *[]Warning // Set by addItemsTo while the Conf is locked
+	line int        // Line being parsed, 0 if not parsing
+}
+
+// Warnings returns the warnings collected since the Conf was created or
+// ClearWarnings was last called
+func (conf *Conf) Warnings() []Warning {
+	if conf.warnings == nil {
+		return nil
+	}
+	conf.warnings.mu.Lock()
+	defer conf.warnings.mu.Unlock()
+	return append([]Warning(nil), conf.warnings.list...)
+}
+
+// ClearWarnings drops the collected warnings
+func (conf *Conf) ClearWarnings() {
+	if conf.warnings == nil {
+		return
+	}
+	conf.warnings.mu.Lock()
+	conf.warnings.list = nil
+	conf.warnings.mu.Unlock()
+}
+
+// warn records a warning, on the Conf or for the operation collecting
+// them. The line being parsed is filled in if not set
+func (conf *Conf) warn(w Warning) {
+	wl := conf.warnings
+	if wl == nil {
+		return
+	}
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	if w.Line == 0 {
+		w.Line = wl.line
+	}
+	if wl.to != nil {
+		*wl.to = append(*wl.to, w)
+		return
+	}
+	wl.list = append(wl.list, w)
+}
+
+// AddWithWarnings is Add returning the warnings of the call instead of
+// collecting them on the Conf
+func (conf *Conf) AddWithWarnings(items ...ConfItem) ([]Warning, error) {
+	conf.lock()
+	defer conf.unlock()
+	var ws []Warning
+	err := conf.addItemsTo(items, &ws)
+	return ws, err
+}
+
+// CollectWarnings makes Write and WriteFile append their warnings to dst
+// instead of collecting them on the Conf
+func CollectWarnings(dst *[]Warning) WriteOption {
+	return writeOptionFunc(func(o *writeOptions) {
+		o.warnings = dst
+	})
+}
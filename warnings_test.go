@@ -0,0 +1,138 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func warningCodes(ws []resolvconf.Warning) []string {
+	var codes []string
+	for _, w := range ws {
+		codes = append(codes, w.Code)
+	}
+	return codes
+}
+
+func TestWarningsAreCollected(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewOption("ndots").Set(20), resolvconf.NewOption("ndots").Set(3), resolvconf.NewOption("ndots").Set(3))
+	ws := conf.Warnings()
+	assert.Equal(t, []string{resolvconf.WarnValueCapped, resolvconf.WarnValueUpdated}, warningCodes(ws))
+	assert.Equal(t, "Option ndots is capped to 15, set value is 20", ws[0].Message)
+	assert.Equal(t, "warning: Option ndots is already present, its value is changed from 15 to 3", ws[1].String())
+	assert.True(t, ws[1].Item == conf.Find(resolvconf.NewOption("ndots")))
+
+	conf.ClearWarnings()
+	assert.Equal(t, 0, len(conf.Warnings()))
+}
+
+func TestReadConfWarningsHaveLines(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("nameserver 8.8.8.8\n\noptions timeout:60 attempts:2\n"))
+	assert.Nil(t, err)
+	ws := conf.Warnings()
+	assert.Equal(t, 1, len(ws))
+	assert.Equal(t, resolvconf.WarnValueCapped, ws[0].Code)
+	assert.Equal(t, 3, ws[0].Line)
+	assert.Equal(t, "warning: line 3: Option timeout is capped to 30, set value is 60", ws[0].String())
+
+	// Warnings of later calls have no line
+	conf.Add(resolvconf.NewOption("attempts").Set(9))
+	assert.Equal(t, 0, conf.Warnings()[1].Line)
+}
+
+func TestAddWithWarnings(t *testing.T) {
+	conf := resolvconf.New()
+	ws, err := conf.AddWithWarnings(resolvconf.NewOption("timeout").Set(60), resolvconf.NewNameserver(net.ParseIP("8.8.8.8")))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{resolvconf.WarnValueCapped}, warningCodes(ws))
+	assert.Equal(t, 0, len(conf.Warnings()))
+
+	// Later warnings are collected on the Conf again
+	conf.Add(resolvconf.NewOption("timeout").Set(70))
+	assert.Equal(t, 1, len(conf.Warnings()))
+}
+
+// Run with -race
+func TestAddWithWarningsConcurrent(t *testing.T) {
+	conf := resolvconf.New()
+	const n = 500
+	var wg sync.WaitGroup
+	wg.Add(2)
+	collected := 0
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			ws, _ := conf.AddWithWarnings(resolvconf.NewOption("timeout").Set(60))
+			collected += len(ws)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			conf.Add(resolvconf.NewOption("attempts").Set(9))
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, n, collected)
+	ws := conf.Warnings()
+	assert.Equal(t, n, len(ws))
+	for _, w := range ws {
+		assert.Equal(t, "attempts", w.Item.(*resolvconf.Option).Type)
+	}
+}
+
+func TestWriteWarnsAboutFilteredNameservers(t *testing.T) {
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("8.8.8.8")), resolvconf.NewNameserver(net.ParseIP("127.0.0.53")))
+	noLoopback := resolvconf.FilterNameservers(func(ns resolvconf.Nameserver) bool { return !ns.IP.IsLoopback() })
+
+	assert.Nil(t, conf.Write(new(bytes.Buffer), noLoopback))
+	ws := conf.Warnings()
+	assert.Equal(t, []string{resolvconf.WarnNameserverFiltered}, warningCodes(ws))
+	assert.Equal(t, "127.0.0.53", ws[0].Item.String())
+
+	// Validate doesn't write, so doesn't warn
+	conf.ClearWarnings()
+	conf.Validate(noLoopback)
+	assert.Equal(t, 0, len(conf.Warnings()))
+
+	var own []resolvconf.Warning
+	assert.Nil(t, conf.Write(new(bytes.Buffer), noLoopback, resolvconf.CollectWarnings(&own)))
+	assert.Equal(t, 1, len(own))
+	assert.Equal(t, 0, len(conf.Warnings()))
+}
+
+func TestMergeWarnsAboutDuplicates(t *testing.T) {
+	frags := []resolvconf.InterfaceConf{
+		{Name: "eth0", Metric: 100, Conf: ifConf(t, "nameserver 10.0.0.1")},
+		{Name: "eth1", Metric: 200, Conf: ifConf(t, "nameserver 10.0.0.1")},
+	}
+	conf, err := resolvconf.MergeInterfaces(frags, resolvconf.MergeConfig{})
+	assert.Nil(t, err)
+	ws := conf.Warnings()
+	assert.Equal(t, []string{resolvconf.WarnMergeDuplicate}, warningCodes(ws))
+	assert.Equal(t, "nameserver 10.0.0.1 from interface eth1 is skipped, already merged", ws[0].Message)
+}
+
+func TestMergeWarnsAboutConflicts(t *testing.T) {
+	conf, err := resolvconf.ReadConf(strings.NewReader("domain example.com\nlookup file bind\noptions ndots:2\n"), resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Nil(t, err)
+	other, err := resolvconf.ReadConf(strings.NewReader("domain corp.example\nlookup bind\noptions ndots:5\n"), resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Nil(t, err)
+	assert.Nil(t, conf.Merge(other, resolvconf.KeepExisting))
+	ws := conf.Warnings()
+	assert.Equal(t, []string{resolvconf.WarnMergeConflict, resolvconf.WarnMergeConflict, resolvconf.WarnMergeConflict}, warningCodes(ws))
+	assert.Equal(t, "option ndots:5 is skipped, conflicts with ndots:2", ws[2].Message)
+
+	// Appended, the domain isn't a conflict
+	conf, _ = resolvconf.ReadConf(strings.NewReader("domain example.com\nlookup file bind\n"), resolvconf.WithProfile(resolvconf.ProfileNone))
+	assert.Nil(t, conf.Merge(other, resolvconf.Append))
+	ws = conf.Warnings()
+	assert.Equal(t, []string{resolvconf.WarnMergeConflict}, warningCodes(ws))
+	assert.Equal(t, "lookup bind is skipped, conflicts with lookup file bind", ws[0].Message)
+}
@@ -0,0 +1,94 @@
+package resolvconf
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch checks the file, unless changed
+// with WatchInterval
+const DefaultWatchInterval = time.Second
+
+// WatchInterval sets how often Watch checks the file
+func WatchInterval(d time.Duration) FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.watchInterval = d
+	})
+}
+
+// Watch reads the file at path and checks it for changes until ctx is
+// done, every new configuration is sent on the returned Conf channel. The
+// current configuration is sent first.
+//
+// The file is read by path on every check, so a file renamed over path,
+// which is how most tools replace it, and a symlink changed to point
+// elsewhere are seen like writes in place. A change is only sent once the
+// file stayed the same for one interval, rapid successive writes are sent
+// as one change.
+//
+// Errors reading or parsing the file are sent on the error channel and the
+// file is watched on, a Conf parsed with errors is sent as well. The same
+// read error is only sent once and errors are dropped while an earlier one
+// hasn't been received. Both channels are closed once ctx is done
+func Watch(ctx context.Context, path string, opts ...FileOption) (<-chan *Conf, <-chan error) {
+	o := newFileOptions(opts)
+	confs := make(chan *Conf)
+	errs := make(chan error, 1)
+	go o.watch(ctx, path, confs, errs)
+	return confs, errs
+}
+
+func (o *fileOptions) watch(ctx context.Context, path string, confs chan<- *Conf, errs chan<- error) {
+	defer close(confs)
+	defer close(errs)
+	report := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	ticker := time.NewTicker(o.watchInterval)
+	defer ticker.Stop()
+
+	var sent, seen []byte // Content last sent and read
+	hasSent, first := false, true
+	lastErr := ""
+	for {
+		resolved, err := o.resolve(path)
+		var data []byte
+		if err == nil {
+			data, err = o.fs.ReadFile(o.path(resolved))
+		}
+		switch {
+		case err != nil:
+			if err.Error() != lastErr {
+				lastErr = err.Error()
+				report(err)
+			}
+		case (first || bytes.Equal(data, seen)) && (!hasSent || !bytes.Equal(data, sent)):
+			lastErr = ""
+			conf, err := o.readConf(bytes.NewReader(data), path, resolved)
+			if err != nil {
+				report(err)
+			}
+			sent, hasSent = data, true
+			if conf != nil {
+				select {
+				case confs <- conf:
+				case <-ctx.Done():
+					return
+				}
+			}
+		default:
+			lastErr = ""
+		}
+		seen, first = data, false
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package resolvconf_test
+
+import (
+	"." // import the main package
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextConf(t *testing.T, confs <-chan *resolvconf.Conf) *resolvconf.Conf {
+	select {
+	case conf := <-confs:
+		return conf
+	case <-time.After(5 * time.Second):
+		t.Fatal("No configuration received")
+	}
+	return nil
+}
+
+func nextErr(t *testing.T, errs <-chan error) error {
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("No error received")
+	}
+	return nil
+}
+
+func TestWatchRenamedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "resolvconf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("nameserver 10.0.0.1\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	confs, errs := resolvconf.Watch(ctx, path, resolvconf.WatchInterval(10*time.Millisecond))
+	assert.Equal(t, "10.0.0.1", nextConf(t, confs).GetNameservers()[0].String())
+
+	// WriteFile renames a new file over the old one
+	conf := resolvconf.New()
+	conf.Add(resolvconf.NewNameserver(net.ParseIP("10.0.0.2")))
+	assert.Nil(t, conf.WriteFile(path, 0644))
+	got := nextConf(t, confs)
+	assert.Equal(t, "10.0.0.2", got.GetNameservers()[0].String())
+	assert.Equal(t, path, got.Source())
+
+	cancel()
+	_, ok := <-confs
+	assert.False(t, ok)
+	_, ok = <-errs
+	assert.False(t, ok)
+}
+
+func TestWatchDebouncesAndReportsErrors(t *testing.T) {
+	fsys := resolvconf.NewMemFS()
+	assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte("nameserver 10.0.0.1\n"), 0644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	confs, errs := resolvconf.Watch(ctx, "/etc/resolv.conf", resolvconf.WithFileSystem(fsys), resolvconf.WatchInterval(50*time.Millisecond))
+	nextConf(t, confs)
+
+	for _, ns := range []string{"10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte("nameserver "+ns+"\n"), 0644))
+	}
+	assert.Equal(t, "10.0.0.4", nextConf(t, confs).GetNameservers()[0].String())
+	select {
+	case conf := <-confs:
+		t.Errorf("Unexpected configuration %s", conf)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Parse errors don't stop the watcher
+	assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte("nameserver 10.0.0.5\noptions foo\n"), 0644))
+	assert.True(t, errors.Is(nextErr(t, errs), resolvconf.ErrUnknownOption))
+	assert.Equal(t, "10.0.0.5", nextConf(t, confs).GetNameservers()[0].String())
+
+	// Nor does a missing file
+	assert.Nil(t, fsys.Remove("/etc/resolv.conf"))
+	assert.True(t, errors.Is(nextErr(t, errs), os.ErrNotExist))
+	assert.Nil(t, fsys.WriteFile("/etc/resolv.conf", []byte("nameserver 10.0.0.6\n"), 0644))
+	assert.Equal(t, "10.0.0.6", nextConf(t, confs).GetNameservers()[0].String())
+}